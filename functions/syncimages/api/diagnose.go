@@ -0,0 +1,51 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DiagnoseRequest is the body of POST /diagnose.
+type DiagnoseRequest struct {
+	SensorTypes []string `json:"sensorTypes,omitempty" description:"Only check the credentials and registries of these sensor types. Defaults to every sensor type."`
+}
+
+// DecodeDiagnoseRequest decodes the request body, rejecting unknown fields.
+func DecodeDiagnoseRequest(body io.Reader) (DiagnoseRequest, error) {
+	var req DiagnoseRequest
+	err := decode(body, &req)
+
+	return req, err
+}
+
+// Validate checks the sensor types against the catalog.
+func (r DiagnoseRequest) Validate(sensorTypes []string) error {
+	known := map[string]bool{}
+	for _, sensorType := range sensorTypes {
+		known[sensorType] = true
+	}
+
+	for _, sensorType := range r.SensorTypes {
+		if !known[sensorType] {
+			return fmt.Errorf("unknown sensor type %q, expected one of: %s", sensorType, strings.Join(sensorTypes, ", "))
+		}
+	}
+
+	return nil
+}
+
+// Includes reports whether the sensor type is diagnosed.
+func (r DiagnoseRequest) Includes(sensorType string) bool {
+	if len(r.SensorTypes) == 0 {
+		return true
+	}
+
+	for _, s := range r.SensorTypes {
+		if s == sensorType {
+			return true
+		}
+	}
+
+	return false
+}
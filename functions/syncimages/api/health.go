@@ -0,0 +1,26 @@
+package api
+
+import (
+	"syncimages/diagnose"
+	"syncimages/version"
+)
+
+// VersionResponse is the body returned by GET /version.
+type VersionResponse struct {
+	version.Info
+	// SchemaVersion is the version of the stored image list format.
+	SchemaVersion int `json:"schemaVersion"`
+}
+
+// HealthResponse is the body returned by GET /healthz.
+type HealthResponse struct {
+	// Status is ok when every check passed, fail otherwise.
+	Status  string `json:"status"`
+	Version string `json:"version"`
+	// FnID, FnVersion and FnBuildVersion identify the deployed function, like the default health
+	// check of the function runtime.
+	FnID           string `json:"fn_id"`
+	FnVersion      string `json:"fn_version"`
+	FnBuildVersion string `json:"fn_build_version"`
+	diagnose.Report
+}
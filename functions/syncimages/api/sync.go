@@ -0,0 +1,171 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	falconapi "syncimages/falcon"
+)
+
+// MaxTimeoutSeconds is the largest sync timeout accepted.
+const MaxTimeoutSeconds = 900
+
+// maxTriggerLength is the longest trigger accepted.
+const maxTriggerLength = 64
+
+// SyncRequest is the body of POST /sync-images. All fields are optional.
+type SyncRequest struct {
+	SensorTypes    []string       `json:"sensorTypes,omitempty" description:"Only sync these sensor types. Defaults to every image of the catalog."`
+	Force          bool           `json:"force,omitempty" description:"Sync even if the stored images are more recent than the minimum sync interval."`
+	DryRun         bool           `json:"dryRun,omitempty" description:"Return the synced images without writing them to the images collection."`
+	TagLimit       int            `json:"tagLimit,omitempty" description:"Only fetch the newest tags of every sensor type, the stored tags are kept. 0 fetches all tags." minimum:"0"`
+	TagLimits      map[string]int `json:"tagLimits,omitempty" description:"Only fetch the newest tags per sensor type, overriding tagLimit."`
+	Archs          []string       `json:"archs,omitempty" description:"Only store tags available for these architectures, e.g. x86_64 or aarch64. The stored tags of other architectures are dropped."`
+	TimeoutSeconds int            `json:"timeoutSeconds,omitempty" description:"Abort the sync after this many seconds. 0 uses the function timeout." minimum:"0" maximum:"900"`
+	Trigger        string         `json:"trigger,omitempty" description:"What triggered the sync, recorded in the sync_runs collection, e.g. ui, workflow or schedule. Defaults to api."`
+	Async          bool           `json:"async,omitempty" description:"Start the sync as a background job and return the job immediately. Poll GET /sync-jobs/{id} for its progress."`
+	MemberCIDs     []string       `json:"memberCids,omitempty" description:"Sync these MSSP member CIDs instead of the CID of the app. Requires a parent API client, see docs/DEVELOPER.md."`
+	AllMembers     bool           `json:"allMembers,omitempty" description:"Sync the MSSP member CIDs of the function configuration instead of the CID of the app. Requires a parent API client, see docs/DEVELOPER.md."`
+}
+
+// DecodeSyncRequest decodes the request body, rejecting unknown fields. An empty body is a
+// request with the default options.
+func DecodeSyncRequest(body io.Reader) (SyncRequest, error) {
+	var req SyncRequest
+	err := decode(body, &req)
+
+	return req, err
+}
+
+// decode decodes the JSON request body into v, rejecting unknown fields. An empty body leaves
+// v unchanged.
+func decode(body io.Reader, v interface{}) error {
+	if body == nil {
+		return nil
+	}
+
+	b, err := io.ReadAll(body)
+	if err != nil {
+		return fmt.Errorf("error reading request body: %v", err)
+	}
+	if len(bytes.TrimSpace(b)) == 0 {
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		return fmt.Errorf("invalid request body: %v", strings.TrimPrefix(err.Error(), "json: "))
+	}
+
+	return nil
+}
+
+// Validate checks the options against the sensor types of the catalog.
+func (r SyncRequest) Validate(sensorTypes []string) error {
+	known := map[string]bool{}
+	for _, sensorType := range sensorTypes {
+		known[sensorType] = true
+	}
+
+	for _, sensorType := range r.SensorTypes {
+		if !known[sensorType] {
+			return fmt.Errorf("unknown sensor type %q, expected one of: %s", sensorType, strings.Join(sensorTypes, ", "))
+		}
+	}
+
+	if r.TagLimit < 0 {
+		return fmt.Errorf("tagLimit must not be negative")
+	}
+	for sensorType, limit := range r.TagLimits {
+		if !known[sensorType] {
+			return fmt.Errorf("unknown sensor type %q in tagLimits", sensorType)
+		}
+		if limit < 0 {
+			return fmt.Errorf("tagLimits[%q] must not be negative", sensorType)
+		}
+	}
+
+	if len(r.Trigger) > maxTriggerLength {
+		return fmt.Errorf("trigger must not be longer than %d characters", maxTriggerLength)
+	}
+
+	if r.TimeoutSeconds < 0 || r.TimeoutSeconds > MaxTimeoutSeconds {
+		return fmt.Errorf("timeoutSeconds must be between 0 and %d", MaxTimeoutSeconds)
+	}
+
+	seen := map[string]bool{}
+	for _, value := range r.MemberCIDs {
+		cid, err := falconapi.ParseCID(value)
+		if err != nil {
+			return fmt.Errorf("memberCids: %v", err)
+		}
+		if seen[cid] {
+			return fmt.Errorf("memberCids: duplicate CID %q", value)
+		}
+		seen[cid] = true
+	}
+	if r.AllMembers && len(r.MemberCIDs) > 0 {
+		return fmt.Errorf("allMembers and memberCids cannot be combined")
+	}
+	if r.Async && (r.AllMembers || len(r.MemberCIDs) > 0) {
+		return fmt.Errorf("async syncs of member CIDs are not supported")
+	}
+
+	return nil
+}
+
+// Includes reports whether the sensor type is selected for the sync.
+func (r SyncRequest) Includes(sensorType string) bool {
+	if len(r.SensorTypes) == 0 {
+		return true
+	}
+
+	for _, selected := range r.SensorTypes {
+		if selected == sensorType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Members returns the member CIDs selected by the request, lowercased, or the configured member
+// CIDs with allMembers. Without them the sync covers the CID of the app.
+func (r SyncRequest) Members(configured []string) []string {
+	if r.AllMembers {
+		return configured
+	}
+	if len(r.MemberCIDs) == 0 {
+		return nil
+	}
+
+	cids := make([]string, 0, len(r.MemberCIDs))
+	for _, cid := range r.MemberCIDs {
+		cids = append(cids, strings.ToLower(strings.TrimSpace(cid)))
+	}
+
+	return cids
+}
+
+// Limit returns the number of newest tags to keep for the sensor type, 0 for all.
+func (r SyncRequest) Limit(sensorType string) int {
+	if limit, ok := r.TagLimits[sensorType]; ok {
+		return limit
+	}
+
+	return r.TagLimit
+}
+
+// Timeout returns the sync timeout, 0 for none.
+func (r SyncRequest) Timeout() time.Duration {
+	return time.Duration(r.TimeoutSeconds) * time.Second
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"strings"
+	"testing"
+)
+
+const testCID = "0123456789abcdef0123456789abcdef"
+
+func TestValidate(t *testing.T) {
+	sensorTypes := []string{"falcon-sensor", "falcon-kac"}
+
+	tests := []struct {
+		name string
+		req  SyncRequest
+		err  string
+	}{
+		{name: "empty", req: SyncRequest{}},
+		{name: "all options", req: SyncRequest{SensorTypes: []string{"falcon-kac"}, TagLimit: 5, TagLimits: map[string]int{"falcon-sensor": 0}, TimeoutSeconds: MaxTimeoutSeconds, Trigger: "workflow", MemberCIDs: []string{testCID + "-ab"}}},
+		{name: "unknown sensor type", req: SyncRequest{SensorTypes: []string{"falcon-unknown"}}, err: `unknown sensor type "falcon-unknown", expected one of: falcon-sensor, falcon-kac`},
+		{name: "negative tag limit", req: SyncRequest{TagLimit: -1}, err: "tagLimit must not be negative"},
+		{name: "unknown tag limit", req: SyncRequest{TagLimits: map[string]int{"falcon-unknown": 1}}, err: `unknown sensor type "falcon-unknown" in tagLimits`},
+		{name: "negative tag limits", req: SyncRequest{TagLimits: map[string]int{"falcon-kac": -1}}, err: `tagLimits["falcon-kac"] must not be negative`},
+		{name: "long trigger", req: SyncRequest{Trigger: strings.Repeat("x", maxTriggerLength+1)}, err: "trigger must not be longer"},
+		{name: "negative timeout", req: SyncRequest{TimeoutSeconds: -1}, err: "timeoutSeconds must be between"},
+		{name: "long timeout", req: SyncRequest{TimeoutSeconds: MaxTimeoutSeconds + 1}, err: "timeoutSeconds must be between"},
+		{name: "invalid CID", req: SyncRequest{MemberCIDs: []string{"not-a-cid"}}, err: "memberCids: invalid CID"},
+		{name: "duplicate CID", req: SyncRequest{MemberCIDs: []string{testCID, strings.ToUpper(testCID)}}, err: "memberCids: duplicate CID"},
+		{name: "async members", req: SyncRequest{Async: true, MemberCIDs: []string{testCID}}, err: "async syncs of member CIDs are not supported"},
+		{name: "async all members", req: SyncRequest{Async: true, AllMembers: true}, err: "async syncs of member CIDs are not supported"},
+		{name: "all and named members", req: SyncRequest{AllMembers: true, MemberCIDs: []string{testCID}}, err: "allMembers and memberCids cannot be combined"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.Validate(sensorTypes)
+			if tt.err == "" && err != nil {
+				t.Errorf("Validate() error = %v", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestDecodeSyncRequest(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want SyncRequest
+		err  string
+	}{
+		{name: "empty", body: ""},
+		{name: "whitespace", body: " \n"},
+		{name: "options", body: `{"sensorTypes":["falcon-kac"],"tagLimit":3,"force":true}`, want: SyncRequest{SensorTypes: []string{"falcon-kac"}, TagLimit: 3, Force: true}},
+		{name: "unknown field", body: `{"sensorType":"falcon-kac"}`, err: `invalid request body: unknown field "sensorType"`},
+		{name: "invalid type", body: `{"tagLimit":"3"}`, err: "invalid request body"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeSyncRequest(strings.NewReader(tt.body))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("DecodeSyncRequest() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DecodeSyncRequest() error = %v", err)
+			}
+			if strings.Join(got.SensorTypes, ",") != strings.Join(tt.want.SensorTypes, ",") || got.TagLimit != tt.want.TagLimit || got.Force != tt.want.Force {
+				t.Errorf("DecodeSyncRequest() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMembers(t *testing.T) {
+	configured := []string{testCID}
+
+	tests := []struct {
+		name string
+		req  SyncRequest
+		want []string
+	}{
+		{name: "app", req: SyncRequest{}},
+		{name: "all members", req: SyncRequest{AllMembers: true}, want: configured},
+		{name: "named members", req: SyncRequest{MemberCIDs: []string{" " + strings.ToUpper(testCID) + "-AB"}}, want: []string{testCID + "-ab"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.req.Members(configured); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Members() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,16 @@
+package api
+
+import "io"
+
+// WebhookTestRequest is the body of POST /webhooks/test.
+type WebhookTestRequest struct {
+	Webhook string `json:"webhook,omitempty" description:"Only call the webhook with this name. Defaults to every webhook."`
+}
+
+// DecodeWebhookTestRequest decodes the request body, rejecting unknown fields.
+func DecodeWebhookTestRequest(body io.Reader) (WebhookTestRequest, error) {
+	var req WebhookTestRequest
+	err := decode(body, &req)
+
+	return req, err
+}
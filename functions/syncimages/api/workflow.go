@@ -0,0 +1,132 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	falconapi "syncimages/falcon"
+	"syncimages/images"
+)
+
+// SyncResponse is the body returned by POST /sync-images: the synced image list with outputs
+// that Falcon Fusion workflows can branch on.
+type SyncResponse struct {
+	images.ImageList
+	// NewReleases are the tags added by the sync.
+	NewReleases    []images.Change `json:"newReleases" description:"Tags added since the previous sync."`
+	HasNewReleases bool            `json:"hasNewReleases" description:"Whether the sync found new tags."`
+	// Latest and Digests map every sensor type to its latest tag and digest.
+	Latest  map[string]string `json:"latestTags" description:"Latest tag per sensor type."`
+	Digests map[string]string `json:"latestDigests" description:"Digest of the latest tag per sensor type."`
+	// Members are the outcomes of the member CIDs of an MSSP sync, whose images are not part of
+	// the response.
+	Members []MemberSync `json:"members,omitempty" description:"Outcome of every MSSP member CID synced. Set instead of the images, latest tags and digests."`
+}
+
+// MemberSync is the outcome of the sync of an MSSP member CID.
+type MemberSync struct {
+	MemberCID   string            `json:"memberCid" description:"Member CID."`
+	Updated     time.Time         `json:"updated" description:"When the images of the member were synced."`
+	NewReleases []images.Change   `json:"newReleases" description:"Tags added since the previous sync of the member."`
+	Latest      map[string]string `json:"latestTags" description:"Latest tag per sensor type."`
+	Digests     map[string]string `json:"latestDigests" description:"Digest of the latest tag per sensor type."`
+	Error       string            `json:"error,omitempty" description:"Why the sync of the member failed."`
+}
+
+// NewSyncResponse returns the response for the image list.
+func NewSyncResponse(l images.ImageList) SyncResponse {
+	res := SyncResponse{
+		ImageList:   l,
+		NewReleases: []images.Change{},
+		Latest:      map[string]string{},
+		Digests:     map[string]string{},
+	}
+
+	for _, change := range l.Changes {
+		if change.Type == images.ChangeAdded {
+			res.NewReleases = append(res.NewReleases, change)
+		}
+	}
+	res.HasNewReleases = len(res.NewReleases) > 0
+
+	for _, img := range l.Images {
+		res.Latest[img.SensorType] = img.LatestTag
+		res.Digests[img.SensorType] = img.LatestDigest
+	}
+
+	return res
+}
+
+// NewMembersSyncResponse returns the response of a sync of MSSP member CIDs, with the image lists
+// of the members that synced and the errors of the others by member CID. The new releases of all
+// members are aggregated, each carrying its member CID.
+func NewMembersSyncResponse(cids []string, lists map[string]images.ImageList, errs map[string]error) SyncResponse {
+	res := NewSyncResponse(images.ImageList{Images: []images.Image{}})
+	res.Members = []MemberSync{}
+
+	for _, cid := range cids {
+		member := MemberSync{MemberCID: cid, NewReleases: []images.Change{}, Latest: map[string]string{}, Digests: map[string]string{}}
+		if err, ok := errs[cid]; ok {
+			member.Error = err.Error()
+			res.Members = append(res.Members, member)
+			continue
+		}
+
+		synced := NewSyncResponse(lists[cid])
+		member.Updated = synced.Updated
+		member.NewReleases = synced.NewReleases
+		member.Latest = synced.Latest
+		member.Digests = synced.Digests
+		res.Members = append(res.Members, member)
+		res.NewReleases = append(res.NewReleases, synced.NewReleases...)
+	}
+	res.HasNewReleases = len(res.NewReleases) > 0
+
+	return res
+}
+
+// RecommendedImageRequest is the body of POST /recommended-image.
+type RecommendedImageRequest struct {
+	SensorType string `json:"sensorType" description:"Sensor type of the image, e.g. falcon-sensor." required:"true"`
+	Arch       string `json:"arch,omitempty" description:"Architecture the image must support, e.g. x86_64 or aarch64."`
+	Release    string `json:"release,omitempty" description:"Release position: N for the newest minor version, N-1 for the one before, ... Defaults to N."`
+	MemberCID  string `json:"memberCid,omitempty" description:"MSSP member CID whose images are read. Defaults to the CID of the app."`
+}
+
+// DecodeRecommendedImageRequest decodes and validates the request body, rejecting unknown fields.
+func DecodeRecommendedImageRequest(body io.Reader) (RecommendedImageRequest, error) {
+	var req RecommendedImageRequest
+	if err := decode(body, &req); err != nil {
+		return req, err
+	}
+
+	req.SensorType = strings.TrimSpace(req.SensorType)
+	if req.SensorType == "" {
+		return req, fmt.Errorf("sensorType is required")
+	}
+	if req.Release == "" {
+		req.Release = "N"
+	}
+	if req.MemberCID != "" {
+		cid, err := falconapi.ParseCID(req.MemberCID)
+		if err != nil {
+			return req, fmt.Errorf("memberCid: %v", err)
+		}
+		req.MemberCID = cid
+	}
+
+	return req, nil
+}
+
+// ImageRef is a single image reference resolved from a release position.
+type ImageRef struct {
+	SensorType string   `json:"sensorType" description:"Sensor type of the image."`
+	Release    string   `json:"release" description:"Release position of the tag."`
+	Arch       string   `json:"arch,omitempty" description:"Requested architecture."`
+	Tag        string   `json:"tag" description:"Newest tag of the release position."`
+	Digest     string   `json:"digest" description:"Digest of the tag."`
+	Archs      []string `json:"archs" description:"Architectures of the tag."`
+	Image      string   `json:"image" description:"Image reference pinned by digest, e.g. registry/repository@sha256:..."`
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"syncimages/images"
+)
+
+func TestNewSyncResponse(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := images.ImageList{
+		Updated: now,
+		Images: []images.Image{
+			{SensorType: "falcon-sensor", LatestTag: "7.12.0", LatestDigest: "sha256:a"},
+			{SensorType: "falcon-kac", LatestTag: "7.11.0", LatestDigest: "sha256:b"},
+		},
+		Changes: []images.Change{
+			{Type: images.ChangeAdded, SensorType: "falcon-sensor", Tag: "7.12.0"},
+			{Type: images.ChangeRemoved, SensorType: "falcon-sensor", Tag: "7.9.0"},
+			{Type: images.ChangeDigestChanged, SensorType: "falcon-kac", Tag: "7.11.0"},
+		},
+	}
+
+	res := NewSyncResponse(l)
+	if !res.HasNewReleases || len(res.NewReleases) != 1 || res.NewReleases[0].Tag != "7.12.0" {
+		t.Errorf("NewReleases = %+v, want only the added 7.12.0", res.NewReleases)
+	}
+	if res.Latest["falcon-sensor"] != "7.12.0" || res.Latest["falcon-kac"] != "7.11.0" {
+		t.Errorf("Latest = %v", res.Latest)
+	}
+	if res.Digests["falcon-sensor"] != "sha256:a" || res.Digests["falcon-kac"] != "sha256:b" {
+		t.Errorf("Digests = %v", res.Digests)
+	}
+	if !res.Updated.Equal(now) || len(res.Images) != 2 {
+		t.Errorf("ImageList = %+v, want the synced list", res.ImageList)
+	}
+
+	empty := NewSyncResponse(images.ImageList{})
+	if empty.HasNewReleases || empty.NewReleases == nil || empty.Latest == nil || empty.Digests == nil {
+		t.Errorf("NewSyncResponse(empty) = %+v, want empty outputs", empty)
+	}
+}
+
+func TestNewMembersSyncResponse(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	failed, synced := testCID+"-aa", testCID+"-bb"
+	lists := map[string]images.ImageList{synced: {
+		Updated: now,
+		Images:  []images.Image{{SensorType: "falcon-sensor", LatestTag: "7.12.0", LatestDigest: "sha256:a"}},
+		Changes: []images.Change{{Type: images.ChangeAdded, SensorType: "falcon-sensor", Tag: "7.12.0", MemberCID: synced}},
+	}}
+	errs := map[string]error{failed: errors.New("error getting member token")}
+
+	res := NewMembersSyncResponse([]string{failed, synced}, lists, errs)
+	if len(res.Images) != 0 || len(res.Latest) != 0 {
+		t.Errorf("images, latest = %v, %v, want none for a members sync", res.Images, res.Latest)
+	}
+	if !res.HasNewReleases || len(res.NewReleases) != 1 || res.NewReleases[0].MemberCID != synced {
+		t.Errorf("NewReleases = %+v, want the release of %s", res.NewReleases, synced)
+	}
+	if len(res.Members) != 2 {
+		t.Fatalf("Members = %+v, want 2", res.Members)
+	}
+	if got := res.Members[0]; got.MemberCID != failed || got.Error != "error getting member token" || got.NewReleases == nil || got.Latest == nil {
+		t.Errorf("Members[0] = %+v, want the error of %s", got, failed)
+	}
+	if got := res.Members[1]; got.MemberCID != synced || got.Error != "" || !got.Updated.Equal(now) || got.Latest["falcon-sensor"] != "7.12.0" || got.Digests["falcon-sensor"] != "sha256:a" || len(got.NewReleases) != 1 {
+		t.Errorf("Members[1] = %+v, want the sync of %s", got, synced)
+	}
+}
+
+func TestDecodeRecommendedImageRequest(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want RecommendedImageRequest
+		err  string
+	}{
+		{name: "defaults", body: `{"sensorType":" falcon-sensor "}`, want: RecommendedImageRequest{SensorType: "falcon-sensor", Release: "N"}},
+		{name: "options", body: `{"sensorType":"falcon-kac","arch":"aarch64","release":"N-1"}`, want: RecommendedImageRequest{SensorType: "falcon-kac", Arch: "aarch64", Release: "N-1"}},
+		{name: "missing sensor type", body: `{"arch":"x86_64"}`, err: "sensorType is required"},
+		{name: "unknown field", body: `{"sensorType":"falcon-sensor","tag":"latest"}`, err: `unknown field "tag"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeRecommendedImageRequest(strings.NewReader(tt.body))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("DecodeRecommendedImageRequest() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DecodeRecommendedImageRequest() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("DecodeRecommendedImageRequest() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
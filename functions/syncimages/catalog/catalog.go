@@ -0,0 +1,426 @@
+package catalog
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strings"
+
+	falconapi "syncimages/falcon"
+
+	"github.com/Masterminds/semver"
+	"github.com/crowdstrike/gofalcon/falcon"
+)
+
+//go:embed catalog.json
+var defaultCatalog []byte
+
+// TagOrder is the strategy used to order the tags of an image.
+type TagOrder string
+
+const (
+	// TagOrderSemver sorts tags in semver order and drops tags that are not valid semver.
+	TagOrderSemver TagOrder = "semver"
+	// TagOrderRegistry keeps tags in the order returned by the registry.
+	TagOrderRegistry TagOrder = "registry"
+)
+
+// Catalog describes the container images synced by the function.
+type Catalog struct {
+	Images []Entry `json:"images"`
+	// Repositories are additional repositories hosted outside of the CrowdStrike registry.
+	Repositories []Repository `json:"repositories,omitempty"`
+}
+
+// Entry describes a single container image in the CrowdStrike registry.
+type Entry struct {
+	SensorType  falcon.SensorType     `json:"sensorType"`
+	Name        string                `json:"name"`
+	Description string                `json:"description"`
+	DocsURL     string                `json:"docsUrl,omitempty"`
+	LoginPrefix string                `json:"loginPrefix"`
+	TokenSource falconapi.TokenSource `json:"tokenSource"`
+	TagPolicy
+	Namespace Namespace `json:"namespace"`
+	// Disabled removes an image from the catalog when set in a custom catalog.
+	Disabled bool `json:"disabled,omitempty"`
+}
+
+// TagPolicy describes how the tags of a repository are filtered and ordered.
+type TagPolicy struct {
+	TagOrder   TagOrder    `json:"tagOrder"`
+	TagFilters []TagFilter `json:"tagFilters,omitempty"`
+	// SupportedReleases is the number of supported minor versions, e.g. 3 for N, N-1 and N-2.
+	// Zero leaves the support status of the tags unknown.
+	SupportedReleases int `json:"supportedReleases,omitempty"`
+}
+
+// TagFilter restricts the tags of an image. A tag is kept when it matches every field set on the filter.
+type TagFilter struct {
+	// Constraint is a semver constraint checked against the version part of the tag (before the first "-").
+	Constraint string `json:"constraint,omitempty"`
+	// Pattern is a regular expression the tag must match.
+	Pattern string `json:"pattern,omitempty"`
+
+	constraint *semver.Constraints
+	pattern    *regexp.Regexp
+}
+
+// Namespace describes where the image lives in the CrowdStrike registry.
+type Namespace struct {
+	// Name is the first path segment of the repository.
+	Name string `json:"name"`
+	// CloudScoped adds the registry cloud segment (e.g. us-1) after the name.
+	CloudScoped bool `json:"cloudScoped"`
+	// Path is the remainder of the repository path.
+	Path string `json:"path"`
+}
+
+// Default returns the catalog embedded in the function.
+func Default() (Catalog, error) {
+	var c Catalog
+	if err := json.Unmarshal(defaultCatalog, &c); err != nil {
+		return Catalog{}, fmt.Errorf("error parsing embedded catalog: %v", err)
+	}
+
+	return c, nil
+}
+
+// Load returns the embedded catalog merged with the custom catalog, if any, and validates the result.
+// Custom entries replace embedded entries with the same sensor type and are appended otherwise.
+func Load(custom *Catalog) (Catalog, error) {
+	c, err := Default()
+	if err != nil {
+		return Catalog{}, err
+	}
+
+	if custom != nil {
+		c = c.merge(*custom)
+	}
+
+	if err := c.Validate(); err != nil {
+		return Catalog{}, err
+	}
+
+	return c, nil
+}
+
+// merge overlays the custom catalog on top of c and drops disabled entries and repositories.
+func (c Catalog) merge(custom Catalog) Catalog {
+	images := append([]Entry{}, c.Images...)
+
+	for _, entry := range custom.Images {
+		replaced := false
+		for i := range images {
+			if images[i].SensorType == entry.SensorType {
+				images[i] = entry
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			images = append(images, entry)
+		}
+	}
+
+	repositories := append([]Repository{}, c.Repositories...)
+
+	for _, repo := range custom.Repositories {
+		replaced := false
+		for i := range repositories {
+			if repositories[i].SensorType == repo.SensorType {
+				repositories[i] = repo
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			repositories = append(repositories, repo)
+		}
+	}
+
+	merged := Catalog{}
+	for _, entry := range images {
+		if !entry.Disabled {
+			merged.Images = append(merged.Images, entry)
+		}
+	}
+	for _, repo := range repositories {
+		if !repo.Disabled {
+			merged.Repositories = append(merged.Repositories, repo)
+		}
+	}
+
+	return merged
+}
+
+// Validate checks the catalog for missing or invalid fields and compiles the tag filters.
+func (c *Catalog) Validate() error {
+	if len(c.Images) == 0 {
+		return fmt.Errorf("catalog has no images")
+	}
+
+	seen := map[falcon.SensorType]bool{}
+	for i := range c.Images {
+		entry := &c.Images[i]
+		if err := entry.validate(); err != nil {
+			return fmt.Errorf("invalid catalog image %d (%q): %v", i, entry.SensorType, err)
+		}
+		if seen[entry.SensorType] {
+			return fmt.Errorf("duplicate catalog image: %q", entry.SensorType)
+		}
+		seen[entry.SensorType] = true
+	}
+
+	for i := range c.Repositories {
+		repo := &c.Repositories[i]
+		if err := repo.validate(); err != nil {
+			return fmt.Errorf("invalid catalog repository %d (%q): %v", i, repo.SensorType, err)
+		}
+		if seen[repo.SensorType] {
+			return fmt.Errorf("duplicate catalog repository: %q", repo.SensorType)
+		}
+		seen[repo.SensorType] = true
+	}
+
+	return nil
+}
+
+// validate checks a single entry and compiles its tag filters.
+func (e *Entry) validate() error {
+	switch {
+	case e.SensorType == "":
+		return fmt.Errorf("sensorType is required")
+	case e.Name == "":
+		return fmt.Errorf("name is required")
+	case e.LoginPrefix == "":
+		return fmt.Errorf("loginPrefix is required")
+	case e.Namespace.Name == "" || e.Namespace.Path == "":
+		return fmt.Errorf("namespace name and path are required")
+	}
+
+	if !e.TokenSource.Valid() {
+		return fmt.Errorf("unknown tokenSource: %q", e.TokenSource)
+	}
+
+	return e.TagPolicy.validate()
+}
+
+// validate checks the tag order and compiles the tag filters.
+func (p *TagPolicy) validate() error {
+	if p.SupportedReleases < 0 {
+		return fmt.Errorf("supportedReleases must not be negative")
+	}
+
+	switch p.TagOrder {
+	case TagOrderSemver, TagOrderRegistry:
+	case "":
+		p.TagOrder = TagOrderRegistry
+	default:
+		return fmt.Errorf("unknown tagOrder: %q", p.TagOrder)
+	}
+
+	for i := range p.TagFilters {
+		f := &p.TagFilters[i]
+		if f.Constraint == "" && f.Pattern == "" {
+			return fmt.Errorf("tag filter %d is empty", i)
+		}
+		if f.Constraint != "" {
+			constraint, err := semver.NewConstraint(f.Constraint)
+			if err != nil {
+				return fmt.Errorf("invalid tag filter constraint %q: %v", f.Constraint, err)
+			}
+			f.constraint = constraint
+		}
+		if f.Pattern != "" {
+			pattern, err := regexp.Compile(f.Pattern)
+			if err != nil {
+				return fmt.Errorf("invalid tag filter pattern %q: %v", f.Pattern, err)
+			}
+			f.pattern = pattern
+		}
+	}
+
+	return nil
+}
+
+// Lookup returns the catalog entry for the specified sensor type.
+func (c Catalog) Lookup(sensorType falcon.SensorType) (Entry, bool) {
+	for _, entry := range c.Images {
+		if entry.SensorType == sensorType {
+			return entry, true
+		}
+	}
+
+	return Entry{}, false
+}
+
+// SensorTypes returns the sensor types of the images and repositories, in catalog order.
+func (c Catalog) SensorTypes() []string {
+	sensorTypes := make([]string, 0, len(c.Images)+len(c.Repositories))
+	for _, entry := range c.Images {
+		sensorTypes = append(sensorTypes, string(entry.SensorType))
+	}
+	for _, repo := range c.Repositories {
+		sensorTypes = append(sensorTypes, string(repo.SensorType))
+	}
+
+	return sensorTypes
+}
+
+// Repository returns the full repository of the image in the registry for the specified cloud.
+func (e Entry) Repository(cloud falcon.CloudType) string {
+	segments := []string{RegistryHost(cloud), e.Namespace.Name}
+	if e.Namespace.CloudScoped {
+		segments = append(segments, registryCloud(cloud))
+	}
+	segments = append(segments, strings.Trim(e.Namespace.Path, "/"))
+
+	return strings.Join(segments, "/")
+}
+
+// Targets returns every repository of the catalog resolved for the specified cloud, in catalog order.
+func (c Catalog) Targets(cloud falcon.CloudType) []Target {
+	targets := make([]Target, 0, len(c.Images)+len(c.Repositories))
+
+	for _, entry := range c.Images {
+		targets = append(targets, Target{
+			SensorType:  string(entry.SensorType),
+			Name:        entry.Name,
+			Description: entry.Description,
+			DocsURL:     entry.DocsURL,
+			Source:      SourceCrowdStrike,
+			Repository:  entry.Repository(cloud),
+			Credentials: Credentials{
+				Source:      CredentialSourceFalcon,
+				LoginPrefix: entry.LoginPrefix,
+				TokenSource: entry.TokenSource,
+			},
+			TagPolicy: entry.TagPolicy,
+		})
+	}
+
+	for _, repo := range c.Repositories {
+		targets = append(targets, Target{
+			SensorType:  string(repo.SensorType),
+			Name:        repo.Name,
+			Description: repo.Description,
+			DocsURL:     repo.DocsURL,
+			Source:      SourceExternal,
+			Repository:  repo.Repository,
+			Credentials: repo.Credentials,
+			Insecure:    repo.Insecure,
+			TagPolicy:   repo.TagPolicy,
+		})
+	}
+
+	return targets
+}
+
+// ProcessTags filters and orders the tags according to the policy.
+func (p TagPolicy) ProcessTags(tags []string) []string {
+	if len(p.TagFilters) > 0 {
+		slog.Debug("Filtering tags", "filters", len(p.TagFilters))
+		tags = p.filterTags(tags)
+	}
+
+	if p.TagOrder == TagOrderSemver {
+		slog.Debug("Sorting semver tags")
+		tags = semverSort(tags)
+	}
+
+	return tags
+}
+
+// filterTags removes tags that do not match every tag filter of the policy.
+func (p TagPolicy) filterTags(tags []string) []string {
+	filteredTags := []string{}
+	for _, tag := range tags {
+		if p.keepTag(tag) {
+			filteredTags = append(filteredTags, tag)
+		}
+	}
+
+	return filteredTags
+}
+
+// keepTag reports whether the tag matches every tag filter of the policy.
+func (p TagPolicy) keepTag(tag string) bool {
+	for _, f := range p.TagFilters {
+		if f.pattern != nil && !f.pattern.MatchString(tag) {
+			return false
+		}
+		if f.constraint != nil {
+			versionPart := strings.Split(tag, "-")[0]
+			v, err := semver.NewVersion(versionPart)
+			if err != nil || !f.constraint.Check(v) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// semverSort sorts the tags in semver order.
+func semverSort(tags []string) []string {
+	sv := make([]*semver.Version, 0, len(tags))
+
+	for _, r := range tags {
+		v, err := semver.NewVersion(r)
+		if err != nil {
+			// Don't fail on invalid semver tags, just log and continue
+			slog.Warn("Skipping invalid semver tag", "tag", r, "error", err)
+			continue
+		}
+		sv = append(sv, v)
+	}
+
+	if len(sv) == 0 {
+		slog.Warn("No valid semver tags found", "tags", tags)
+		return tags
+	}
+
+	sort.Sort(semver.Collection(sv))
+
+	// Rebuild the sorted tag list
+	result := make([]string, len(sv))
+	for i, v := range sv {
+		result[i] = v.Original()
+	}
+
+	slog.Debug("Semver results", "tags", result)
+
+	return result
+}
+
+// RegistryHost returns the CrowdStrike registry host for the specified cloud, empty for a cloud
+// that is not resolved (autodiscover).
+func RegistryHost(cloud falcon.CloudType) string {
+	switch cloud {
+	case falcon.CloudUs1, falcon.CloudUs2, falcon.CloudEu1:
+		return "registry.crowdstrike.com"
+	case falcon.CloudUsGov1, falcon.CloudGov1:
+		return "registry.laggar.gcw.crowdstrike.com"
+	case falcon.CloudGov2:
+		return "registry.us-gov-2.crowdstrike.mil"
+	default:
+		return ""
+	}
+}
+
+// registryCloud returns the cloud segment used in cloud scoped repositories. us-gov-1 is the
+// former name of gov1 and shares its repositories.
+func registryCloud(cloud falcon.CloudType) string {
+	switch cloud {
+	case falcon.CloudUsGov1, falcon.CloudGov1:
+		return "gov1"
+	case falcon.CloudGov2:
+		return "gov2"
+	default:
+		return cloud.String()
+	}
+}
@@ -0,0 +1,196 @@
+package catalog
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/crowdstrike/gofalcon/falcon"
+)
+
+func TestLoad(t *testing.T) {
+	defaults, err := Default()
+	if err != nil {
+		t.Fatal(err)
+	}
+	operator := Repository{
+		SensorType: "falcon-operator",
+		Name:       "Falcon Operator",
+		Repository: "quay.io/crowdstrike/falcon-operator",
+	}
+
+	tests := []struct {
+		name        string
+		custom      *Catalog
+		sensorTypes func(defaults []string) []string
+		wantErr     string
+	}{
+		{name: "embedded", sensorTypes: func(defaults []string) []string { return defaults }},
+		{
+			name:   "disabled entry",
+			custom: &Catalog{Images: []Entry{{SensorType: "falcon-kac", Disabled: true}}},
+			sensorTypes: func(defaults []string) []string {
+				return slices.DeleteFunc(slices.Clone(defaults), func(s string) bool { return s == "falcon-kac" })
+			},
+		},
+		{
+			name:        "appended repository",
+			custom:      &Catalog{Repositories: []Repository{operator}},
+			sensorTypes: func(defaults []string) []string { return append(slices.Clone(defaults), "falcon-operator") },
+		},
+		{
+			name: "replaced entry",
+			custom: &Catalog{Images: []Entry{{
+				SensorType:  "falcon-kac",
+				Name:        "KAC",
+				LoginPrefix: "fc",
+				TokenSource: defaults.Images[0].TokenSource,
+				Namespace:   Namespace{Name: "falcon-kac", Path: "release/falcon-kac"},
+			}}},
+			sensorTypes: func(defaults []string) []string { return defaults },
+		},
+		{
+			name:    "invalid entry",
+			custom:  &Catalog{Images: []Entry{{SensorType: "new", Name: "New"}}},
+			wantErr: "loginPrefix is required",
+		},
+		{
+			name:    "duplicate sensor type",
+			custom:  &Catalog{Repositories: []Repository{{SensorType: "falcon-kac", Name: "KAC", Repository: "quay.io/crowdstrike/kac"}}},
+			wantErr: "duplicate catalog repository",
+		},
+		{
+			name:    "repository with a tag",
+			custom:  &Catalog{Repositories: []Repository{{SensorType: "op", Name: "Op", Repository: "quay.io/crowdstrike/falcon-operator:latest"}}},
+			wantErr: "must not contain a tag",
+		},
+		{
+			name: "static credentials without password",
+			custom: &Catalog{Repositories: []Repository{{
+				SensorType:  "op",
+				Name:        "Op",
+				Repository:  "quay.io/crowdstrike/falcon-operator",
+				Credentials: Credentials{Source: CredentialSourceStatic, Username: "robot"},
+			}}},
+			wantErr: "require a password",
+		},
+		{
+			name:    "invalid tag filter",
+			custom:  &Catalog{Repositories: []Repository{{SensorType: "op", Name: "Op", Repository: "quay.io/crowdstrike/falcon-operator", TagPolicy: TagPolicy{TagFilters: []TagFilter{{Pattern: "("}}}}}},
+			wantErr: "invalid tag filter pattern",
+		},
+		{
+			name:    "negative supported releases",
+			custom:  &Catalog{Repositories: []Repository{{SensorType: "op", Name: "Op", Repository: "quay.io/crowdstrike/falcon-operator", TagPolicy: TagPolicy{SupportedReleases: -1}}}},
+			wantErr: "supportedReleases must not be negative",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := Load(tt.custom)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+
+			if want := tt.sensorTypes(defaults.SensorTypes()); !slices.Equal(c.SensorTypes(), want) {
+				t.Errorf("SensorTypes() = %v, want %v", c.SensorTypes(), want)
+			}
+		})
+	}
+}
+
+func TestTargets(t *testing.T) {
+	c, err := Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		cloud falcon.CloudType
+		want  string
+	}{
+		{cloud: falcon.CloudUs1, want: "registry.crowdstrike.com/falcon-sensor/us-1/release/falcon-sensor"},
+		{cloud: falcon.CloudEu1, want: "registry.crowdstrike.com/falcon-sensor/eu-1/release/falcon-sensor"},
+		{cloud: falcon.CloudGov1, want: "registry.laggar.gcw.crowdstrike.com/falcon-sensor/gov1/release/falcon-sensor"},
+		{cloud: falcon.CloudUsGov1, want: "registry.laggar.gcw.crowdstrike.com/falcon-sensor/gov1/release/falcon-sensor"},
+		{cloud: falcon.CloudGov2, want: "registry.us-gov-2.crowdstrike.mil/falcon-sensor/gov2/release/falcon-sensor"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cloud.String(), func(t *testing.T) {
+			targets := c.Targets(tt.cloud)
+			if targets[0].Repository != tt.want {
+				t.Errorf("Targets()[0].Repository = %q, want %q", targets[0].Repository, tt.want)
+			}
+			if targets[0].SupportedReleases != 3 {
+				t.Errorf("Targets()[0].SupportedReleases = %d, want 3", targets[0].SupportedReleases)
+			}
+		})
+	}
+}
+
+func TestProcessTags(t *testing.T) {
+	tags := []string{"7.10.0-1", "7.2.0-1", "latest", "6.9.0-1"}
+
+	tests := []struct {
+		name   string
+		policy TagPolicy
+		want   []string
+	}{
+		{name: "registry order", policy: TagPolicy{TagOrder: TagOrderRegistry}, want: tags},
+		{name: "constraint", policy: TagPolicy{TagOrder: TagOrderRegistry, TagFilters: []TagFilter{{Constraint: ">= 7.0.0"}}}, want: []string{"7.10.0-1", "7.2.0-1"}},
+		{name: "pattern", policy: TagPolicy{TagOrder: TagOrderRegistry, TagFilters: []TagFilter{{Pattern: `^7\.`}}}, want: []string{"7.10.0-1", "7.2.0-1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := tt.policy
+			if err := policy.validate(); err != nil {
+				t.Fatal(err)
+			}
+			if got := policy.ProcessTags(slices.Clone(tags)); !slices.Equal(got, tt.want) {
+				t.Errorf("ProcessTags() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCredentialRef(t *testing.T) {
+	static := func(repository string, username string) Target {
+		return Target{Repository: repository, Credentials: Credentials{Source: CredentialSourceStatic, Username: username}}
+	}
+
+	tests := []struct {
+		name   string
+		target Target
+		want   string
+	}{
+		{
+			name:   "falcon",
+			target: Target{Repository: "registry.crowdstrike.com/falcon-container/us-1/release/falcon-sensor", Credentials: Credentials{Source: CredentialSourceFalcon, LoginPrefix: "fc", TokenSource: "falcon-container"}},
+			want:   "falcon-fc-falcon-container",
+		},
+		{name: "static", target: static("quay.io/CrowdStrike/falcon-operator", "Robot"), want: "static-quay.io-crowdstrike-falcon-operator-robot"},
+		{name: "anonymous", target: Target{Repository: "quay.io/crowdstrike/falcon-operator"}, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.target.CredentialRef(); got != tt.want {
+				t.Errorf("CredentialRef() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// The same username on two repositories of a registry can have different passwords
+	if static("quay.io/crowdstrike/a", "robot").CredentialRef() == static("quay.io/crowdstrike/b", "robot").CredentialRef() {
+		t.Error("CredentialRef() is the same for static credentials of two repositories")
+	}
+}
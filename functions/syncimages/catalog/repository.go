@@ -0,0 +1,165 @@
+package catalog
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	falconapi "syncimages/falcon"
+
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/crowdstrike/gofalcon/falcon"
+)
+
+const (
+	// SourceCrowdStrike marks images hosted in the CrowdStrike registry.
+	SourceCrowdStrike = "crowdstrike"
+	// SourceExternal marks repositories hosted in other registries.
+	SourceExternal = "external"
+)
+
+// CredentialSource identifies how the credentials of a repository are obtained.
+type CredentialSource string
+
+const (
+	// CredentialSourceAnonymous pulls without credentials.
+	CredentialSourceAnonymous CredentialSource = "anonymous"
+	// CredentialSourceStatic uses a username and password from the function config.
+	CredentialSourceStatic CredentialSource = "static"
+	// CredentialSourceFalcon uses the CID based login and a registry token from the CrowdStrike API.
+	CredentialSourceFalcon CredentialSource = "falcon"
+)
+
+// Repository describes an additional repository hosted outside of the CrowdStrike registry,
+// such as the falcon-operator image on quay.io.
+type Repository struct {
+	SensorType  falcon.SensorType `json:"sensorType"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	DocsURL     string            `json:"docsUrl,omitempty"`
+	// Repository is the full repository reference without tag, e.g. quay.io/crowdstrike/falcon-operator.
+	Repository  string      `json:"repository"`
+	Credentials Credentials `json:"credentials"`
+	// Insecure skips TLS verification and allows plain HTTP, e.g. for a local registry.
+	Insecure bool `json:"insecure,omitempty"`
+	TagPolicy
+	// Disabled removes a repository from the catalog when set in a custom catalog.
+	Disabled bool `json:"disabled,omitempty"`
+}
+
+// Credentials describes the registry credentials of a repository.
+type Credentials struct {
+	Source CredentialSource `json:"source"`
+
+	// Username and Password (or PasswordEnv, the name of an environment variable holding the
+	// password) are used by the static source.
+	Username    string `json:"username,omitempty"`
+	Password    string `json:"password,omitempty"`
+	PasswordEnv string `json:"passwordEnv,omitempty"`
+
+	// LoginPrefix and TokenSource are used by the falcon source.
+	LoginPrefix string                `json:"loginPrefix,omitempty"`
+	TokenSource falconapi.TokenSource `json:"tokenSource,omitempty"`
+}
+
+// Target is a repository resolved for a cloud, ready to be synced.
+type Target struct {
+	SensorType  string
+	Name        string
+	Description string
+	DocsURL     string
+	Source      string
+	Repository  string
+	Credentials Credentials
+	Insecure    bool
+	TagPolicy
+}
+
+// CredentialRef returns the key of the target's registry credential in the credentials
+// collection, e.g. falcon-fc-falcon-container. Targets sharing a login and token share the
+// reference. Static credentials are scoped to the repository, as the same username can have
+// another password for another repository of the registry. Anonymous targets have no reference.
+func (t Target) CredentialRef() string {
+	var parts []string
+	switch t.Credentials.Source {
+	case CredentialSourceFalcon:
+		parts = []string{string(t.Credentials.Source), t.Credentials.LoginPrefix, string(t.Credentials.TokenSource)}
+	case CredentialSourceStatic:
+		parts = []string{string(t.Credentials.Source), t.Repository, t.Credentials.Username}
+	default:
+		return ""
+	}
+
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '.', r == '_':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		default:
+			return '-'
+		}
+	}, strings.Join(parts, "-"))
+}
+
+// StaticPassword returns the password of the static credential source.
+func (c Credentials) StaticPassword() string {
+	if c.PasswordEnv != "" {
+		return os.Getenv(c.PasswordEnv)
+	}
+
+	return c.Password
+}
+
+// validate checks a single repository and compiles its tag filters.
+func (r *Repository) validate() error {
+	switch {
+	case r.SensorType == "":
+		return fmt.Errorf("sensorType is required")
+	case r.Name == "":
+		return fmt.Errorf("name is required")
+	case r.Repository == "":
+		return fmt.Errorf("repository is required")
+	}
+
+	ref, err := reference.ParseNormalizedNamed(r.Repository)
+	if err != nil {
+		return fmt.Errorf("invalid repository %q: %v", r.Repository, err)
+	}
+	if !reference.IsNameOnly(ref) {
+		return fmt.Errorf("repository %q must not contain a tag or digest", r.Repository)
+	}
+
+	if err := r.Credentials.validate(); err != nil {
+		return err
+	}
+
+	return r.TagPolicy.validate()
+}
+
+// validate checks that the fields required by the credential source are set.
+func (c *Credentials) validate() error {
+	switch c.Source {
+	case CredentialSourceAnonymous:
+	case "":
+		c.Source = CredentialSourceAnonymous
+	case CredentialSourceStatic:
+		if c.Username == "" {
+			return fmt.Errorf("static credentials require a username")
+		}
+		if c.Password == "" && c.PasswordEnv == "" {
+			return fmt.Errorf("static credentials require a password or passwordEnv")
+		}
+	case CredentialSourceFalcon:
+		if c.LoginPrefix == "" {
+			return fmt.Errorf("falcon credentials require a loginPrefix")
+		}
+		if !c.TokenSource.Valid() {
+			return fmt.Errorf("unknown tokenSource: %q", c.TokenSource)
+		}
+	default:
+		return fmt.Errorf("unknown credentials source: %q", c.Source)
+	}
+
+	return nil
+}
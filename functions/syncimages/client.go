@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"syncimages/catalog"
+	"syncimages/config"
+	falconapi "syncimages/falcon"
+	"syncimages/version"
+
+	fdk "github.com/CrowdStrike/foundry-fn-go"
+	"github.com/crowdstrike/gofalcon/falcon"
+	"github.com/crowdstrike/gofalcon/falcon/client"
+)
+
+// newFalconClient creates a new Falcon client and returns the cloud it resolved, e.g. us-2 when
+// the cloud is autodiscovered. The cloud is not verified against the API client, see verifyCloud.
+func newFalconClient(token string) (*client.CrowdStrikeAPISpecification, string, error) {
+	ctx := context.Background()
+	userAgent := falconUserAgent()
+
+	configured, err := configuredCloud(token)
+	if err != nil {
+		return nil, configured.String(), err
+	}
+
+	apiConfig := &falcon.ApiConfig{
+		AccessToken:       token,
+		Cloud:             configured,
+		Context:           ctx,
+		UserAgentOverride: userAgent,
+	}
+
+	if apiConfig.AccessToken == "" {
+		apiConfig.ClientId = os.Getenv("FALCON_CLIENT_ID")
+		apiConfig.ClientSecret = os.Getenv("FALCON_CLIENT_SECRET")
+	}
+
+	// Only log which credentials are used, never their values
+	slog.Debug("Creating Falcon client", "client_id", apiConfig.ClientId, "access_token_set", apiConfig.AccessToken != "", "cloud", configured, "user_agent", userAgent)
+
+	client, err := falcon.NewClient(apiConfig)
+	if err != nil {
+		if configured == falcon.CloudAutoDiscover {
+			// GovCloud API clients are unknown to the commercial API autodiscovery asks
+			return nil, configured.String(), fmt.Errorf("error autodiscovering the Falcon cloud, set FALCON_CLOUD (e.g. us-gov-1, gov1 or gov2 for GovCloud): %v", err)
+		}
+		return nil, configured.String(), err
+	}
+
+	// With autodiscover, NewClient resolves the cloud from the token of the API client and
+	// updates the config, so the cloud is only known now.
+	resolved := apiConfig.Cloud
+	if catalog.RegistryHost(resolved) == "" {
+		return nil, resolved.String(), fmt.Errorf("could not resolve the Falcon cloud %q, set FALCON_CLOUD", configured)
+	}
+	if configured == falcon.CloudAutoDiscover {
+		slog.Debug("Autodiscovered Falcon cloud", "cloud", resolved, "registry_host", catalog.RegistryHost(resolved))
+	}
+
+	return client, resolved.String(), nil
+}
+
+// configuredCloud returns the cloud set in FALCON_CLOUD, or by the function runtime in CS_CLOUD.
+// An access token does not tell its cloud, so autodiscover falls back to CS_CLOUD with an access
+// token, and fails when it is not set.
+func configuredCloud(token string) (falcon.CloudType, error) {
+	cloud := fdk.FalconClientOpts().Cloud
+	if os.Getenv("FALCON_CLOUD") != "" {
+		cloud = os.Getenv("FALCON_CLOUD")
+	}
+
+	configured, err := falconapi.ParseCloud(cloud)
+	if err != nil || configured != falcon.CloudAutoDiscover || token == "" {
+		return configured, err
+	}
+
+	if runtimeCloud := os.Getenv("CS_CLOUD"); runtimeCloud != "" {
+		if fallback, err := falconapi.ParseCloud(runtimeCloud); err == nil && fallback != falcon.CloudAutoDiscover {
+			slog.Debug("Using the cloud of the function runtime, the cloud cannot be autodiscovered with an access token", "cloud", fallback)
+			return fallback, nil
+		}
+	}
+
+	return configured, fmt.Errorf("the Falcon cloud cannot be autodiscovered with an access token, set FALCON_CLOUD or CS_CLOUD")
+}
+
+// verifyCloud checks that the API client belongs to the resolved cloud, so a wrong FALCON_CLOUD
+// fails with the cloud to use instead of authentication errors. It calls the OAuth2 API, so it
+// only runs before syncing and diagnosing. Autodiscovered clouds are right by construction, and
+// access tokens cannot be verified.
+func verifyCloud(ctx context.Context, token string, cloud string) error {
+	configured, err := configuredCloud(token)
+	if err != nil || configured == falcon.CloudAutoDiscover || token != "" {
+		return err
+	}
+
+	return falconapi.VerifyCloud(ctx, falcon.Cloud(cloud), os.Getenv("FALCON_CLIENT_ID"), os.Getenv("FALCON_CLIENT_SECRET"))
+}
+
+// newMemberClient creates a Falcon client acting on the MSSP member CID in the cloud of the app.
+// The access token of the app only covers its own CID, so member clients authenticate with the
+// client credentials of a parent API client.
+func newMemberClient(cloud string, memberCID string, members config.Members) (*client.CrowdStrikeAPISpecification, error) {
+	idEnv, secretEnv := members.ClientEnv()
+	clientID, clientSecret := os.Getenv(idEnv), os.Getenv(secretEnv)
+	if clientID == "" || clientSecret == "" {
+		return nil, fmt.Errorf("syncing member CID %s requires the client credentials of a parent API client in %s and %s", memberCID, idEnv, secretEnv)
+	}
+
+	slog.Debug("Creating Falcon member client", "client_id", clientID, "member_cid", memberCID, "cloud", cloud)
+
+	return falcon.NewClient(&falcon.ApiConfig{
+		ClientId:          clientID,
+		ClientSecret:      clientSecret,
+		MemberCID:         memberCID,
+		Cloud:             falcon.Cloud(cloud),
+		Context:           context.Background(),
+		UserAgentOverride: falconUserAgent(),
+	})
+}
+
+// falconUserAgent returns the user agent of the Falcon clients.
+func falconUserAgent() string {
+	return fmt.Sprintf("%s foundry-container-registry/%s", fdk.FalconClientOpts().UserAgent, version.Current())
+}
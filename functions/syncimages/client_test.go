@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/crowdstrike/gofalcon/falcon"
+)
+
+func TestConfiguredCloud(t *testing.T) {
+	tests := []struct {
+		name        string
+		falconCloud string
+		csCloud     string
+		token       string
+		want        falcon.CloudType
+		failed      bool
+	}{
+		{name: "runtime default", want: falcon.CloudUs1},
+		{name: "runtime cloud", csCloud: "eu-1", want: falcon.CloudEu1},
+		{name: "falcon cloud", falconCloud: "us-2", csCloud: "eu-1", want: falcon.CloudUs2},
+		{name: "autodiscover with client credentials", falconCloud: "autodiscover", csCloud: "eu-1", want: falcon.CloudAutoDiscover},
+		{name: "autodiscover with access token", falconCloud: "autodiscover", csCloud: "eu-1", token: "token", want: falcon.CloudEu1},
+		{name: "autodiscover with access token without runtime cloud", falconCloud: "autodiscover", token: "token", failed: true},
+		{name: "unknown cloud", falconCloud: "mars-1", failed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("FALCON_CLOUD", tt.falconCloud)
+			t.Setenv("CS_CLOUD", tt.csCloud)
+
+			got, err := configuredCloud(tt.token)
+			if (err != nil) != tt.failed {
+				t.Fatalf("configuredCloud() error = %v, want failed %v", err, tt.failed)
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("configuredCloud() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyCloudSkipped(t *testing.T) {
+	tests := []struct {
+		name        string
+		falconCloud string
+		token       string
+	}{
+		// Neither calls the OAuth2 API
+		{name: "access token", falconCloud: "eu-1", token: "token"},
+		{name: "autodiscovered", falconCloud: "autodiscover"},
+		{name: "govcloud", falconCloud: "gov1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("FALCON_CLOUD", tt.falconCloud)
+			t.Setenv("CS_CLOUD", "")
+
+			if err := verifyCloud(context.Background(), tt.token, tt.falconCloud); err != nil {
+				t.Errorf("verifyCloud() error = %v", err)
+			}
+		})
+	}
+}
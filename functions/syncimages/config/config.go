@@ -0,0 +1,202 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"syncimages/catalog"
+	"syncimages/encryption"
+	falconapi "syncimages/falcon"
+	"syncimages/webhook"
+)
+
+// Config holds the function configuration.
+type Config struct {
+	// Catalog overrides or extends the embedded image catalog.
+	Catalog *catalog.Catalog `json:"catalog,omitempty"`
+	// Discovery reports registry repositories that are not covered by the catalog.
+	Discovery Discovery `json:"discovery"`
+	// Sync configures the sync.
+	Sync Sync `json:"sync"`
+	// Webhooks are called with the changes found by every sync.
+	Webhooks []webhook.Webhook `json:"webhooks,omitempty"`
+	// Encryption encrypts the stored registry credentials.
+	Encryption encryption.Config `json:"encryption"`
+	// Members are the MSSP member CIDs synced by a parent CID.
+	Members Members `json:"members"`
+
+	// catalog is the image catalog loaded by OK.
+	catalog catalog.Catalog
+}
+
+// Members configures the member CIDs an MSSP (Flight Control) parent CID syncs. The images of
+// every member are synced with an API client acting on the member and stored separately.
+type Members struct {
+	// CIDs are the member CIDs synced by requests with allMembers. Other requests sync the CID
+	// of the app, or the member CIDs they name.
+	CIDs []string `json:"cids,omitempty"`
+	// ClientIDEnv and ClientSecretEnv name the environment variables holding the client ID and
+	// secret of a parent API client with access to the members, FALCON_CLIENT_ID and
+	// FALCON_CLIENT_SECRET when unset. The access token of the app only covers its own CID.
+	ClientIDEnv     string `json:"clientIdEnv,omitempty"`
+	ClientSecretEnv string `json:"clientSecretEnv,omitempty"`
+}
+
+// Default environment variables of the parent API client of the member CIDs.
+const (
+	defaultClientIDEnv     = "FALCON_CLIENT_ID"
+	defaultClientSecretEnv = "FALCON_CLIENT_SECRET"
+)
+
+// Validate checks and lowercases the member CIDs.
+func (m *Members) Validate() error {
+	seen := map[string]bool{}
+	for i, value := range m.CIDs {
+		cid, err := falconapi.ParseCID(value)
+		if err != nil {
+			return fmt.Errorf("members: %v", err)
+		}
+		if seen[cid] {
+			return fmt.Errorf("members: duplicate CID %q", value)
+		}
+		seen[cid] = true
+		m.CIDs[i] = cid
+	}
+
+	return nil
+}
+
+// ClientEnv returns the names of the environment variables holding the parent API client.
+func (m Members) ClientEnv() (string, string) {
+	idEnv, secretEnv := m.ClientIDEnv, m.ClientSecretEnv
+	if idEnv == "" {
+		idEnv = defaultClientIDEnv
+	}
+	if secretEnv == "" {
+		secretEnv = defaultClientSecretEnv
+	}
+
+	return idEnv, secretEnv
+}
+
+// Sync configures the sync.
+type Sync struct {
+	// MinIntervalSeconds returns the stored images instead of syncing when they were updated
+	// more recently, unless the sync is forced. 0 always syncs.
+	MinIntervalSeconds int `json:"minIntervalSeconds,omitempty"`
+	// RunRetention is the number of runs kept in the sync_runs collection, 50 when unset.
+	RunRetention int `json:"runRetention,omitempty"`
+	// ChangeRetention is the number of change sets kept in the changes collection, 500 when unset.
+	ChangeRetention int `json:"changeRetention,omitempty"`
+	// JobRetention is the number of asynchronous sync jobs kept in the sync_jobs collection, 50
+	// when unset.
+	JobRetention int `json:"jobRetention,omitempty"`
+	// SkipCredentials does not store the registry credentials in the credentials collection,
+	// and deletes the stored ones. Consumers then obtain their own registry credentials.
+	SkipCredentials bool `json:"skipCredentials,omitempty"`
+	// CredentialRenewalSeconds renews the stored credentials expiring within this time that were
+	// not synced, 86400 (one day) when unset.
+	CredentialRenewalSeconds int `json:"credentialRenewalSeconds,omitempty"`
+	// EntitlementRecheckSeconds is how long sensor types the tenant is not entitled to are
+	// skipped before the sync checks them again, 86400 (one day) when unset. Forced syncs always
+	// check them.
+	EntitlementRecheckSeconds int `json:"entitlementRecheckSeconds,omitempty"`
+	// UnmaskCID stores the full CID in the metadata of the image list instead of a masked one.
+	UnmaskCID bool `json:"unmaskCid,omitempty"`
+}
+
+// Default retentions of the sync history.
+const (
+	defaultRunRetention    = 50
+	defaultChangeRetention = 500
+	defaultJobRetention    = 50
+)
+
+// Default intervals of the credential and entitlement checks.
+const (
+	defaultCredentialRenewal  = 24 * time.Hour
+	defaultEntitlementRecheck = 24 * time.Hour
+)
+
+// RunsKept returns the number of runs kept in the sync_runs collection.
+func (s Sync) RunsKept() int {
+	if s.RunRetention <= 0 {
+		return defaultRunRetention
+	}
+
+	return s.RunRetention
+}
+
+// ChangesKept returns the number of change sets kept in the changes collection.
+func (s Sync) ChangesKept() int {
+	if s.ChangeRetention <= 0 {
+		return defaultChangeRetention
+	}
+
+	return s.ChangeRetention
+}
+
+// JobsKept returns the number of jobs kept in the sync_jobs collection.
+func (s Sync) JobsKept() int {
+	if s.JobRetention <= 0 {
+		return defaultJobRetention
+	}
+
+	return s.JobRetention
+}
+
+// CredentialRenewal returns how long before their expiry the stored credentials are renewed.
+func (s Sync) CredentialRenewal() time.Duration {
+	if s.CredentialRenewalSeconds <= 0 {
+		return defaultCredentialRenewal
+	}
+
+	return time.Duration(s.CredentialRenewalSeconds) * time.Second
+}
+
+// EntitlementRecheck returns how long sensor types the tenant is not entitled to are skipped.
+func (s Sync) EntitlementRecheck() time.Duration {
+	if s.EntitlementRecheckSeconds <= 0 {
+		return defaultEntitlementRecheck
+	}
+
+	return time.Duration(s.EntitlementRecheckSeconds) * time.Second
+}
+
+// MinInterval returns the minimum interval between two syncs.
+func (s Sync) MinInterval() time.Duration {
+	return time.Duration(s.MinIntervalSeconds) * time.Second
+}
+
+// Discovery configures the registry catalog discovery step of the sync.
+type Discovery struct {
+	Enabled bool `json:"enabled"`
+	// Prefixes restricts discovery to repository paths starting with one of the prefixes
+	// (e.g. "falcon-sensor/"). Defaults to the namespaces of the catalog. Registries without a
+	// _catalog endpoint are only walked by the namespaces of configured prefixes.
+	Prefixes []string `json:"prefixes,omitempty"`
+}
+
+// OK validates the config when fdk.Run loads it: it loads the image catalog, compiles the
+// webhook filters, and checks the encryption keys and the member CIDs.
+func (c *Config) OK() error {
+	cat, err := catalog.Load(c.Catalog)
+	if err != nil {
+		return err
+	}
+	c.catalog = cat
+
+	if err := webhook.Validate(c.Webhooks); err != nil {
+		return err
+	}
+	if err := c.Encryption.Validate(); err != nil {
+		return err
+	}
+
+	return c.Members.Validate()
+}
+
+// ImageCatalog returns the embedded catalog merged with the custom catalog, loaded by OK.
+func (c Config) ImageCatalog() catalog.Catalog {
+	return c.catalog
+}
@@ -0,0 +1,116 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"syncimages/catalog"
+)
+
+func TestConfigOK(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr bool
+	}{
+		{name: "default", config: `{}`},
+		{name: "custom catalog", config: `{"catalog": {"images": [{"sensorType": "falcon-kac", "disabled": true}]}}`},
+		{name: "invalid catalog", config: `{"catalog": {"repositories": [{"sensorType": "operator", "name": "Operator"}]}}`, wantErr: true},
+		{name: "invalid webhook", config: `{"webhooks": [{"name": "hook"}]}`, wantErr: true},
+		{name: "invalid member CID", config: `{"members": {"cids": ["not-a-cid"]}}`, wantErr: true},
+		{name: "invalid encryption key", config: `{"encryption": {"keys": [{"id": "k1", "key": "c2hvcnQ="}]}}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg Config
+			if err := json.Unmarshal([]byte(tt.config), &cfg); err != nil {
+				t.Fatal(err)
+			}
+
+			err := cfg.OK()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("OK() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && len(cfg.ImageCatalog().Images) == 0 {
+				t.Errorf("ImageCatalog() has no images")
+			}
+		})
+	}
+}
+
+func TestConfigOKCatalog(t *testing.T) {
+	cfg := Config{Catalog: &catalog.Catalog{Images: []catalog.Entry{{SensorType: "falcon-kac", Disabled: true}}}}
+	if err := cfg.OK(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := cfg.ImageCatalog().Lookup("falcon-kac"); ok {
+		t.Errorf("ImageCatalog() contains the disabled falcon-kac image")
+	}
+}
+
+func TestLoader(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{name: "unset", path: "", want: `{}`},
+		{name: "missing", path: filepath.Join(dir, "missing.json"), want: `{}`},
+		{name: "json", path: write("config.json", `{"sync":{"unmaskCid":true}}`), want: `{"sync":{"unmaskCid":true}}`},
+		{name: "yaml", path: write("config.yaml", "sync:\n  unmaskCid: true\n"), want: `{"sync":{"unmaskCid":true}}`},
+		{name: "invalid yaml", path: write("invalid.yml", "sync: [\n"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CS_FN_CONFIG_PATH", tt.path)
+
+			got, err := loader{}.LoadConfig(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadConfig() error = %v, want error %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Errorf("LoadConfig() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetention(t *testing.T) {
+	tests := []struct {
+		name    string
+		sync    Sync
+		runs    int
+		changes int
+	}{
+		{name: "defaults", runs: defaultRunRetention, changes: defaultChangeRetention},
+		{name: "negative", sync: Sync{RunRetention: -1, ChangeRetention: -1}, runs: defaultRunRetention, changes: defaultChangeRetention},
+		{name: "configured", sync: Sync{RunRetention: 5, ChangeRetention: 10}, runs: 5, changes: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.sync.RunsKept(); got != tt.runs {
+				t.Errorf("RunsKept() = %d, want %d", got, tt.runs)
+			}
+			if got := tt.sync.ChangesKept(); got != tt.changes {
+				t.Errorf("ChangesKept() = %d, want %d", got, tt.changes)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	fdk "github.com/CrowdStrike/foundry-fn-go"
+	"gopkg.in/yaml.v3"
+)
+
+// LoaderType is the fdk config loader of the function, used unless CS_CONFIG_LOADER_TYPE selects
+// another one.
+const LoaderType = "optional-fs"
+
+// RegisterLoader registers the config loader of the function with fdk and selects it when
+// CS_CONFIG_LOADER_TYPE is unset.
+func RegisterLoader() error {
+	fdk.RegisterConfigLoader(LoaderType, loader{})
+	if os.Getenv("CS_CONFIG_LOADER_TYPE") != "" {
+		return nil
+	}
+
+	return os.Setenv("CS_CONFIG_LOADER_TYPE", LoaderType)
+}
+
+// loader reads the JSON or YAML file referenced by CS_FN_CONFIG_PATH like the default fdk loader,
+// except that a missing file is the default configuration: the function is deployed without a
+// config (see manifest.yml).
+type loader struct{}
+
+// LoadConfig returns the config as JSON.
+func (loader) LoadConfig(_ context.Context) ([]byte, error) {
+	path := os.Getenv("CS_FN_CONFIG_PATH")
+	if path == "" {
+		return []byte("{}"), nil
+	}
+
+	b, err := os.ReadFile(path) // #nosec G304 -- path is provided by the function runtime
+	if os.IsNotExist(err) {
+		return []byte("{}"), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading function config: %v", err)
+	}
+
+	if ext := filepath.Ext(path); ext == ".yaml" || ext == ".yml" {
+		var out map[string]any
+		if err := yaml.Unmarshal(b, &out); err != nil {
+			return nil, fmt.Errorf("error parsing function config: %v", err)
+		}
+		return json.Marshal(out)
+	}
+
+	return b, nil
+}
@@ -0,0 +1,284 @@
+package credentials
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"syncimages/encryption"
+	"syncimages/images"
+)
+
+// Collection is the collection storing the registry credentials of the synced images, keyed by
+// the credential reference of the images. Unlike the images collection, it is only readable with
+// the registry-credentials permission.
+const Collection = "credentials"
+
+// Credential is the document stored in the credentials collection.
+type Credential struct {
+	Ref      string `json:"ref"`
+	Registry string `json:"registry"`
+	Login    string `json:"login"`
+	Password string `json:"password"`
+	// DockerAuthConfig is the base64 encoded docker config.json of the registry.
+	DockerAuthConfig string `json:"dockerAuthConfig"`
+	// SensorTypes are the synced images using the credential.
+	SensorTypes []string  `json:"sensorTypes"`
+	Updated     time.Time `json:"updated"`
+	// Issued is when the login and password were issued: the issued at claim of a JWT token,
+	// when the sync first obtained them otherwise.
+	Issued time.Time `json:"issued"`
+	// Verified is when the sync last pulled from the registry with the credential.
+	Verified time.Time `json:"verified"`
+	// Expires is the expiry claim of a JWT token.
+	Expires *time.Time `json:"expires,omitempty"`
+	// Encryption is the wrapped data key of an encrypted credential, whose Password and
+	// DockerAuthConfig are then ciphertexts.
+	Encryption *encryption.Envelope `json:"encryption,omitempty"`
+	// Fingerprint identifies the login and password of an encrypted credential, so the sync
+	// detects new credentials without decrypting the stored ones.
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+// memberPrefix prefixes the references of the credentials of MSSP member CIDs. The references of
+// the catalog never start with it.
+const memberPrefix = "member-"
+
+// MemberRef returns the reference of the credential of the member CID, whose logins and tokens
+// differ from those of the CID of the app. The reference is unchanged for the CID of the app and
+// for images pulled anonymously.
+func MemberRef(memberCID string, ref string) string {
+	if memberCID == "" || ref == "" {
+		return ref
+	}
+
+	return memberPrefix + memberCID + "-" + ref
+}
+
+// Owned reports whether the credential reference belongs to the member CID, or to the CID of the
+// app when the member CID is empty.
+func Owned(memberCID string, ref string) bool {
+	if memberCID == "" {
+		return !strings.HasPrefix(ref, memberPrefix)
+	}
+
+	return strings.HasPrefix(ref, memberPrefix+memberCID+"-")
+}
+
+// FromImages returns the credentials of the images, one per credential reference in image order,
+// verified by the sync of the images. Images without a reference pull anonymously and have no
+// credential.
+func FromImages(imgs []images.Image, updated time.Time) []Credential {
+	creds := []Credential{}
+	index := map[string]int{}
+	for _, img := range imgs {
+		if img.CredentialRef == "" {
+			continue
+		}
+
+		if i, ok := index[img.CredentialRef]; ok {
+			creds[i].SensorTypes = append(creds[i].SensorTypes, img.SensorType)
+			continue
+		}
+
+		cred := Credential{
+			Ref:              img.CredentialRef,
+			Registry:         img.Registry,
+			Login:            img.Login,
+			Password:         img.Password,
+			DockerAuthConfig: img.DockerJson,
+			SensorTypes:      []string{img.SensorType},
+			Updated:          updated,
+			Issued:           updated,
+			Verified:         updated,
+		}
+		if issued, expires, ok := tokenTimes(img.Password); ok {
+			cred.Expires = &expires
+			if !issued.IsZero() {
+				cred.Issued = issued
+			}
+		}
+
+		index[img.CredentialRef] = len(creds)
+		creds = append(creds, cred)
+	}
+
+	return creds
+}
+
+// KeepSensorTypes adds the sensor types of the stored credentials to the credentials of the same
+// reference, so a sync of a subset of the sensor types does not drop the images it did not sync
+// from a shared credential. The synced sensor types are taken from the credentials as they are,
+// and the sensor types no longer in the catalog are dropped. The sensor types follow the order of
+// the catalog.
+func KeepSensorTypes(previous map[string]Credential, creds []Credential, synced []string, sensorTypes []string) {
+	skip := map[string]bool{}
+	for _, sensorType := range synced {
+		skip[sensorType] = true
+	}
+
+	for i := range creds {
+		cred := &creds[i]
+		using := map[string]bool{}
+		for _, sensorType := range cred.SensorTypes {
+			using[sensorType] = true
+		}
+		for _, sensorType := range previous[cred.Ref].SensorTypes {
+			if !skip[sensorType] {
+				using[sensorType] = true
+			}
+		}
+
+		cred.SensorTypes = []string{}
+		for _, sensorType := range sensorTypes {
+			if using[sensorType] {
+				cred.SensorTypes = append(cred.SensorTypes, sensorType)
+			}
+		}
+	}
+}
+
+// Changes returns a credentialsChanged change for every image whose login or password differs
+// from the stored credential. Credentials that were not stored before have no changes.
+func Changes(keys encryption.Config, previous map[string]Credential, imgs []images.Image, updated time.Time) ([]images.Change, error) {
+	changes := []images.Change{}
+	for _, img := range imgs {
+		before, ok := previous[img.CredentialRef]
+		if !ok {
+			continue
+		}
+		same, err := before.matches(keys, img.Login, img.Password)
+		if err != nil {
+			return nil, err
+		}
+		if same {
+			continue
+		}
+
+		changes = append(changes, images.Change{
+			Type:       images.ChangeCredentials,
+			SensorType: img.SensorType,
+			Repository: img.Repository,
+			Time:       updated,
+		})
+	}
+
+	return changes, nil
+}
+
+// matches reports whether the credential has the login and password. Encrypted credentials are
+// compared by fingerprint. A credential encrypted with a key that is not configured anymore is
+// stale and never matches, so it is issued again.
+func (c Credential) matches(keys encryption.Config, login string, password string) (bool, error) {
+	if c.Encryption == nil {
+		return c.Login == login && c.Password == password, nil
+	}
+
+	dataKey, err := keys.Unwrap(*c.Encryption)
+	if errors.Is(err, encryption.ErrUnknownKey) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("credential %q: %w", c.Ref, err)
+	}
+
+	return c.Login == login && c.Fingerprint == encryption.Fingerprint(dataKey, login, password), nil
+}
+
+// Encrypt returns the credential with its Password and DockerAuthConfig encrypted by a new data
+// key wrapped by the active key.
+func (c Credential) Encrypt(keys encryption.Config) (Credential, error) {
+	dataKey, env, err := keys.NewDataKey()
+	if err != nil {
+		return Credential{}, err
+	}
+
+	password, err := encryption.Seal(dataKey, c.Password, c.Ref+"/password")
+	if err != nil {
+		return Credential{}, fmt.Errorf("error encrypting credential %q: %v", c.Ref, err)
+	}
+	dockerAuthConfig, err := encryption.Seal(dataKey, c.DockerAuthConfig, c.Ref+"/dockerAuthConfig")
+	if err != nil {
+		return Credential{}, fmt.Errorf("error encrypting credential %q: %v", c.Ref, err)
+	}
+
+	c.Fingerprint = encryption.Fingerprint(dataKey, c.Login, c.Password)
+	c.Password = password
+	c.DockerAuthConfig = dockerAuthConfig
+	c.Encryption = &env
+
+	return c, nil
+}
+
+// Stale returns the stored credentials that were not synced and are encrypted with a key that is
+// not configured anymore. They cannot be decrypted and must be issued again.
+func Stale(keys encryption.Config, stored map[string]Credential, synced []Credential) []Credential {
+	skip := map[string]bool{}
+	for _, cred := range synced {
+		skip[cred.Ref] = true
+	}
+
+	stale := []Credential{}
+	for _, cred := range stored {
+		if skip[cred.Ref] || cred.Encryption == nil {
+			continue
+		}
+		if _, err := keys.Unwrap(*cred.Encryption); errors.Is(err, encryption.ErrUnknownKey) {
+			stale = append(stale, cred)
+		}
+	}
+
+	return stale
+}
+
+// Decrypt returns the plaintext credential. It is only used to serve the credential.
+func (c Credential) Decrypt(keys encryption.Config) (Credential, error) {
+	if c.Encryption == nil {
+		return c, nil
+	}
+
+	dataKey, err := keys.Unwrap(*c.Encryption)
+	if err != nil {
+		return Credential{}, fmt.Errorf("credential %q: %w", c.Ref, err)
+	}
+
+	password, err := encryption.Open(dataKey, c.Password, c.Ref+"/password")
+	if err != nil {
+		return Credential{}, fmt.Errorf("error decrypting credential %q: %v", c.Ref, err)
+	}
+	dockerAuthConfig, err := encryption.Open(dataKey, c.DockerAuthConfig, c.Ref+"/dockerAuthConfig")
+	if err != nil {
+		return Credential{}, fmt.Errorf("error decrypting credential %q: %v", c.Ref, err)
+	}
+
+	c.Password = password
+	c.DockerAuthConfig = dockerAuthConfig
+	c.Encryption = nil
+	c.Fingerprint = ""
+
+	return c, nil
+}
+
+// Rotate returns the credential encrypted with the active key and whether it changed: the data
+// key of a credential wrapped by another key is re-wrapped, without decrypting the credential,
+// and a plaintext credential is encrypted.
+func (c Credential) Rotate(keys encryption.Config) (Credential, bool, error) {
+	switch {
+	case !keys.Enabled():
+		return c, false, nil
+	case c.Encryption == nil:
+		encrypted, err := c.Encrypt(keys)
+		return encrypted, err == nil, err
+	case c.Encryption.KeyID == keys.ActiveKey:
+		return c, false, nil
+	}
+
+	env, err := keys.Rewrap(*c.Encryption)
+	if err != nil {
+		return Credential{}, false, fmt.Errorf("credential %q: %w", c.Ref, err)
+	}
+	c.Encryption = &env
+
+	return c, true, nil
+}
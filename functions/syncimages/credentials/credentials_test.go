@@ -0,0 +1,292 @@
+package credentials
+
+import (
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"syncimages/encryption"
+	"syncimages/images"
+)
+
+// testKeys returns a validated config with the keys, the first one being active. The key of an ID
+// is always the same.
+func testKeys(t *testing.T, ids ...string) encryption.Config {
+	t.Helper()
+	c := encryption.Config{ActiveKey: ids[0]}
+	for _, id := range ids {
+		c.Keys = append(c.Keys, encryption.Key{ID: id, Key: base64.StdEncoding.EncodeToString([]byte(strings.Repeat(id, 16)))})
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+func TestMemberRef(t *testing.T) {
+	tests := []struct {
+		name      string
+		memberCID string
+		ref       string
+		want      string
+	}{
+		{name: "app", ref: "falcon-sensor", want: "falcon-sensor"},
+		{name: "member", memberCID: "0123456789abcdef0123456789abcdef-ab", ref: "falcon-sensor", want: "member-0123456789abcdef0123456789abcdef-ab-falcon-sensor"},
+		{name: "anonymous member image", memberCID: "0123456789abcdef0123456789abcdef-ab"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MemberRef(tt.memberCID, tt.ref); got != tt.want {
+				t.Errorf("MemberRef() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOwned(t *testing.T) {
+	member := "0123456789abcdef0123456789abcdef-ab"
+	other := "fedcba9876543210fedcba9876543210-cd"
+
+	tests := []struct {
+		name      string
+		memberCID string
+		ref       string
+		want      bool
+	}{
+		{name: "app credential", ref: "falcon-sensor", want: true},
+		{name: "member credential read by the app", ref: MemberRef(member, "falcon-sensor")},
+		{name: "member credential", memberCID: member, ref: MemberRef(member, "falcon-sensor"), want: true},
+		{name: "app credential read by a member", memberCID: member, ref: "falcon-sensor"},
+		{name: "other member credential", memberCID: member, ref: MemberRef(other, "falcon-sensor")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Owned(tt.memberCID, tt.ref); got != tt.want {
+				t.Errorf("Owned(%q, %q) = %v, want %v", tt.memberCID, tt.ref, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	keys := testKeys(t, "k1")
+	cred := Credential{Ref: "falcon-sensor", Login: "login", Password: "password", DockerAuthConfig: "config"}
+
+	encrypted, err := cred.Encrypt(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if encrypted.Password == cred.Password || encrypted.DockerAuthConfig == cred.DockerAuthConfig || encrypted.Fingerprint == "" || encrypted.Encryption == nil {
+		t.Fatalf("Encrypt() = %+v, want the secrets encrypted", encrypted)
+	}
+
+	decrypted, err := encrypted.Decrypt(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decrypted.Password != cred.Password || decrypted.DockerAuthConfig != cred.DockerAuthConfig || decrypted.Encryption != nil || decrypted.Fingerprint != "" {
+		t.Errorf("Decrypt() = %+v, want %+v", decrypted, cred)
+	}
+
+	// The ciphertexts are bound to the reference
+	moved := encrypted
+	moved.Ref = "falcon-kac"
+	if _, err := moved.Decrypt(keys); err == nil {
+		t.Error("Decrypt() of a credential with another reference succeeded")
+	}
+
+	if _, err := encrypted.Decrypt(testKeys(t, "k2")); !errors.Is(err, encryption.ErrUnknownKey) {
+		t.Errorf("Decrypt() with a removed key error = %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	old := testKeys(t, "k1")
+	encrypted, err := Credential{Ref: "falcon-sensor", Login: "login", Password: "password"}.Encrypt(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := Credential{Ref: "falcon-sensor", Login: "login", Password: "password"}
+
+	tests := []struct {
+		name    string
+		cred    Credential
+		keys    encryption.Config
+		changed bool
+		keyID   string
+		err     error
+	}{
+		{name: "disabled", cred: plain, keys: encryption.Config{}},
+		{name: "plaintext", cred: plain, keys: old, changed: true, keyID: "k1"},
+		{name: "active key", cred: encrypted, keys: old, keyID: "k1"},
+		{name: "rotated key", cred: encrypted, keys: testKeys(t, "k2", "k1"), changed: true, keyID: "k2"},
+		{name: "removed key", cred: encrypted, keys: testKeys(t, "k2"), err: encryption.ErrUnknownKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, changed, err := tt.cred.Rotate(tt.keys)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Rotate() error = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if changed != tt.changed {
+				t.Errorf("Rotate() changed = %v, want %v", changed, tt.changed)
+			}
+			keyID := ""
+			if got.Encryption != nil {
+				keyID = got.Encryption.KeyID
+			}
+			if keyID != tt.keyID {
+				t.Errorf("Rotate() key ID = %q, want %q", keyID, tt.keyID)
+			}
+		})
+	}
+}
+
+func TestFromImages(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	imgs := []images.Image{
+		{SensorType: "falcon-sensor", Registry: "registry.example.com", CredentialRef: "falcon-sensor", Login: "sensor", Password: "p1", DockerJson: "e30="},
+		{SensorType: "falcon-container", Registry: "registry.example.com", CredentialRef: "falcon-container", Login: "container", Password: "p2"},
+		{SensorType: "falcon-operator", Registry: "quay.io"},
+		{SensorType: "falcon-container-regional", Registry: "registry.example.com", CredentialRef: "falcon-container", Login: "container", Password: "p2"},
+	}
+
+	want := []Credential{
+		{Ref: "falcon-sensor", Registry: "registry.example.com", Login: "sensor", Password: "p1", DockerAuthConfig: "e30=", SensorTypes: []string{"falcon-sensor"}, Updated: now, Issued: now, Verified: now},
+		{Ref: "falcon-container", Registry: "registry.example.com", Login: "container", Password: "p2", SensorTypes: []string{"falcon-container", "falcon-container-regional"}, Updated: now, Issued: now, Verified: now},
+	}
+	if got := FromImages(imgs, now); !reflect.DeepEqual(got, want) {
+		t.Errorf("FromImages() = %+v, want %+v", got, want)
+	}
+}
+
+func TestChanges(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	stored := map[string]Credential{"falcon-sensor": {Ref: "falcon-sensor", Login: "login", Password: "password"}}
+
+	tests := []struct {
+		name string
+		img  images.Image
+		want int
+	}{
+		{name: "unchanged", img: images.Image{SensorType: "falcon-sensor", CredentialRef: "falcon-sensor", Login: "login", Password: "password"}},
+		{name: "new password", img: images.Image{SensorType: "falcon-sensor", CredentialRef: "falcon-sensor", Login: "login", Password: "renewed"}, want: 1},
+		{name: "new login", img: images.Image{SensorType: "falcon-sensor", CredentialRef: "falcon-sensor", Login: "other", Password: "password"}, want: 1},
+		{name: "not stored", img: images.Image{SensorType: "falcon-kac", CredentialRef: "falcon-kac", Login: "login", Password: "password"}},
+		{name: "anonymous", img: images.Image{SensorType: "falcon-operator"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes, err := Changes(encryption.Config{}, stored, []images.Image{tt.img}, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(changes) != tt.want {
+				t.Fatalf("Changes() = %+v, want %d changes", changes, tt.want)
+			}
+			for _, change := range changes {
+				// The change never carries the credentials
+				if change.Type != images.ChangeCredentials || change.SensorType != tt.img.SensorType || change.Tag != "" || change.Digest != "" || !change.Time.Equal(now) {
+					t.Errorf("Changes() = %+v, want a credentials change of %s", change, tt.img.SensorType)
+				}
+			}
+		})
+	}
+}
+
+func TestChangesWithStaleKey(t *testing.T) {
+	old := testKeys(t, "k1")
+	encrypted, err := Credential{Ref: "falcon-sensor", Login: "login", Password: "password"}.Encrypt(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := map[string]Credential{encrypted.Ref: encrypted}
+	imgs := []images.Image{{SensorType: "falcon-sensor", CredentialRef: "falcon-sensor", Login: "login", Password: "password"}}
+
+	changes, err := Changes(old, stored, imgs, time.Now())
+	if err != nil || len(changes) != 0 {
+		t.Errorf("Changes() = %v, %v, want no changes for the same credential", changes, err)
+	}
+
+	// A credential encrypted with a removed key does not fail the sync, it is issued again
+	changes, err = Changes(testKeys(t, "k2"), stored, imgs, time.Now())
+	if err != nil || len(changes) != 1 || changes[0].Type != images.ChangeCredentials {
+		t.Errorf("Changes() = %v, %v, want a credentials change for the stale credential", changes, err)
+	}
+}
+
+func TestStale(t *testing.T) {
+	old := testKeys(t, "k1")
+	stored := map[string]Credential{}
+	for _, ref := range []string{"falcon-sensor", "falcon-kac", "falcon-imageanalyzer"} {
+		cred, err := Credential{Ref: ref, Login: "login", Password: "password"}.Encrypt(old)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stored[ref] = cred
+	}
+	stored["falcon-container"] = Credential{Ref: "falcon-container", Login: "login", Password: "password"}
+
+	if stale := Stale(testKeys(t, "k2", "k1"), stored, nil); len(stale) != 0 {
+		t.Errorf("Stale() = %v, want none while the key is configured", stale)
+	}
+
+	stale := Stale(testKeys(t, "k2"), stored, []Credential{{Ref: "falcon-kac"}})
+	refs := map[string]bool{}
+	for _, cred := range stale {
+		refs[cred.Ref] = true
+	}
+	if len(stale) != 2 || !refs["falcon-sensor"] || !refs["falcon-imageanalyzer"] {
+		t.Errorf("Stale() = %v, want the encrypted credentials that were not synced", stale)
+	}
+}
+
+func TestKeepSensorTypes(t *testing.T) {
+	catalogTypes := []string{"falcon-sensor", "falcon-container", "falcon-container-regional", "falcon-kac"}
+	stored := map[string]Credential{
+		"falcon-container": {Ref: "falcon-container", SensorTypes: []string{"falcon-container", "falcon-container-regional", "falcon-removed"}},
+	}
+	imgs := []images.Image{{SensorType: "falcon-container", CredentialRef: "falcon-container", Login: "login", Password: "password"}}
+
+	tests := []struct {
+		name   string
+		synced []string
+		imgs   []images.Image
+		want   []string
+	}{
+		{name: "subset sync", synced: []string{"falcon-container"}, imgs: imgs, want: []string{"falcon-container", "falcon-container-regional"}},
+		{
+			name:   "image moved to another credential",
+			synced: []string{"falcon-container", "falcon-container-regional"},
+			imgs:   imgs,
+			want:   []string{"falcon-container"},
+		},
+		{
+			name:   "full sync",
+			synced: catalogTypes,
+			imgs:   append(imgs, images.Image{SensorType: "falcon-kac", CredentialRef: "falcon-container"}),
+			want:   []string{"falcon-container", "falcon-kac"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			creds := FromImages(tt.imgs, time.Now())
+			KeepSensorTypes(stored, creds, tt.synced, catalogTypes)
+			if got := strings.Join(creds[0].SensorTypes, ","); got != strings.Join(tt.want, ",") {
+				t.Errorf("SensorTypes = %s, want %s", got, strings.Join(tt.want, ","))
+			}
+		})
+	}
+}
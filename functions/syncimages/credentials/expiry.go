@@ -0,0 +1,81 @@
+package credentials
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"syncimages/encryption"
+)
+
+// CarryOver keeps the issue time of the credentials whose login and password did not change
+// since they were stored. Credentials issuing time from their token keep it.
+func CarryOver(keys encryption.Config, previous map[string]Credential, creds []Credential) error {
+	for i := range creds {
+		cred := &creds[i]
+		before, ok := previous[cred.Ref]
+		if !ok || before.Issued.IsZero() {
+			continue
+		}
+		if issued, _, ok := tokenTimes(cred.Password); ok && !issued.IsZero() {
+			continue
+		}
+
+		same, err := before.matches(keys, cred.Login, cred.Password)
+		if err != nil {
+			return err
+		}
+		if same {
+			cred.Issued = before.Issued
+		}
+	}
+
+	return nil
+}
+
+// Expiring returns the stored credentials that expire within the window and were not synced,
+// so the sync renews them before the pull secrets of consumers stop working.
+func Expiring(stored map[string]Credential, synced []Credential, now time.Time, window time.Duration) []Credential {
+	skip := map[string]bool{}
+	for _, cred := range synced {
+		skip[cred.Ref] = true
+	}
+
+	expiring := []Credential{}
+	for _, cred := range stored {
+		if !skip[cred.Ref] && cred.Expires != nil && cred.Expires.Before(now.Add(window)) {
+			expiring = append(expiring, cred)
+		}
+	}
+
+	return expiring
+}
+
+// tokenTimes returns the issued at and expiry claims of a JWT. ok is false when the token is not
+// a JWT with an expiry.
+func tokenTimes(token string) (issued time.Time, expires time.Time, ok bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, time.Time{}, false
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+
+	var claims struct {
+		IssuedAt  float64 `json:"iat"`
+		ExpiresAt float64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.ExpiresAt == 0 {
+		return time.Time{}, time.Time{}, false
+	}
+
+	if claims.IssuedAt > 0 {
+		issued = time.Unix(int64(claims.IssuedAt), 0).UTC()
+	}
+
+	return issued, time.Unix(int64(claims.ExpiresAt), 0).UTC(), true
+}
@@ -0,0 +1,131 @@
+package credentials
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"syncimages/images"
+)
+
+// testToken returns an unsigned JWT with the claims.
+func testToken(claims string) string {
+	encode := base64.RawURLEncoding.EncodeToString
+	return encode([]byte(`{"alg":"none"}`)) + "." + encode([]byte(claims)) + ".c2lnbmF0dXJl"
+}
+
+func TestTokenTimes(t *testing.T) {
+	issued := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	expires := issued.Add(12 * time.Hour)
+
+	tests := []struct {
+		name    string
+		token   string
+		issued  time.Time
+		expires time.Time
+		ok      bool
+	}{
+		{name: "issued and expiry", token: testToken(fmt.Sprintf(`{"iat":%d,"exp":%d}`, issued.Unix(), expires.Unix())), issued: issued, expires: expires, ok: true},
+		{name: "expiry only", token: testToken(fmt.Sprintf(`{"exp":%d}`, expires.Unix())), expires: expires, ok: true},
+		{name: "no expiry", token: testToken(fmt.Sprintf(`{"iat":%d}`, issued.Unix()))},
+		{name: "password", token: "s3cr3t"},
+		{name: "invalid payload", token: "a.!!!.c"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotIssued, gotExpires, ok := tokenTimes(tt.token)
+			if ok != tt.ok || !gotIssued.Equal(tt.issued) || !gotExpires.Equal(tt.expires) {
+				t.Errorf("tokenTimes() = %v, %v, %v, want %v, %v, %v", gotIssued, gotExpires, ok, tt.issued, tt.expires, tt.ok)
+			}
+		})
+	}
+}
+
+func TestFromImagesTokenTimes(t *testing.T) {
+	now := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+	issued := now.Add(-time.Hour)
+	expires := now.Add(11 * time.Hour)
+	imgs := []images.Image{
+		{SensorType: "falcon-sensor", CredentialRef: "falcon-sensor", Password: testToken(fmt.Sprintf(`{"iat":%d,"exp":%d}`, issued.Unix(), expires.Unix()))},
+		{SensorType: "falcon-kac", CredentialRef: "falcon-kac", Password: "s3cr3t"},
+	}
+
+	creds := FromImages(imgs, now)
+	if !creds[0].Issued.Equal(issued) || creds[0].Expires == nil || !creds[0].Expires.Equal(expires) || !creds[0].Verified.Equal(now) {
+		t.Errorf("token credential = %+v, want issued %v, expiring %v", creds[0], issued, expires)
+	}
+	if !creds[1].Issued.Equal(now) || creds[1].Expires != nil {
+		t.Errorf("password credential = %+v, want issued now without expiry", creds[1])
+	}
+}
+
+func TestCarryOverIssued(t *testing.T) {
+	keys := testKeys(t, "k1")
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := t0.AddDate(0, 0, 1)
+	tokenIssued := now.Add(-time.Hour)
+	token := testToken(fmt.Sprintf(`{"iat":%d,"exp":%d}`, tokenIssued.Unix(), now.Add(time.Hour).Unix()))
+
+	encrypted, err := Credential{Ref: "encrypted", Login: "login", Password: "password", Issued: t0}.Encrypt(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := map[string]Credential{
+		"same":      {Ref: "same", Login: "login", Password: "password", Issued: t0},
+		"renewed":   {Ref: "renewed", Login: "login", Password: "old", Issued: t0},
+		"token":     {Ref: "token", Login: "login", Password: token, Issued: t0},
+		"encrypted": encrypted,
+	}
+
+	tests := []struct {
+		name   string
+		cred   Credential
+		issued time.Time
+	}{
+		{name: "unchanged", cred: Credential{Ref: "same", Login: "login", Password: "password", Issued: now}, issued: t0},
+		{name: "new password", cred: Credential{Ref: "renewed", Login: "login", Password: "new", Issued: now}, issued: now},
+		{name: "token", cred: Credential{Ref: "token", Login: "login", Password: token, Issued: tokenIssued}, issued: tokenIssued},
+		{name: "unchanged encrypted", cred: Credential{Ref: "encrypted", Login: "login", Password: "password", Issued: now}, issued: t0},
+		{name: "not stored", cred: Credential{Ref: "new", Login: "login", Password: "password", Issued: now}, issued: now},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			creds := []Credential{tt.cred}
+			if err := CarryOver(keys, previous, creds); err != nil {
+				t.Fatal(err)
+			}
+			if !creds[0].Issued.Equal(tt.issued) {
+				t.Errorf("Issued = %v, want %v", creds[0].Issued, tt.issued)
+			}
+		})
+	}
+}
+
+func TestExpiring(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		expires := now.Add(d)
+		return &expires
+	}
+	stored := map[string]Credential{
+		"soon":     {Ref: "soon", Expires: at(time.Hour)},
+		"expired":  {Ref: "expired", Expires: at(-time.Hour)},
+		"later":    {Ref: "later", Expires: at(48 * time.Hour)},
+		"password": {Ref: "password"},
+		"synced":   {Ref: "synced", Expires: at(time.Hour)},
+	}
+
+	var got []string
+	for _, cred := range Expiring(stored, []Credential{{Ref: "synced"}}, now, 24*time.Hour) {
+		got = append(got, cred.Ref)
+	}
+	sort.Strings(got)
+	if want := []string{"expired", "soon"}; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Expiring() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"syncimages/credentials"
+	"syncimages/encryption"
+	falconapi "syncimages/falcon"
+
+	fdk "github.com/CrowdStrike/foundry-fn-go"
+)
+
+// credentialHandler returns the stored registry credential referenced by the credentialRef of
+// an image, decrypted. The handler requires the registry-credentials permission (see manifest.yml)
+// and is the only reader of the encrypted credentials.
+func credentialHandler(logger *slog.Logger, keys encryption.Config) fdk.Handler {
+	return jsonHandler(logger, func(ctx context.Context, r fdk.Request) (interface{}, error) {
+		client, err := apiClient(r)
+		if err != nil {
+			return nil, err
+		}
+
+		ref := pathParam(ctx, "ref")
+		var cred credentials.Credential
+		err = falconapi.ReadObject(ctx, client, credentials.Collection, ref, &cred)
+		if errors.Is(err, falconapi.ErrObjectNotFound) {
+			return nil, notFound(fmt.Errorf("credential not found: %q", ref))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading credential %s: %w", ref, err)
+		}
+
+		cred, err = cred.Decrypt(keys)
+		if errors.Is(err, encryption.ErrUnknownKey) {
+			return nil, statusError{code: http.StatusConflict, err: fmt.Errorf("credential %s is stale, the next sync issues it again: %w", ref, err)}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error decrypting credential %s: %w", ref, err)
+		}
+
+		return cred, nil
+	})
+}
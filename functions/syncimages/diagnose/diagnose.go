@@ -0,0 +1,208 @@
+package diagnose
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"syncimages/registry"
+
+	"github.com/crowdstrike/gofalcon/falcon"
+	"golang.org/x/oauth2"
+)
+
+// Check kinds.
+const (
+	KindAPI        = "api"
+	KindCID        = "cid"
+	KindCredential = "credential"
+	KindRegistry   = "registry"
+)
+
+// Check statuses.
+const (
+	StatusPass    = "pass"
+	StatusFail    = "fail"
+	StatusSkipped = "skipped"
+)
+
+// Failure reasons.
+const (
+	ReasonMissingScope = "missingScope"
+	ReasonNotEntitled  = "notEntitled"
+	ReasonUnauthorized = "unauthorized"
+	ReasonNotFound     = "notFound"
+	ReasonRateLimited  = "rateLimited"
+	ReasonUnavailable  = "unavailable"
+	ReasonNetwork      = "network"
+	ReasonUnknown      = "unknown"
+)
+
+// Report is the checklist returned by POST /diagnose.
+type Report struct {
+	// Healthy is set when no check failed.
+	Healthy bool    `json:"healthy"`
+	Cloud   string  `json:"cloud"`
+	Checks  []Check `json:"checks"`
+}
+
+// Check is a single item of the checklist.
+type Check struct {
+	Name   string `json:"name"`
+	Kind   string `json:"kind"`
+	Status string `json:"status"`
+	// Scope is the API scope the check requires.
+	Scope       string   `json:"scope,omitempty"`
+	SensorTypes []string `json:"sensorTypes,omitempty"`
+	DurationMs  int64    `json:"durationMs"`
+	// Code is the HTTP status of the failed request, when known.
+	Code        int    `json:"code,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+	Error       string `json:"error,omitempty"`
+	Remediation string `json:"remediation,omitempty"`
+}
+
+// NewReport returns an empty report for the cloud.
+func NewReport(cloud string) Report {
+	return Report{Healthy: true, Cloud: cloud, Checks: []Check{}}
+}
+
+// Add runs the check and appends its outcome to the report.
+func (r *Report) Add(check Check, run func() error) {
+	started := time.Now()
+	err := run()
+	check.DurationMs = time.Since(started).Milliseconds()
+	check.Status = StatusPass
+	if err != nil {
+		check.fail(err)
+		r.Healthy = false
+	}
+
+	r.Checks = append(r.Checks, check)
+}
+
+// Skip appends a check that was not run because the check it depends on failed.
+func (r *Report) Skip(check Check, dependency string) {
+	check.Status = StatusSkipped
+	check.Remediation = fmt.Sprintf("Fix the %s check first.", dependency)
+
+	r.Checks = append(r.Checks, check)
+}
+
+// fail records the classified error and its remediation.
+func (c *Check) fail(err error) {
+	c.Status = StatusFail
+	c.Error = err.Error()
+	c.Code, c.Reason = Classify(err)
+	if c.Kind == KindRegistry && c.Reason == ReasonMissingScope {
+		// The registry has no API scopes: a denied pull means the login is not entitled
+		c.Reason = ReasonNotEntitled
+	}
+	c.Remediation = c.remediation()
+}
+
+// Classify returns the HTTP status of a CrowdStrike API or registry error, when known, and the
+// reason of the failure.
+func Classify(err error) (int, string) {
+	var retrieveErr *oauth2.RetrieveError
+	if errors.As(err, &retrieveErr) {
+		if retrieveErr.Response != nil {
+			return retrieveErr.Response.StatusCode, ReasonUnauthorized
+		}
+		return 0, ReasonUnauthorized
+	}
+
+	code := 0
+	var coder interface{ Code() int }
+	var statusErr *registry.StatusError
+	switch {
+	case errors.As(err, &statusErr):
+		code = statusErr.Code
+	case errors.As(err, &coder):
+		code = coder.Code()
+	}
+
+	message := strings.ToLower(err.Error() + " " + falcon.ErrorExplain(err))
+	switch {
+	case code == http.StatusUnauthorized:
+		return code, ReasonUnauthorized
+	case code == http.StatusForbidden && notEntitled(message):
+		return code, ReasonNotEntitled
+	case code == http.StatusForbidden:
+		return code, ReasonMissingScope
+	case code == http.StatusNotFound:
+		return code, ReasonNotFound
+	case code == http.StatusTooManyRequests:
+		return code, ReasonRateLimited
+	case code >= 500:
+		return code, ReasonUnavailable
+	case code == 0 && network(err):
+		return code, ReasonNetwork
+	case code == 0 && notEntitled(message):
+		return code, ReasonNotEntitled
+	default:
+		return code, ReasonUnknown
+	}
+}
+
+// Entitlement returns the reason of an error caused by a missing API scope or subscription, so
+// the sync can skip the product instead of failing. ok is false for other errors.
+func Entitlement(err error) (reason string, ok bool) {
+	_, reason = Classify(err)
+
+	return reason, reason == ReasonMissingScope || reason == ReasonNotEntitled
+}
+
+// notEntitled reports whether the error message points to a missing subscription.
+func notEntitled(message string) bool {
+	for _, hint := range []string{"subscription", "not subscribed", "not entitled", "entitlement", "not enabled", "requested access to the resource is denied"} {
+		if strings.Contains(message, hint) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// network reports whether the error is a connection failure.
+func network(err error) bool {
+	var netErr net.Error
+	var urlErr *url.Error
+	var dnsErr *net.DNSError
+
+	return errors.As(err, &netErr) || errors.As(err, &urlErr) || errors.As(err, &dnsErr) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
+
+// remediation returns the fix for the failure of the check.
+func (c Check) remediation() string {
+	switch c.Reason {
+	case ReasonMissingScope:
+		return fmt.Sprintf("The API client is missing the %s scope. Add it to auth.scopes in manifest.yml and redeploy the app, or to the API client used for local testing.", c.Scope)
+	case ReasonNotEntitled:
+		if c.Kind == KindRegistry {
+			return fmt.Sprintf("The registry denied access with the credential of %s. Check that the CID is subscribed to the products of these images, or disable them in the catalog.", strings.Join(c.SensorTypes, ", "))
+		}
+		return fmt.Sprintf("The CID is not subscribed to the product behind the %s scope. Contact your CrowdStrike account team, or disable the images using it in the catalog: %s.", c.Scope, strings.Join(c.SensorTypes, ", "))
+	case ReasonUnauthorized:
+		if c.Kind == KindRegistry {
+			return "The registry rejected the login. Check the registry token and login prefix of the catalog entry, or the username and password of static credentials."
+		}
+		return "The CrowdStrike API rejected the credentials. Reinstall the app, or check FALCON_CLIENT_ID, FALCON_CLIENT_SECRET and FALCON_CLOUD when testing locally."
+	case ReasonNotFound:
+		return "The resource was not found. Check the repository of the catalog entry and the cloud."
+	case ReasonRateLimited:
+		return "The request was rate limited. Retry in a few minutes."
+	case ReasonUnavailable:
+		return "The service returned a server error. Retry later and contact CrowdStrike support if it persists."
+	case ReasonNetwork:
+		return "The service could not be reached. Check the network access of the function and the cloud."
+	default:
+		return "Check the error message and the function logs."
+	}
+}
@@ -0,0 +1,93 @@
+package diagnose
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+
+	"syncimages/registry"
+
+	"golang.org/x/oauth2"
+)
+
+// codeError is an API error exposing its HTTP status like the gofalcon errors.
+type codeError int
+
+func (e codeError) Error() string { return fmt.Sprintf("[%d] API error", int(e)) }
+func (e codeError) Code() int     { return int(e) }
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		code   int
+		reason string
+	}{
+		{name: "oauth2", err: &oauth2.RetrieveError{Response: &http.Response{StatusCode: http.StatusUnauthorized}}, code: http.StatusUnauthorized, reason: ReasonUnauthorized},
+		{name: "oauth2 without response", err: &oauth2.RetrieveError{}, reason: ReasonUnauthorized},
+		{name: "registry unauthorized", err: &registry.StatusError{Code: http.StatusUnauthorized}, code: http.StatusUnauthorized, reason: ReasonUnauthorized},
+		{name: "registry denied", err: fmt.Errorf("error pinging registry: %w", &registry.StatusError{Code: http.StatusForbidden, Body: "requested access to the resource is denied"}), code: http.StatusForbidden, reason: ReasonNotEntitled},
+		{name: "missing scope", err: codeError(http.StatusForbidden), code: http.StatusForbidden, reason: ReasonMissingScope},
+		{name: "not found", err: codeError(http.StatusNotFound), code: http.StatusNotFound, reason: ReasonNotFound},
+		{name: "rate limited", err: &registry.StatusError{Code: http.StatusTooManyRequests}, code: http.StatusTooManyRequests, reason: ReasonRateLimited},
+		{name: "unavailable", err: &registry.StatusError{Code: http.StatusServiceUnavailable}, code: http.StatusServiceUnavailable, reason: ReasonUnavailable},
+		{name: "dns", err: &net.DNSError{Err: "no such host", Name: "registry.example.com"}, reason: ReasonNetwork},
+		{name: "deadline", err: fmt.Errorf("error listing tags: %w", context.DeadlineExceeded), reason: ReasonNetwork},
+		{name: "not subscribed", err: errors.New("CID is not subscribed to the product"), reason: ReasonNotEntitled},
+		{name: "unknown", err: errors.New("unexpected end of manifest"), reason: ReasonUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, reason := Classify(tt.err)
+			if code != tt.code || reason != tt.reason {
+				t.Errorf("Classify() = %d, %q, want %d, %q", code, reason, tt.code, tt.reason)
+			}
+		})
+	}
+}
+
+func TestEntitlement(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		reason string
+		ok     bool
+	}{
+		{name: "missing scope", err: codeError(http.StatusForbidden), reason: ReasonMissingScope, ok: true},
+		{name: "not entitled", err: errors.New("subscription required"), reason: ReasonNotEntitled, ok: true},
+		{name: "unauthorized", err: codeError(http.StatusUnauthorized), reason: ReasonUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason, ok := Entitlement(tt.err)
+			if reason != tt.reason || ok != tt.ok {
+				t.Errorf("Entitlement() = %q, %v, want %q, %v", reason, ok, tt.reason, tt.ok)
+			}
+		})
+	}
+}
+
+func TestReport(t *testing.T) {
+	report := NewReport("us-1")
+	report.Add(Check{Name: "api", Kind: KindAPI}, func() error { return nil })
+	report.Add(Check{Name: "registry", Kind: KindRegistry, SensorTypes: []string{"falcon-kac"}}, func() error { return codeError(http.StatusForbidden) })
+	report.Skip(Check{Name: "tags", Kind: KindRegistry}, "registry")
+
+	if report.Healthy {
+		t.Error("Healthy = true, want false with a failed check")
+	}
+	want := []struct{ status, reason string }{{StatusPass, ""}, {StatusFail, ReasonNotEntitled}, {StatusSkipped, ""}}
+	for i, check := range report.Checks {
+		if check.Status != want[i].status || check.Reason != want[i].reason {
+			t.Errorf("Checks[%d] = %s, %q, want %s, %q", i, check.Status, check.Reason, want[i].status, want[i].reason)
+		}
+		if check.Status != StatusPass && check.Remediation == "" {
+			t.Errorf("Checks[%d] has no remediation", i)
+		}
+	}
+}
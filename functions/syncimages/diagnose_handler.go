@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"syncimages/api"
+	"syncimages/catalog"
+	"syncimages/diagnose"
+	falconapi "syncimages/falcon"
+	"syncimages/images"
+	"syncimages/registry"
+	"syncimages/version"
+
+	fdk "github.com/CrowdStrike/foundry-fn-go"
+	"github.com/crowdstrike/gofalcon/falcon"
+	"github.com/crowdstrike/gofalcon/falcon/client"
+)
+
+// diagnoseHandler checks the API client, the credential sources and the registries of the
+// catalog, and returns a checklist with the remediation of every failed check.
+func diagnoseHandler(logger *slog.Logger, cat catalog.Catalog) fdk.Handler {
+	return jsonHandler(logger, func(ctx context.Context, r fdk.Request) (interface{}, error) {
+		req, err := api.DecodeDiagnoseRequest(r.Body)
+		if err != nil {
+			return nil, badRequest(err)
+		}
+		if err := req.Validate(cat.SensorTypes()); err != nil {
+			return nil, badRequest(err)
+		}
+
+		client, cloud, err := newFalconClient(r.AccessToken)
+		if err == nil {
+			err = verifyCloud(ctx, r.AccessToken, cloud)
+		}
+		report := diagnose.NewReport(cloud)
+		report.Add(diagnose.Check{Name: "CrowdStrike API client", Kind: diagnose.KindAPI}, func() error {
+			return err
+		})
+		if err == nil {
+			diagnoseCatalog(ctx, client, cloud, cat, req, &report)
+		}
+
+		if !report.Healthy {
+			logger.Warn("Diagnostics found failures", "checks", len(report.Checks))
+		}
+
+		return report, nil
+	})
+}
+
+// versionHandler returns the version and build information of the function.
+func versionHandler() fdk.Handler {
+	return fdk.HandlerFn(func(ctx context.Context, r fdk.Request) fdk.Response {
+		return fdk.Response{
+			Code: 200,
+			Body: fdk.JSON(api.VersionResponse{
+				Info:          version.Get(),
+				SchemaVersion: images.SchemaVersion,
+			}),
+		}
+	})
+}
+
+// healthHandler checks that the Falcon client can be created, without syncing or calling the
+// registries. It returns 503 when the check failed. The function config and the catalog are
+// validated by fdk before the handler is called.
+func healthHandler(logger *slog.Logger) fdk.Handler {
+	return fdk.HandlerFn(func(ctx context.Context, r fdk.Request) fdk.Response {
+		report := diagnose.NewReport("")
+		var cloud string
+		report.Add(diagnose.Check{Name: "CrowdStrike API client", Kind: diagnose.KindAPI}, func() (err error) {
+			_, cloud, err = newFalconClient(r.AccessToken)
+			return err
+		})
+		report.Cloud = cloud
+
+		res := api.HealthResponse{
+			Status:         "ok",
+			Version:        version.Current(),
+			FnID:           r.FnID,
+			FnVersion:      strconv.Itoa(r.FnVersion),
+			FnBuildVersion: os.Getenv("CS_FN_BUILD_VERSION"),
+			Report:         report,
+		}
+		code := http.StatusOK
+		if !report.Healthy {
+			logger.Warn("Health check failed", "checks", len(report.Checks))
+			res.Status = "fail"
+			code = http.StatusServiceUnavailable
+		}
+
+		return fdk.Response{
+			Code: code,
+			Body: fdk.JSON(res),
+		}
+	})
+}
+
+// diagnoseCatalog adds a check of the CID, of every credential and of every registry used by the
+// selected sensor types to the report.
+func diagnoseCatalog(ctx context.Context, client *client.CrowdStrikeAPISpecification, cloud string, cat catalog.Catalog, req api.DiagnoseRequest, report *diagnose.Report) {
+	cidCheck := diagnose.Check{Name: "CID", Kind: diagnose.KindCID, Scope: falconapi.CIDScope}
+	var cid string
+	report.Add(cidCheck, func() (err error) {
+		cid, err = falconapi.GetCID(ctx, client)
+		return err
+	})
+	cidFailed := cid == ""
+
+	// The targets are grouped by credential and registry, so every credential is fetched and
+	// every registry login tested once.
+	type group struct {
+		ref, host   string
+		target      catalog.Target
+		sensorTypes []string
+	}
+	groups := []*group{}
+	index := map[string]*group{}
+	credentialSensorTypes := map[string][]string{}
+	for _, target := range cat.Targets(falcon.Cloud(cloud)) {
+		if !req.Includes(target.SensorType) {
+			continue
+		}
+
+		ref := target.CredentialRef()
+		host := strings.Split(target.Repository, "/")[0]
+		g, ok := index[ref+"/"+host]
+		if !ok {
+			g = &group{ref: ref, host: host, target: target}
+			index[ref+"/"+host] = g
+			groups = append(groups, g)
+		}
+		g.sensorTypes = append(g.sensorTypes, target.SensorType)
+		credentialSensorTypes[ref] = append(credentialSensorTypes[ref], target.SensorType)
+	}
+
+	type login struct {
+		user, pass string
+		ok         bool
+	}
+	logins := map[string]login{}
+	for _, g := range groups {
+		l, fetched := logins[g.ref]
+		if g.ref == "" {
+			l = login{ok: true}
+		} else if !fetched {
+			check := diagnose.Check{
+				Name:        "credential " + g.ref,
+				Kind:        diagnose.KindCredential,
+				Scope:       g.target.Credentials.TokenSource.Scope(),
+				SensorTypes: credentialSensorTypes[g.ref],
+			}
+			if g.target.Credentials.Source == catalog.CredentialSourceFalcon && cidFailed {
+				report.Skip(check, "CID")
+			} else {
+				report.Add(check, func() (err error) {
+					l.user, l.pass, err = registryCredentials(ctx, client, cid, g.target.Credentials)
+					if err == nil && l.pass == "" {
+						err = fmt.Errorf("the credential has no password, check password or passwordEnv")
+					}
+					l.ok = err == nil
+					return err
+				})
+			}
+			logins[g.ref] = l
+		}
+
+		check := diagnose.Check{Name: "registry " + g.host, Kind: diagnose.KindRegistry, SensorTypes: g.sensorTypes}
+		if !l.ok {
+			report.Skip(check, "credential "+g.ref)
+			continue
+		}
+		report.Add(check, func() error {
+			rc := registry.NewRegistryConfig(l.user, l.pass).WithContext(ctx)
+			if g.target.Insecure {
+				rc = rc.Insecure()
+			}
+			return rc.Ping(g.host)
+		})
+	}
+}
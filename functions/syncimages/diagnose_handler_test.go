@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"syncimages/api"
+	"syncimages/images"
+	"syncimages/version"
+
+	fdk "github.com/CrowdStrike/foundry-fn-go"
+)
+
+func TestVersionHandler(t *testing.T) {
+	res := versionHandler().Handle(context.Background(), fdk.Request{Method: http.MethodGet, URL: "/version"})
+	if res.Code != http.StatusOK {
+		t.Fatalf("code = %d, want %d", res.Code, http.StatusOK)
+	}
+
+	b, err := res.Body.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got api.VersionResponse
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Version != version.Current() || got.SchemaVersion != images.SchemaVersion || got.Modules == nil {
+		t.Errorf("version = %s, want version %s, schema version %d and the modules", b, version.Current(), images.SchemaVersion)
+	}
+}
+
+func TestHealthHandler(t *testing.T) {
+	tests := []struct {
+		name   string
+		cloud  string
+		code   int
+		status string
+	}{
+		{name: "healthy", cloud: "us-2", code: http.StatusOK, status: "ok"},
+		{name: "unknown cloud", cloud: "mars-1", code: http.StatusServiceUnavailable, status: "fail"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := withFakeAPI(t)
+			t.Setenv("FALCON_CLOUD", tt.cloud)
+			t.Setenv("CS_FN_BUILD_VERSION", "7")
+
+			res := healthHandler(newLogger(io.Discard, false, false)).Handle(context.Background(), fdk.Request{
+				Method:      http.MethodGet,
+				URL:         "/healthz",
+				AccessToken: "token",
+				FnID:        "fn",
+				FnVersion:   3,
+			})
+			if res.Code != tt.code {
+				t.Fatalf("code = %d, want %d", res.Code, tt.code)
+			}
+			// The health check neither syncs nor calls the API
+			if len(fake.paths) != 0 {
+				t.Errorf("requested %v, want no API calls", fake.paths)
+			}
+
+			b, err := res.Body.MarshalJSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got api.HealthResponse
+			if err := json.Unmarshal(b, &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != tt.status || got.FnID != "fn" || got.FnVersion != "3" || got.FnBuildVersion != "7" || got.Version != version.Current() {
+				t.Errorf("health = %s, want status %s of the function", b, tt.status)
+			}
+			if tt.status == "ok" && got.Cloud != tt.cloud {
+				t.Errorf("cloud = %q, want %q", got.Cloud, tt.cloud)
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"syncimages/registry"
+)
+
+// StatusUnclassified marks a discovered repository that is not covered by the catalog.
+const StatusUnclassified = "unclassified"
+
+// maxProbes is the number of repository candidates walk probes per credential.
+const maxProbes = 50
+
+// Credential is a registry credential already held by the sync.
+type Credential struct {
+	Registry string
+	User     string
+	Pass     string
+	Insecure bool
+}
+
+// Repository is a repository found in a registry catalog that is not part of the image catalog.
+type Repository struct {
+	Registry   string `json:"registry"`
+	Repository string `json:"repository"`
+	Login      string `json:"login,omitempty"`
+	Status     string `json:"status"`
+}
+
+// Result holds the discovered repositories and the registries that could not be listed.
+type Result struct {
+	Repositories []Repository `json:"repositories"`
+	Errors       []string     `json:"errors,omitempty"`
+}
+
+// Discover lists the repositories visible to each credential through the registry _catalog endpoint
+// and returns those that are not in known (full repository references). When prefixes are set, only
+// repositories whose path starts with one of the prefixes are considered. With walk set, registries
+// without a _catalog endpoint, or denying it to the credential, are walked by the prefixes instead,
+// see walk. Walking the namespaces of the catalog cannot discover new ones, so walk must only be
+// set for configured prefixes. Discovery stops when the context is done.
+func Discover(ctx context.Context, credentials []Credential, known []string, prefixes []string, walk bool) Result {
+	result := Result{Repositories: []Repository{}}
+
+	knownSet := map[string]bool{}
+	for _, repo := range known {
+		knownSet[repo] = true
+	}
+
+	seenCredentials := map[string]bool{}
+	seenRepositories := map[string]bool{}
+
+	for _, cred := range credentials {
+		if err := ctx.Err(); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("discovery stopped: %v", err))
+			break
+		}
+
+		key := cred.Registry + "\x00" + cred.User + "\x00" + cred.Pass
+		if seenCredentials[key] {
+			continue
+		}
+		seenCredentials[key] = true
+
+		rc := registry.NewRegistryConfig(cred.User, cred.Pass).WithContext(ctx)
+		if cred.Insecure {
+			rc = rc.Insecure()
+		}
+
+		repositories, err := listRepositories(ctx, rc, cred, known, prefixes, walk)
+		if err != nil {
+			// A walk beyond maxProbes returns the repositories found so far
+			slog.Warn("Failed to discover registry repositories", "registry", cred.Registry, "user", cred.User, "error", err)
+			result.Errors = append(result.Errors, fmt.Sprintf("%s (%s): %v", cred.Registry, cred.User, err))
+		}
+
+		for _, path := range repositories {
+			full := cred.Registry + "/" + path
+			if knownSet[full] || seenRepositories[full] || !hasPrefix(path, prefixes) {
+				continue
+			}
+			seenRepositories[full] = true
+
+			result.Repositories = append(result.Repositories, Repository{
+				Registry:   cred.Registry,
+				Repository: full,
+				Login:      cred.User,
+				Status:     StatusUnclassified,
+			})
+		}
+	}
+
+	sort.Slice(result.Repositories, func(i, j int) bool {
+		return result.Repositories[i].Repository < result.Repositories[j].Repository
+	})
+
+	slog.Info("Completed repository discovery", "discovered", len(result.Repositories), "errors", len(result.Errors))
+	return result
+}
+
+// listRepositories returns the repository paths of the registry visible to the credential. The
+// credential is checked first, so a rejected credential fails instead of falling back to walk.
+func listRepositories(ctx context.Context, rc registry.Config, cred Credential, known []string, prefixes []string, walkPrefixes bool) ([]string, error) {
+	if err := rc.Ping(cred.Registry); err != nil {
+		return nil, err
+	}
+
+	slog.Debug("Listing registry catalog", "registry", cred.Registry, "user", cred.User)
+	repositories, err := rc.ListRepositories(cred.Registry)
+	if err == nil {
+		return repositories, nil
+	}
+	if ctx.Err() != nil || !walkPrefixes {
+		return nil, err
+	}
+
+	slog.Info("Registry catalog not available, walking the namespace prefixes", "registry", cred.Registry, "user", cred.User, "error", err)
+	return walk(ctx, rc, cred.Registry, known, prefixes)
+}
+
+// walk probes the repositories the registry would have under each prefix: the namespace (first
+// path segment) of every known repository of the registry is replaced by the namespace of the
+// prefix, and so is a last segment repeating it. For example the prefix "falcon-jobcontroller/"
+// and the known repository "falcon-sensor/release/falcon-sensor" probe
+// "falcon-jobcontroller/release/falcon-jobcontroller". Candidates without tags, or denied to the
+// credential, are skipped. At most maxProbes candidates are probed, an error is returned with the
+// repositories found when there are more.
+func walk(ctx context.Context, rc registry.Config, host string, known []string, prefixes []string) ([]string, error) {
+	knownSet := map[string]bool{}
+	paths := []string{}
+	for _, repo := range known {
+		path, ok := strings.CutPrefix(repo, host+"/")
+		if ok {
+			knownSet[path] = true
+			paths = append(paths, path)
+		}
+	}
+
+	candidates := []string{}
+	seen := map[string]bool{}
+	for _, prefix := range prefixes {
+		namespace := strings.Split(prefix, "/")[0]
+		if namespace == "" {
+			continue
+		}
+		for _, path := range paths {
+			segments := strings.Split(path, "/")
+			if segments[len(segments)-1] == segments[0] {
+				segments[len(segments)-1] = namespace
+			}
+			segments[0] = namespace
+
+			candidate := strings.Join(segments, "/")
+			if knownSet[candidate] || seen[candidate] || !strings.HasPrefix(candidate, prefix) {
+				continue
+			}
+			seen[candidate] = true
+			candidates = append(candidates, candidate)
+		}
+	}
+
+	var limitErr error
+	if len(candidates) > maxProbes {
+		limitErr = fmt.Errorf("walked %d of %d repository candidates, set fewer discovery prefixes", maxProbes, len(candidates))
+		candidates = candidates[:maxProbes]
+	}
+
+	repositories := []string{}
+	for _, candidate := range candidates {
+		tags, err := rc.GetRepositoryTags(host + "/" + candidate)
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("error walking the namespace prefixes: %w", ctx.Err())
+		}
+		if err != nil {
+			slog.Debug("Skipping repository candidate", "registry", host, "repository", candidate, "error", err)
+			continue
+		}
+		if len(tags) > 0 {
+			repositories = append(repositories, candidate)
+		}
+	}
+
+	return repositories, limitErr
+}
+
+// hasPrefix reports whether the repository path starts with one of the prefixes. No prefixes match everything.
+func hasPrefix(path string, prefixes []string) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
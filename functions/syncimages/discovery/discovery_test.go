@@ -0,0 +1,94 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testRegistry returns an anonymous registry without a _catalog endpoint holding the repositories.
+func testRegistry(t *testing.T, repositories ...string) string {
+	t.Helper()
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		for _, repo := range repositories {
+			if r.URL.Path == "/v2/"+repo+"/tags/list" {
+				_ = json.NewEncoder(w).Encode(map[string]interface{}{"name": repo, "tags": []string{"1.0.0"}})
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(server.Close)
+
+	return strings.TrimPrefix(server.URL, "https://")
+}
+
+func TestDiscoverWalk(t *testing.T) {
+	host := testRegistry(t, "falcon-sensor/us-1/release/falcon-sensor", "falcon-jobcontroller/us-1/release/falcon-jobcontroller")
+	credentials := []Credential{{Registry: host, Insecure: true}}
+	known := []string{host + "/falcon-sensor/us-1/release/falcon-sensor"}
+
+	manyPrefixes := []string{"falcon-jobcontroller/"}
+	for i := 0; i < maxProbes; i++ {
+		manyPrefixes = append(manyPrefixes, fmt.Sprintf("falcon-unknown-%d/", i))
+	}
+
+	tests := []struct {
+		name     string
+		prefixes []string
+		walk     bool
+		want     []string
+		err      string
+	}{
+		{name: "catalog namespaces", prefixes: []string{"falcon-sensor/"}, walk: true, want: []string{}},
+		{name: "new namespace", prefixes: []string{"falcon-sensor/", "falcon-jobcontroller/"}, walk: true, want: []string{host + "/falcon-jobcontroller/us-1/release/falcon-jobcontroller"}},
+		{name: "missing namespace", prefixes: []string{"falcon-unknown/"}, walk: true, want: []string{}},
+		{name: "other path", prefixes: []string{"falcon-jobcontroller/eu-1/"}, walk: true, want: []string{}},
+		{name: "default prefixes", prefixes: []string{"falcon-sensor/", "falcon-jobcontroller/"}, want: []string{}, err: "registry returned status 404"},
+		{name: "probe limit", prefixes: manyPrefixes, walk: true, want: []string{host + "/falcon-jobcontroller/us-1/release/falcon-jobcontroller"}, err: fmt.Sprintf("walked %d of %d repository candidates", maxProbes, maxProbes+1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Discover(context.Background(), credentials, known, tt.prefixes, tt.walk)
+			if tt.err == "" && len(result.Errors) != 0 {
+				t.Fatalf("Discover() errors = %v", result.Errors)
+			}
+			if tt.err != "" && (len(result.Errors) != 1 || !strings.Contains(result.Errors[0], tt.err)) {
+				t.Fatalf("Discover() errors = %v, want %q", result.Errors, tt.err)
+			}
+
+			got := []string{}
+			for _, repo := range result.Repositories {
+				got = append(got, repo.Repository)
+				if repo.Status != StatusUnclassified {
+					t.Errorf("Discover() status = %q, want %q", repo.Status, StatusUnclassified)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Discover() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiscoverCanceled(t *testing.T) {
+	host := testRegistry(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	result := Discover(ctx, []Credential{{Registry: host, Insecure: true}}, nil, nil, true)
+	if len(result.Errors) != 1 || !strings.Contains(result.Errors[0], "context canceled") {
+		t.Errorf("Discover() errors = %v, want the discovery stopped", result.Errors)
+	}
+}
@@ -0,0 +1,217 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrUnknownKey is returned for envelopes wrapped by a key that is not configured anymore. The
+// records they encrypt cannot be read and must be issued again.
+var ErrUnknownKey = errors.New("unknown encryption key")
+
+// dataKeySize is the size of the AES-256 data key generated for every record.
+const dataKeySize = 32
+
+// Config holds the key encryption keys. Every record is encrypted with its own data key, which
+// is stored wrapped (encrypted) by the active key, so rotating the active key only re-wraps the
+// data keys.
+type Config struct {
+	// ActiveKey is the ID of the key wrapping the data keys of new records. Empty disables
+	// encryption of new records, the existing envelopes are left as they are and still need the
+	// keys wrapping them to be read.
+	ActiveKey string `json:"activeKey,omitempty"`
+	// Keys are the active key and the retired keys still needed to read older records.
+	Keys []Key `json:"keys,omitempty"`
+
+	keys map[string][]byte
+}
+
+// Key is a key encryption key.
+type Key struct {
+	ID string `json:"id"`
+	// Key (or KeyEnv, the name of an environment variable holding the key, e.g. a function
+	// secret) is the base64 encoded 128, 192 or 256 bit AES key.
+	Key    string `json:"key,omitempty"`
+	KeyEnv string `json:"keyEnv,omitempty"`
+}
+
+// Envelope is the wrapped data key of an encrypted record.
+type Envelope struct {
+	// KeyID is the ID of the key wrapping the data key.
+	KeyID string `json:"keyId"`
+	// DataKey is the base64 encoded nonce and AES-GCM sealed data key.
+	DataKey string `json:"dataKey"`
+}
+
+// Validate decodes the keys and checks that the active key is one of them.
+func (c *Config) Validate() error {
+	c.keys = map[string][]byte{}
+	for i, key := range c.Keys {
+		if key.ID == "" {
+			return fmt.Errorf("encryption key %d: id is required", i)
+		}
+		if _, ok := c.keys[key.ID]; ok {
+			return fmt.Errorf("encryption key %q: duplicate id", key.ID)
+		}
+
+		value := key.Key
+		if key.KeyEnv != "" {
+			value = os.Getenv(key.KeyEnv)
+		}
+		b, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return fmt.Errorf("encryption key %q: invalid base64: %v", key.ID, err)
+		}
+		if _, err := aes.NewCipher(b); err != nil {
+			return fmt.Errorf("encryption key %q: %v", key.ID, err)
+		}
+		c.keys[key.ID] = b
+	}
+
+	if c.ActiveKey != "" {
+		if _, ok := c.keys[c.ActiveKey]; !ok {
+			return fmt.Errorf("unknown active encryption key %q", c.ActiveKey)
+		}
+	}
+
+	return nil
+}
+
+// Enabled reports whether new records are encrypted.
+func (c Config) Enabled() bool {
+	return c.ActiveKey != ""
+}
+
+// NewDataKey returns a random data key and its envelope wrapped by the active key.
+func (c Config) NewDataKey() ([]byte, Envelope, error) {
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, Envelope{}, fmt.Errorf("error generating data key: %v", err)
+	}
+
+	env, err := c.wrap(dataKey)
+	if err != nil {
+		return nil, Envelope{}, err
+	}
+
+	return dataKey, env, nil
+}
+
+// Unwrap returns the data key of the envelope.
+func (c Config) Unwrap(env Envelope) ([]byte, error) {
+	key, ok := c.keys[env.KeyID]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, env.KeyID)
+	}
+
+	dataKey, err := open(key, env.DataKey, env.KeyID)
+	if err != nil {
+		return nil, fmt.Errorf("error unwrapping data key: %v", err)
+	}
+
+	return dataKey, nil
+}
+
+// Rewrap returns the envelope wrapped by the active key. The data key, and so the data it
+// encrypts, is unchanged.
+func (c Config) Rewrap(env Envelope) (Envelope, error) {
+	dataKey, err := c.Unwrap(env)
+	if err != nil {
+		return Envelope{}, err
+	}
+
+	return c.wrap(dataKey)
+}
+
+// wrap encrypts the data key with the active key.
+func (c Config) wrap(dataKey []byte) (Envelope, error) {
+	key, ok := c.keys[c.ActiveKey]
+	if !ok {
+		return Envelope{}, fmt.Errorf("unknown active encryption key %q", c.ActiveKey)
+	}
+
+	sealed, err := seal(key, dataKey, c.ActiveKey)
+	if err != nil {
+		return Envelope{}, fmt.Errorf("error wrapping data key: %v", err)
+	}
+
+	return Envelope{KeyID: c.ActiveKey, DataKey: sealed}, nil
+}
+
+// Seal encrypts the value with the data key. The associated data binds the ciphertext to its
+// record and field, so it cannot be copied to another one.
+func Seal(dataKey []byte, value string, associatedData string) (string, error) {
+	return seal(dataKey, []byte(value), associatedData)
+}
+
+// Open decrypts a value encrypted by Seal with the same associated data.
+func Open(dataKey []byte, value string, associatedData string) (string, error) {
+	b, err := open(dataKey, value, associatedData)
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+// Fingerprint returns the hex encoded HMAC-SHA256 of the values with the data key, to compare
+// encrypted values without decrypting them.
+func Fingerprint(dataKey []byte, values ...string) string {
+	mac := hmac.New(sha256.New, dataKey)
+	for _, value := range values {
+		mac.Write([]byte(value))
+		mac.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// seal returns the base64 encoded nonce followed by the AES-GCM sealed plaintext.
+func seal(key []byte, plaintext []byte, associatedData string) (string, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, plaintext, []byte(associatedData))), nil
+}
+
+// open decrypts a value returned by seal.
+func open(key []byte, value string, associatedData string) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < aead.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+
+	return aead.Open(nil, b[:aead.NonceSize()], b[aead.NonceSize():], []byte(associatedData))
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
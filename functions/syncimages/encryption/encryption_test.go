@@ -0,0 +1,155 @@
+package encryption
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// testKey returns a base64 encoded AES key of the size filled with the byte.
+func testKey(size int, b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, size))
+}
+
+// testConfig returns a validated config with the keys old and new, new being active.
+func testConfig(t *testing.T, active string) Config {
+	t.Helper()
+	c := Config{ActiveKey: active, Keys: []Key{{ID: "old", Key: testKey(32, 1)}, {ID: "new", Key: testKey(16, 2)}}}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+func TestValidate(t *testing.T) {
+	t.Setenv("TEST_ENCRYPTION_KEY", testKey(24, 3))
+
+	tests := []struct {
+		name   string
+		config Config
+		err    string
+	}{
+		{name: "disabled", config: Config{}},
+		{name: "key and key env", config: Config{ActiveKey: "env", Keys: []Key{{ID: "a", Key: testKey(32, 1)}, {ID: "env", KeyEnv: "TEST_ENCRYPTION_KEY"}}}},
+		{name: "missing id", config: Config{Keys: []Key{{Key: testKey(32, 1)}}}, err: "id is required"},
+		{name: "duplicate id", config: Config{Keys: []Key{{ID: "a", Key: testKey(32, 1)}, {ID: "a", Key: testKey(32, 2)}}}, err: "duplicate id"},
+		{name: "invalid base64", config: Config{Keys: []Key{{ID: "a", Key: "not base64!"}}}, err: "invalid base64"},
+		{name: "invalid size", config: Config{Keys: []Key{{ID: "a", Key: testKey(20, 1)}}}, err: "invalid key size"},
+		{name: "unknown active key", config: Config{ActiveKey: "b", Keys: []Key{{ID: "a", Key: testKey(32, 1)}}}, err: "unknown active encryption key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.err == "" && err != nil {
+				t.Errorf("Validate() error = %v", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestSealOpen(t *testing.T) {
+	c := testConfig(t, "new")
+	dataKey, _, err := c.NewDataKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := Seal(dataKey, "secret", "ref/password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(sealed, "secret") {
+		t.Fatalf("Seal() = %q, want a ciphertext", sealed)
+	}
+
+	otherKey, _, err := c.NewDataKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		dataKey        []byte
+		value          string
+		associatedData string
+		want           string
+		failed         bool
+	}{
+		{name: "same key and record", dataKey: dataKey, value: sealed, associatedData: "ref/password", want: "secret"},
+		{name: "other record", dataKey: dataKey, value: sealed, associatedData: "other/password", failed: true},
+		{name: "other data key", dataKey: otherKey, value: sealed, associatedData: "ref/password", failed: true},
+		{name: "truncated", dataKey: dataKey, value: base64.StdEncoding.EncodeToString([]byte("short")), associatedData: "ref/password", failed: true},
+		{name: "not base64", dataKey: dataKey, value: "???", associatedData: "ref/password", failed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Open(tt.dataKey, tt.value, tt.associatedData)
+			if (err != nil) != tt.failed {
+				t.Fatalf("Open() error = %v, want failed %v", err, tt.failed)
+			}
+			if got != tt.want {
+				t.Errorf("Open() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRewrap(t *testing.T) {
+	old := testConfig(t, "old")
+	dataKey, env, err := old.NewDataKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env.KeyID != "old" {
+		t.Fatalf("NewDataKey() key ID = %q, want old", env.KeyID)
+	}
+
+	rotated := testConfig(t, "new")
+	rewrapped, err := rotated.Rewrap(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rewrapped.KeyID != "new" || rewrapped.DataKey == env.DataKey {
+		t.Errorf("Rewrap() = %+v, want the data key wrapped by new", rewrapped)
+	}
+
+	// The old key can be removed once the data keys are re-wrapped
+	newOnly := Config{ActiveKey: "new", Keys: []Key{{ID: "new", Key: testKey(16, 2)}}}
+	if err := newOnly.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := newOnly.Unwrap(rewrapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, dataKey) {
+		t.Error("Unwrap() of the re-wrapped envelope returned another data key")
+	}
+
+	if _, err := newOnly.Unwrap(env); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Unwrap() with a removed key error = %v, want ErrUnknownKey", err)
+	}
+	if _, err := newOnly.Rewrap(env); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Rewrap() with a removed key error = %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	key := []byte("data key")
+	if Fingerprint(key, "login", "password") != Fingerprint(key, "login", "password") {
+		t.Error("Fingerprint() differs for the same values")
+	}
+	if Fingerprint(key, "login", "password") == Fingerprint(key, "loginp", "assword") {
+		t.Error("Fingerprint() is the same for values with another split")
+	}
+	if Fingerprint(key, "login", "password") == Fingerprint([]byte("other key"), "login", "password") {
+		t.Error("Fingerprint() is the same for another data key")
+	}
+}
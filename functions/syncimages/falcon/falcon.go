@@ -4,8 +4,11 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
+	"regexp"
 	"strings"
 
 	"github.com/crowdstrike/gofalcon/falcon"
@@ -22,6 +25,75 @@ func RegistryLogin(prefix string, cid string) string {
 	return fmt.Sprintf("%s-%s", prefix, strings.ToLower(strings.Split(cid, "-")[0]))
 }
 
+// ParseCloud returns the cloud of the configured value, e.g. us-1 or gov1. An empty value is
+// autodiscover, which only works with client credentials.
+func ParseCloud(value string) (falcon.CloudType, error) {
+	cloud, err := falcon.CloudValidate(value)
+	if err != nil {
+		return cloud, fmt.Errorf("unknown Falcon cloud %q, expected us-1, us-2, eu-1, us-gov-1, gov1, gov2 or autodiscover", value)
+	}
+
+	return cloud, nil
+}
+
+// IsGovCloud reports whether the cloud is a GovCloud. GovCloud API clients only exist in their
+// own cloud, so their cloud cannot be autodiscovered from the commercial API.
+func IsGovCloud(cloud falcon.CloudType) bool {
+	switch cloud {
+	case falcon.CloudUsGov1, falcon.CloudGov1, falcon.CloudGov2:
+		return true
+	default:
+		return false
+	}
+}
+
+// VerifyCloud checks that the API client belongs to the cloud, using the region the OAuth2 API
+// reports for a token of the client. GovCloud clouds are not verified, see IsGovCloud.
+func VerifyCloud(ctx context.Context, cloud falcon.CloudType, clientID string, clientSecret string) error {
+	if IsGovCloud(cloud) {
+		return nil
+	}
+
+	discovered := falcon.CloudType(falcon.CloudAutoDiscover)
+	if err := discovered.Autodiscover(ctx, clientID, clientSecret); err != nil {
+		return fmt.Errorf("error verifying the Falcon cloud %s: %v", cloud, err)
+	}
+	if discovered != cloud {
+		return fmt.Errorf("the API client belongs to the %s cloud, not %s: set FALCON_CLOUD to %s", discovered, cloud, discovered)
+	}
+
+	return nil
+}
+
+// cidPattern matches a CID: 32 hex characters, optionally followed by a dash and a checksum.
+var cidPattern = regexp.MustCompile(`^[0-9a-f]{32}(-[0-9a-f]{2})?$`)
+
+// ParseCID returns the lowercase CID, or an error when the value is not a CID.
+func ParseCID(value string) (string, error) {
+	cid := strings.ToLower(strings.TrimSpace(value))
+	if !cidPattern.MatchString(cid) {
+		return "", fmt.Errorf("invalid CID %q, expected 32 hexadecimal characters with an optional -checksum", value)
+	}
+
+	return cid, nil
+}
+
+// MaskCID returns the CID with all but the first and last 4 hexadecimal characters masked, keeping
+// the checksum, e.g. 0123************************cdef-ab.
+func MaskCID(cid string) string {
+	id, checksum, found := strings.Cut(cid, "-")
+	if len(id) <= 8 {
+		return cid
+	}
+
+	masked := id[:4] + strings.Repeat("*", len(id)-8) + id[len(id)-4:]
+	if found {
+		masked += "-" + checksum
+	}
+
+	return masked
+}
+
 // getCID gets the Falcon CID from the CrowdStrike API using the SensorDownload API.
 func GetCID(ctx context.Context, client *client.CrowdStrikeAPISpecification) (string, error) {
 	response, err := client.SensorDownload.GetSensorInstallersCCIDByQuery(&sensor_download.GetSensorInstallersCCIDByQueryParams{
@@ -112,32 +184,169 @@ func getDefaultToken(ctx context.Context, client *client.CrowdStrikeAPISpecifica
 	return valueString, nil
 }
 
-// RegistryToken gets the registry token from the CrowdStrike API using the FalconContainer API.
-func RegistryToken(ctx context.Context, client *client.CrowdStrikeAPISpecification, sensor falcon.SensorType) (string, error) {
-	switch sensor {
-	case falcon.Snapshot:
+// TokenSource identifies the CrowdStrike API that issues a registry credential.
+type TokenSource string
+
+const (
+	TokenSourceFalconContainer TokenSource = "falcon-container"
+	TokenSourceCloudSnapshots  TokenSource = "cloud-snapshots"
+	TokenSourceFCSCli          TokenSource = "fcs-cli"
+)
+
+// Valid reports whether the token source is known.
+func (s TokenSource) Valid() bool {
+	switch s {
+	case TokenSourceFalconContainer, TokenSourceCloudSnapshots, TokenSourceFCSCli:
+		return true
+	default:
+		return false
+	}
+}
+
+// CIDScope is the API scope required to read the CID.
+const CIDScope = "sensor-installers:read"
+
+// Scope returns the API scope required to get a registry credential from the token source.
+func (s TokenSource) Scope() string {
+	switch s {
+	case TokenSourceCloudSnapshots:
+		return "snapshot-scanner:read"
+	case TokenSourceFCSCli:
+		return "iac:read"
+	case TokenSourceFalconContainer:
+		return "falcon-container:read"
+	default:
+		return ""
+	}
+}
+
+// RegistryToken gets the registry token from the CrowdStrike API for the specified token source.
+func RegistryToken(ctx context.Context, client *client.CrowdStrikeAPISpecification, source TokenSource) (string, error) {
+	switch source {
+	case TokenSourceCloudSnapshots:
 		return getSnapshotToken(ctx, client)
-	case falcon.FCSCli:
+	case TokenSourceFCSCli:
 		return getFCSCliToken(ctx, client)
-	default:
+	case TokenSourceFalconContainer:
 		return getDefaultToken(ctx, client)
+	default:
+		return "", fmt.Errorf("unknown token source: %q", source)
 	}
 }
 
-// WriteToCollection writes the image list to the CrowdStrike API using the CustomStorage API.
-func WriteToCollection(client *client.CrowdStrikeAPISpecification, images interface{}) error {
+// ErrObjectNotFound is returned when an object does not exist in a collection.
+var ErrObjectNotFound = errors.New("object not found")
+
+// WriteToCollection writes the image list to the CrowdStrike API using the CustomStorage API,
+// under the key of the image list (see images.ListKey).
+func WriteToCollection(client *client.CrowdStrikeAPISpecification, key string, images interface{}) error {
+	if err := WriteObject(context.Background(), client, "images", key, images); err != nil {
+		return fmt.Errorf("error storing image list in collection: %v", err)
+	}
+
+	return nil
+}
+
+// ReadFromCollection reads the image list stored under the key from the CrowdStrike API using
+// the CustomStorage API.
+func ReadFromCollection(client *client.CrowdStrikeAPISpecification, key string, images interface{}) error {
+	return ReadObject(context.Background(), client, "images", key, images)
+}
+
+// WriteObject encodes the value as JSON and stores it in the collection under the object key.
+func WriteObject(ctx context.Context, client *client.CrowdStrikeAPISpecification, collection string, key string, v interface{}) error {
 	var buf bytes.Buffer
-	if err := json.NewEncoder(&buf).Encode(images); err != nil {
-		return fmt.Errorf("error encoding image list: %v", err)
+	if err := json.NewEncoder(&buf).Encode(v); err != nil {
+		return fmt.Errorf("error encoding %s object: %v", collection, err)
 	}
 
 	_, err := client.CustomStorage.Upload(&custom_storage.UploadParams{
-		CollectionName: "images",
-		ObjectKey:      "all",
+		Context:        ctx,
+		CollectionName: collection,
+		ObjectKey:      key,
 		Body:           io.NopCloser(&buf),
 	})
 	if err != nil {
-		return fmt.Errorf("error storing image list in collection: %v", err)
+		return fmt.Errorf("error writing %s/%s: %v", collection, key, err)
+	}
+
+	return nil
+}
+
+// ReadObject reads the object key from the collection and decodes it into v.
+// ErrObjectNotFound is returned when the object does not exist.
+func ReadObject(ctx context.Context, client *client.CrowdStrikeAPISpecification, collection string, key string, v interface{}) error {
+	var buf bytes.Buffer
+	_, err := client.CustomStorage.Get(&custom_storage.GetParams{
+		Context:        ctx,
+		CollectionName: collection,
+		ObjectKey:      key,
+	}, &buf)
+	if err != nil {
+		var coded interface{ IsCode(int) bool }
+		if errors.As(err, &coded) && coded.IsCode(http.StatusNotFound) {
+			return ErrObjectNotFound
+		}
+		return fmt.Errorf("error reading %s/%s: %v", collection, key, err)
+	}
+
+	if err := json.NewDecoder(&buf).Decode(v); err != nil {
+		return fmt.Errorf("error decoding %s/%s: %v", collection, key, err)
+	}
+
+	return nil
+}
+
+// listPageSize is the number of object keys requested per page.
+const listPageSize = 1000
+
+// ListObjects returns the object keys of the collection.
+func ListObjects(ctx context.Context, client *client.CrowdStrikeAPISpecification, collection string) ([]string, error) {
+	keys := []string{}
+	start := ""
+
+	for {
+		res, err := client.CustomStorage.List(&custom_storage.ListParams{
+			Context:        ctx,
+			CollectionName: collection,
+			Limit:          listPageSize,
+			Start:          start,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error listing %s: %v", collection, err)
+		}
+		payload := res.GetPayload()
+		if err = falcon.AssertNoError(payload.Errors); err != nil {
+			return nil, fmt.Errorf("error listing %s: %v", collection, err)
+		}
+
+		page := payload.Resources
+		// The start key is included in the next page
+		if start != "" && len(page) > 0 && page[0] == start {
+			page = page[1:]
+		}
+		keys = append(keys, page...)
+
+		if len(page) == 0 || len(payload.Resources) < listPageSize {
+			return keys, nil
+		}
+		start = page[len(page)-1]
+	}
+}
+
+// DeleteObject deletes the object key from the collection. Deleting a missing object is not an error.
+func DeleteObject(ctx context.Context, client *client.CrowdStrikeAPISpecification, collection string, key string) error {
+	_, err := client.CustomStorage.Delete(&custom_storage.DeleteParams{
+		Context:        ctx,
+		CollectionName: collection,
+		ObjectKey:      key,
+	})
+	if err != nil {
+		var coded interface{ IsCode(int) bool }
+		if errors.As(err, &coded) && coded.IsCode(http.StatusNotFound) {
+			return nil
+		}
+		return fmt.Errorf("error deleting %s/%s: %v", collection, key, err)
 	}
 
 	return nil
@@ -0,0 +1,135 @@
+package falcon
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/crowdstrike/gofalcon/falcon"
+)
+
+const testCID = "0123456789abcdef0123456789abcdef"
+
+func TestParseCID(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+		err   bool
+	}{
+		{name: "without checksum", value: testCID, want: testCID},
+		{name: "with checksum", value: testCID + "-ab", want: testCID + "-ab"},
+		{name: "uppercase and spaces", value: " " + strings.ToUpper(testCID) + "-AB ", want: testCID + "-ab"},
+		{name: "short", value: testCID[1:], err: true},
+		{name: "not hexadecimal", value: strings.Repeat("g", 32), err: true},
+		{name: "long checksum", value: testCID + "-abc", err: true},
+		{name: "empty", value: "", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCID(tt.value)
+			if (err != nil) != tt.err {
+				t.Fatalf("ParseCID(%q) error = %v, want error %v", tt.value, err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseCID(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaskCID(t *testing.T) {
+	tests := []struct {
+		cid  string
+		want string
+	}{
+		{cid: testCID + "-ab", want: "0123************************cdef-ab"},
+		{cid: testCID, want: "0123************************cdef"},
+		{cid: "01234567", want: "01234567"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cid, func(t *testing.T) {
+			if got := MaskCID(tt.cid); got != tt.want {
+				t.Errorf("MaskCID(%q) = %q, want %q", tt.cid, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCloud(t *testing.T) {
+	tests := []struct {
+		value string
+		want  falcon.CloudType
+		err   bool
+	}{
+		{value: "", want: falcon.CloudAutoDiscover},
+		{value: "autodiscover", want: falcon.CloudAutoDiscover},
+		{value: "us-2", want: falcon.CloudUs2},
+		{value: "EU-1", want: falcon.CloudEu1},
+		{value: "gov1", want: falcon.CloudGov1},
+		{value: "mars-1", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseCloud(tt.value)
+			if (err != nil) != tt.err {
+				t.Fatalf("ParseCloud(%q) error = %v, want error %v", tt.value, err, tt.err)
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("ParseCloud(%q) = %s, want %s", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+// regionAPI answers the OAuth2 token requests for an API client of the region.
+type regionAPI string
+
+func (region regionAPI) RoundTrip(req *http.Request) (*http.Response, error) {
+	res := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`{"meta":{},"errors":[]}`)),
+		Request:    req,
+	}
+	if strings.HasSuffix(req.URL.Path, "/oauth2/token") {
+		res.StatusCode = http.StatusCreated
+		res.Header.Set("X-Cs-Region", string(region))
+		res.Body = io.NopCloser(strings.NewReader(`{"access_token":"token","token_type":"bearer","expires_in":1799}`))
+	}
+
+	return res, nil
+}
+
+func TestVerifyCloud(t *testing.T) {
+	transport := http.DefaultTransport
+	http.DefaultTransport = regionAPI("us-2")
+	t.Cleanup(func() { http.DefaultTransport = transport })
+
+	tests := []struct {
+		cloud falcon.CloudType
+		err   string
+	}{
+		{cloud: falcon.CloudUs2},
+		{cloud: falcon.CloudEu1, err: "set FALCON_CLOUD to us-2"},
+		// GovCloud API clients are unknown to the commercial API, so they are not verified
+		{cloud: falcon.CloudGov1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cloud.String(), func(t *testing.T) {
+			err := VerifyCloud(context.Background(), tt.cloud, "id", "secret")
+			if tt.err == "" && err != nil {
+				t.Errorf("VerifyCloud() error = %v", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Errorf("VerifyCloud() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package feed
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+
+	"syncimages/images"
+)
+
+// Feed formats.
+const (
+	FormatAtom = "atom"
+	FormatRSS  = "rss"
+)
+
+// Content types of the feed formats.
+const (
+	ContentTypeAtom = "application/atom+xml; charset=utf-8"
+	ContentTypeRSS  = "application/rss+xml; charset=utf-8"
+)
+
+// title is the title of the feeds, followed by the sensor name for per-sensor feeds.
+const title = "CrowdStrike sensor releases"
+
+// projectURL is the channel link of RSS feeds.
+const projectURL = "https://github.com/CrowdStrike/foundry-container-registry"
+
+// Entry is a new tag of the feed. Detected is when the sync that found the tag ran: the registry
+// does not provide a release time, so the entries are dated by detection.
+type Entry struct {
+	SensorType string
+	Name       string
+	Repository string
+	Tag        string
+	Version    string
+	Arch       []string
+	Digest     string
+	Detected   time.Time
+	DocsURL    string
+}
+
+// Entries returns an entry per added tag of the change sets, newest first, restricted to the
+// sensor types when set and to limit entries. The image list provides the sensor names and
+// documentation links.
+func Entries(changeSets []images.ChangeSet, list images.ImageList, sensorTypes []string, limit int) []Entry {
+	entries := []Entry{}
+	for _, changeSet := range changeSets {
+		for _, change := range changeSet.Changes {
+			if change.Type != images.ChangeAdded {
+				continue
+			}
+			if len(sensorTypes) > 0 && !contains(sensorTypes, change.SensorType) {
+				continue
+			}
+
+			entry := Entry{
+				SensorType: change.SensorType,
+				Name:       change.SensorType,
+				Repository: change.Repository,
+				Tag:        change.Tag,
+				Version:    change.Tag,
+				Arch:       change.Arch,
+				Digest:     change.Digest,
+				Detected:   change.Time,
+			}
+			if img, ok := list.Find(change.SensorType); ok {
+				entry.Name = img.Name
+				entry.DocsURL = img.DocsURL
+			}
+			if v, ok := images.ParseVersion(change.Tag); ok {
+				entry.Version = v.String()
+			}
+			entries = append(entries, entry)
+
+			if len(entries) == limit {
+				return entries
+			}
+		}
+	}
+
+	return entries
+}
+
+// titleFor returns the feed title for the sensor types.
+func titleFor(entries []Entry, sensorTypes []string) string {
+	if len(sensorTypes) == 0 {
+		return title
+	}
+
+	names := []string{}
+	for _, sensorType := range sensorTypes {
+		name := sensorType
+		for _, entry := range entries {
+			if entry.SensorType == sensorType {
+				name = entry.Name
+				break
+			}
+		}
+		names = append(names, name)
+	}
+
+	return title + ": " + strings.Join(names, ", ")
+}
+
+// id returns a stable identifier of the entry.
+func (e Entry) id() string {
+	return fmt.Sprintf("urn:crowdstrike:image:%s:%s:%s", e.SensorType, e.Tag, e.Digest)
+}
+
+// title returns the title of the entry, e.g. "Falcon Node Sensor 7.20.0-17306".
+func (e Entry) title() string {
+	return e.Name + " " + e.Version
+}
+
+// summary returns the text content of the entry.
+func (e Entry) summary() string {
+	arch := strings.Join(e.Arch, ", ")
+	if arch == "" {
+		arch = "unknown"
+	}
+
+	return fmt.Sprintf("%s %s\nImage: %s:%s\nPlatforms: %s\nDigest: %s\nDetected: %s",
+		e.Name, e.Version, e.Repository, e.Tag, arch, e.Digest, e.Detected.UTC().Format(time.RFC3339))
+}
+
+// Render returns the feed document of the entries in the format for the sensor types.
+func Render(format string, sensorTypes []string, entries []Entry) ([]byte, error) {
+	feedTitle := titleFor(entries, sensorTypes)
+
+	var v interface{}
+	switch format {
+	case FormatRSS:
+		v = rss(feedTitle, entries)
+	default:
+		v = atom(id(sensorTypes), feedTitle, entries)
+	}
+
+	b, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error encoding feed: %v", err)
+	}
+
+	return append([]byte(xml.Header), b...), nil
+}
+
+// id returns the stable identifier of the feed of the sensor types.
+func id(sensorTypes []string) string {
+	if len(sensorTypes) == 0 {
+		return "urn:crowdstrike:feed:sensor-releases"
+	}
+
+	return "urn:crowdstrike:feed:sensor-releases:" + strings.Join(sensorTypes, ",")
+}
+
+// ContentType returns the content type of the format.
+func ContentType(format string) string {
+	if format == FormatRSS {
+		return ContentTypeRSS
+	}
+
+	return ContentTypeAtom
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
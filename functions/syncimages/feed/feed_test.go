@@ -0,0 +1,74 @@
+package feed
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"syncimages/images"
+)
+
+func TestEntries(t *testing.T) {
+	detected := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	changeSets := []images.ChangeSet{{Changes: []images.Change{
+		{Type: images.ChangeAdded, SensorType: "falcon-sensor", Tag: "7.20.0-17306.falcon-linux.Release.US-1", Time: detected},
+		{Type: images.ChangeRemoved, SensorType: "falcon-sensor", Tag: "7.10.0-1"},
+		{Type: images.ChangeAdded, SensorType: "falcon-kac", Tag: "7.21.0-1", Time: detected},
+		{Type: images.ChangeAdded, SensorType: "falcon-kac", Tag: "7.22.0-1", Time: detected},
+	}}}
+	list := images.ImageList{Images: []images.Image{{SensorType: "falcon-sensor", Name: "Falcon Node Sensor"}}}
+
+	tests := []struct {
+		name        string
+		sensorTypes []string
+		limit       int
+		want        []string
+	}{
+		{name: "added tags", limit: 10, want: []string{"Falcon Node Sensor 7.20.0-17306", "falcon-kac 7.21.0-1", "falcon-kac 7.22.0-1"}},
+		{name: "sensor types", sensorTypes: []string{"falcon-kac"}, limit: 10, want: []string{"falcon-kac 7.21.0-1", "falcon-kac 7.22.0-1"}},
+		{name: "limit", limit: 1, want: []string{"Falcon Node Sensor 7.20.0-17306"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries := Entries(changeSets, list, tt.sensorTypes, tt.limit)
+			if len(entries) != len(tt.want) {
+				t.Fatalf("Entries() = %+v, want %v", entries, tt.want)
+			}
+			for i, entry := range entries {
+				if entry.title() != tt.want[i] {
+					t.Errorf("Entries()[%d] title = %q, want %q", i, entry.title(), tt.want[i])
+				}
+				if !entry.Detected.Equal(detected) {
+					t.Errorf("Entries()[%d] detected = %s, want the change time %s", i, entry.Detected, detected)
+				}
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	entries := []Entry{{SensorType: "falcon-kac", Name: "Falcon KAC", Tag: "7.21.0-1", Version: "7.21.0-1", Detected: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)}}
+
+	tests := []struct {
+		format string
+		want   []string
+	}{
+		{format: FormatAtom, want: []string{`<feed xmlns="http://www.w3.org/2005/Atom">`, "<published>2024-06-01T12:00:00Z</published>", "Detected: 2024-06-01T12:00:00Z"}},
+		{format: FormatRSS, want: []string{`<rss version="2.0">`, "<pubDate>Sat, 01 Jun 2024 12:00:00 +0000</pubDate>", "Detected: 2024-06-01T12:00:00Z"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			doc, err := Render(tt.format, []string{"falcon-kac"}, entries)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(doc), want) {
+					t.Errorf("Render() = %s, want it to contain %q", doc, want)
+				}
+			}
+		})
+	}
+}
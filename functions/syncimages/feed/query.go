@@ -0,0 +1,52 @@
+package feed
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Default and maximum number of feed entries.
+const (
+	defaultLimit = 50
+	maxLimit     = 500
+)
+
+// Query selects the feed returned by GET /feed.
+type Query struct {
+	SensorTypes []string
+	Format      string
+	Limit       int
+}
+
+// ParseQuery parses the query parameters sensorType (repeated or comma separated), format
+// (atom or rss) and limit.
+func ParseQuery(values url.Values) (Query, error) {
+	q := Query{Format: values.Get("format"), Limit: defaultLimit}
+	for _, value := range values["sensorType"] {
+		for _, sensorType := range strings.Split(value, ",") {
+			if sensorType = strings.TrimSpace(sensorType); sensorType != "" {
+				q.SensorTypes = append(q.SensorTypes, sensorType)
+			}
+		}
+	}
+
+	switch q.Format {
+	case "":
+		q.Format = FormatAtom
+	case FormatAtom, FormatRSS:
+	default:
+		return q, fmt.Errorf("invalid format %q, expected %s or %s", q.Format, FormatAtom, FormatRSS)
+	}
+
+	if value := values.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxLimit {
+			return q, fmt.Errorf("limit must be between 1 and %d", maxLimit)
+		}
+		q.Limit = limit
+	}
+
+	return q, nil
+}
@@ -0,0 +1,133 @@
+package feed
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomPerson  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published"`
+	Link       *atomLink      `xml:"link,omitempty"`
+	Categories []atomCategory `xml:"category"`
+	Content    atomContent    `xml:"content"`
+}
+
+type atomContent struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	GUID        rssGUID  `xml:"guid"`
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link,omitempty"`
+	Description string   `xml:"description"`
+	PubDate     string   `xml:"pubDate"`
+	Categories  []string `xml:"category"`
+}
+
+type rssGUID struct {
+	Value       string `xml:",chardata"`
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+}
+
+// updated returns the detection time of the newest entry, or now without entries.
+func updated(entries []Entry) time.Time {
+	if len(entries) == 0 {
+		return time.Now().UTC()
+	}
+
+	return entries[0].Detected.UTC()
+}
+
+func atom(feedID string, feedTitle string, entries []Entry) atomFeed {
+	f := atomFeed{
+		ID:      feedID,
+		Title:   feedTitle,
+		Updated: updated(entries).Format(time.RFC3339),
+		Author:  atomPerson{Name: "CrowdStrike"},
+	}
+
+	for _, e := range entries {
+		entry := atomEntry{
+			ID:         e.id(),
+			Title:      e.title(),
+			Updated:    e.Detected.UTC().Format(time.RFC3339),
+			Published:  e.Detected.UTC().Format(time.RFC3339),
+			Categories: []atomCategory{{Term: e.SensorType}},
+			Content:    atomContent{Type: "text", Value: e.summary()},
+		}
+		for _, arch := range e.Arch {
+			entry.Categories = append(entry.Categories, atomCategory{Term: arch})
+		}
+		if e.DocsURL != "" {
+			entry.Link = &atomLink{Href: e.DocsURL}
+		}
+		f.Entries = append(f.Entries, entry)
+	}
+
+	return f
+}
+
+func rss(feedTitle string, entries []Entry) rssFeed {
+	f := rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:         feedTitle,
+			Link:          projectURL,
+			Description:   "New CrowdStrike sensor image tags",
+			LastBuildDate: updated(entries).Format(time.RFC1123Z),
+		},
+	}
+
+	for _, e := range entries {
+		f.Channel.Items = append(f.Channel.Items, rssItem{
+			GUID:        rssGUID{Value: e.id()},
+			Title:       e.title(),
+			Link:        e.DocsURL,
+			Description: e.summary(),
+			PubDate:     e.Detected.UTC().Format(time.RFC1123Z),
+			Categories:  append([]string{e.SensorType}, e.Arch...),
+		})
+	}
+
+	return f
+}
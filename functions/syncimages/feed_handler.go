@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+
+	falconapi "syncimages/falcon"
+	"syncimages/feed"
+	"syncimages/images"
+	"syncimages/runs"
+
+	fdk "github.com/CrowdStrike/foundry-fn-go"
+)
+
+// feedHandler renders the tags added by the recorded syncs as an Atom (default) or RSS feed. The
+// feed document is returned as a file with the feed content type.
+func feedHandler(logger *slog.Logger) fdk.Handler {
+	return fdk.HandlerFn(func(ctx context.Context, r fdk.Request) fdk.Response {
+		q, err := feed.ParseQuery(r.Queries)
+		if err != nil {
+			return errorResponse(http.StatusBadRequest, err)
+		}
+		memberCID, err := memberCIDParam(r.Queries)
+		if err != nil {
+			return errorResponse(http.StatusBadRequest, err)
+		}
+
+		client, err := apiClient(r)
+		if err != nil {
+			logger.Error("failed to create falcon client", "error", err)
+			return errorResponse(http.StatusInternalServerError, err)
+		}
+
+		// The image list only provides the sensor names, the feed is rendered without it
+		imageData, err := readImages(client, memberCID)
+		if err != nil && statusFor(err) != http.StatusNotFound {
+			logger.Error("failed to read images", "error", err)
+			return errorResponse(statusFor(err), err)
+		}
+
+		keys, err := falconapi.ListObjects(ctx, client, images.ChangesCollection)
+		if err != nil {
+			logger.Error("failed to list changes", "error", err)
+			return errorResponse(http.StatusInternalServerError, err)
+		}
+
+		// Change sets are read newest first until the feed is full
+		entries := []feed.Entry{}
+		for _, key := range runs.Newest(keys) {
+			var changeSet images.ChangeSet
+			if err := falconapi.ReadObject(ctx, client, images.ChangesCollection, key, &changeSet); err != nil {
+				logger.Error("failed to read changes", "key", key, "error", err)
+				return errorResponse(http.StatusInternalServerError, err)
+			}
+
+			entries = append(entries, feed.Entries([]images.ChangeSet{changeSet.ForMember(memberCID)}, imageData, q.SensorTypes, q.Limit-len(entries))...)
+			if len(entries) >= q.Limit {
+				break
+			}
+		}
+
+		doc, err := feed.Render(q.Format, q.SensorTypes, entries)
+		if err != nil {
+			return errorResponse(http.StatusInternalServerError, err)
+		}
+
+		return fdk.Response{
+			Code: 200,
+			Body: fdk.File{
+				ContentType: feed.ContentType(q.Format),
+				Contents:    io.NopCloser(bytes.NewReader(doc)),
+			},
+		}
+	})
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	falconapi "syncimages/falcon"
+	"syncimages/images"
+
+	fdk "github.com/CrowdStrike/foundry-fn-go"
+	"github.com/crowdstrike/gofalcon/falcon/client"
+)
+
+// jsonHandler returns a handler responding with the JSON encoding of the result of fn, or with
+// the error of fn and its status code (see statusFor). Server errors are logged.
+func jsonHandler(logger *slog.Logger, fn func(ctx context.Context, r fdk.Request) (interface{}, error)) fdk.Handler {
+	return fdk.HandlerFn(func(ctx context.Context, r fdk.Request) fdk.Response {
+		res, err := fn(ctx, r)
+		if err != nil {
+			code := statusFor(err)
+			if code >= http.StatusInternalServerError {
+				logger.Error("request failed", "method", r.Method, "url", r.URL, "error", err)
+			}
+			return errorResponse(code, err)
+		}
+
+		return fdk.Response{
+			Code: 200,
+			Body: fdk.JSON(res),
+		}
+	})
+}
+
+// apiClient creates the Falcon client of the request.
+func apiClient(r fdk.Request) (*client.CrowdStrikeAPISpecification, error) {
+	client, _, err := newFalconClient(r.AccessToken)
+	if err != nil {
+		return nil, fmt.Errorf("error creating the Falcon client: %w", err)
+	}
+
+	return client, nil
+}
+
+// storedImages creates the Falcon client of the request and reads the stored image list of the
+// member CID, empty for the CID of the app.
+func storedImages(r fdk.Request, memberCID string) (*client.CrowdStrikeAPISpecification, images.ImageList, error) {
+	client, err := apiClient(r)
+	if err != nil {
+		return nil, images.ImageList{}, err
+	}
+
+	imageData, err := readImages(client, memberCID)
+	if err != nil {
+		return nil, images.ImageList{}, err
+	}
+
+	return client, imageData, nil
+}
+
+// pathParamsKey is the context key of the path parameters of a templated route.
+type pathParamsKey struct{}
+
+// withPathParams routes requests matching one of the templated routes (e.g. /images/{name}) to
+// the template registered on the mux, which only matches exact routes. The path parameters are
+// passed to the handler in the context, see pathParam, so they never replace query parameters.
+func withPathParams(mux *fdk.Mux, templates ...string) fdk.Handler {
+	return fdk.HandlerFn(func(ctx context.Context, r fdk.Request) fdk.Response {
+		for _, template := range templates {
+			params, ok := matchRoute(template, r.URL)
+			if !ok {
+				continue
+			}
+
+			ctx = context.WithValue(ctx, pathParamsKey{}, params)
+			r.URL = template
+			break
+		}
+
+		return mux.Handle(ctx, r)
+	})
+}
+
+// pathParam returns the path parameter of the templated route of the request, see withPathParams.
+func pathParam(ctx context.Context, name string) string {
+	params, _ := ctx.Value(pathParamsKey{}).(map[string]string)
+	return params[name]
+}
+
+// matchRoute matches the path against a route template and returns the path parameters.
+func matchRoute(template string, path string) (map[string]string, bool) {
+	templateParts := strings.Split(strings.Trim(template, "/"), "/")
+	pathParts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(templateParts) != len(pathParts) || template == path {
+		return nil, false
+	}
+
+	params := map[string]string{}
+	for i, part := range templateParts {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			value, err := url.PathUnescape(pathParts[i])
+			if err != nil || value == "" {
+				return nil, false
+			}
+			params[strings.Trim(part, "{}")] = value
+			continue
+		}
+		if part != pathParts[i] {
+			return nil, false
+		}
+	}
+
+	return params, true
+}
+
+// readImages reads the stored image list of the member CID, empty for the CID of the app, from
+// the images collection.
+func readImages(client *client.CrowdStrikeAPISpecification, memberCID string) (images.ImageList, error) {
+	var imageData images.ImageList
+	err := falconapi.ReadFromCollection(client, images.ListKey(memberCID), &imageData)
+	if errors.Is(err, falconapi.ErrObjectNotFound) {
+		if memberCID != "" {
+			return images.ImageList{}, notFound(fmt.Errorf("no images have been synced yet for member CID %s", memberCID))
+		}
+		return images.ImageList{}, notFound(fmt.Errorf("no images have been synced yet"))
+	}
+
+	return imageData, err
+}
+
+// memberCIDParam returns the MSSP member CID selected by the memberCid query parameter, empty
+// for the CID of the app.
+func memberCIDParam(values url.Values) (string, error) {
+	value := values.Get("memberCid")
+	if value == "" {
+		return "", nil
+	}
+
+	cid, err := falconapi.ParseCID(value)
+	if err != nil {
+		return "", fmt.Errorf("memberCid: %v", err)
+	}
+
+	return cid, nil
+}
+
+// statusError is an error carrying the HTTP status code returned to the caller.
+type statusError struct {
+	code int
+	err  error
+}
+
+func (e statusError) Error() string { return e.err.Error() }
+
+func (e statusError) Unwrap() error { return e.err }
+
+// badRequest wraps the error with a 400 status code.
+func badRequest(err error) error {
+	return statusError{code: http.StatusBadRequest, err: err}
+}
+
+// notFound wraps the error with a 404 status code.
+func notFound(err error) error {
+	return statusError{code: http.StatusNotFound, err: err}
+}
+
+// statusFor returns the HTTP status code of the error, defaulting to 500.
+func statusFor(err error) int {
+	var se statusError
+	if errors.As(err, &se) {
+		return se.code
+	}
+
+	return http.StatusInternalServerError
+}
+
+// errorResponse returns a response with the status code and the error message.
+func errorResponse(code int, err error) fdk.Response {
+	return fdk.Response{
+		Code: code,
+		Body: fdk.JSON(map[string]interface{}{
+			"error": err.Error(),
+		}),
+	}
+}
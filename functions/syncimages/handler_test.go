@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+
+	fdk "github.com/CrowdStrike/foundry-fn-go"
+)
+
+func TestMatchRoute(t *testing.T) {
+	tests := []struct {
+		template string
+		path     string
+		want     map[string]string
+	}{
+		{template: "/images/{name}", path: "/images/falcon-kac", want: map[string]string{"name": "falcon-kac"}},
+		{template: "/images/{name}", path: "/images/falcon-kac/", want: map[string]string{"name": "falcon-kac"}},
+		{template: "/images/{name}", path: "/images/Falcon%20KAC", want: map[string]string{"name": "Falcon KAC"}},
+		{template: "/credentials/{ref}", path: "/credentials/member-abc-fc", want: map[string]string{"ref": "member-abc-fc"}},
+		{template: "/images/{name}", path: "/images"},
+		{template: "/images/{name}", path: "/images/a/b"},
+		{template: "/images/{name}", path: "/sync-jobs/1"},
+		{template: "/images/{name}", path: "/images/{name}"},
+		{template: "/images/{name}", path: "/images/%zz"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.template+" "+tt.path, func(t *testing.T) {
+			got, ok := matchRoute(tt.template, tt.path)
+			if ok != (tt.want != nil) {
+				t.Fatalf("matchRoute(%q, %q) ok = %v, want %v", tt.template, tt.path, ok, tt.want != nil)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("matchRoute(%q, %q) = %v, want %v", tt.template, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithPathParams(t *testing.T) {
+	mux := fdk.NewMux()
+	mux.Get("/images", fdk.HandlerFn(func(ctx context.Context, r fdk.Request) fdk.Response {
+		return fdk.Response{Code: 200, Body: fdk.JSON(map[string]string{"route": "list", "name": pathParam(ctx, "name")})}
+	}))
+	mux.Get("/images/{name}", fdk.HandlerFn(func(ctx context.Context, r fdk.Request) fdk.Response {
+		return fdk.Response{Code: 200, Body: fdk.JSON(map[string]string{"route": "image", "name": pathParam(ctx, "name"), "query": r.Queries.Get("name")})}
+	}))
+	handler := withPathParams(mux, "/images/{name}")
+
+	tests := []struct {
+		name    string
+		url     string
+		queries url.Values
+		want    string
+	}{
+		{name: "exact route", url: "/images", want: `{"name":"","route":"list"}`},
+		{name: "templated route", url: "/images/falcon-kac", want: `{"name":"falcon-kac","query":"","route":"image"}`},
+		{
+			name:    "query parameter kept",
+			url:     "/images/falcon-kac",
+			queries: url.Values{"name": {"user"}},
+			want:    `{"name":"falcon-kac","query":"user","route":"image"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := handler.Handle(context.Background(), fdk.Request{Method: http.MethodGet, URL: tt.url, Queries: tt.queries})
+			b, err := res.Body.MarshalJSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tt.want {
+				t.Errorf("body = %s, want %s", b, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONHandler(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code int
+	}{
+		{name: "ok", code: http.StatusOK},
+		{name: "bad request", err: badRequest(errors.New("invalid")), code: http.StatusBadRequest},
+		{name: "not found", err: notFound(errors.New("missing")), code: http.StatusNotFound},
+		{name: "server error", err: errors.New("failed"), code: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := jsonHandler(newLogger(io.Discard, false, false), func(ctx context.Context, r fdk.Request) (interface{}, error) {
+				return map[string]string{"status": "ok"}, tt.err
+			})
+			if res := handler.Handle(context.Background(), fdk.Request{}); res.Code != tt.code {
+				t.Errorf("code = %d, want %d", res.Code, tt.code)
+			}
+		})
+	}
+}
+
+func TestMemberCIDParam(t *testing.T) {
+	tests := []struct {
+		name  string
+		query url.Values
+		want  string
+		err   bool
+	}{
+		{name: "app"},
+		{name: "member", query: url.Values{"memberCid": {"0123456789ABCDEF0123456789ABCDEF-AB"}}, want: testMemberCID},
+		{name: "invalid", query: url.Values{"memberCid": {"member"}}, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := memberCIDParam(tt.query)
+			if (err != nil) != tt.err {
+				t.Fatalf("memberCIDParam() error = %v, want error %v", err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("memberCIDParam() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,429 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"syncimages/api"
+	"syncimages/catalog"
+	"syncimages/config"
+	"syncimages/credentials"
+	"syncimages/diagnose"
+	"syncimages/discovery"
+	falconapi "syncimages/falcon"
+	"syncimages/images"
+	"syncimages/jobs"
+	"syncimages/registry"
+	"syncimages/runs"
+	"syncimages/version"
+
+	"github.com/crowdstrike/gofalcon/falcon"
+	"github.com/crowdstrike/gofalcon/falcon/client"
+)
+
+// getImages returns a list of images and tags from the CrowdStrike API for the sensor types
+// selected by the request, and the outcome of every sensor type for the run history. Sensor
+// types the tenant is not entitled to are marked instead of failing the sync, and are skipped
+// until the entitlement recheck interval has passed.
+func getImages(ctx context.Context, client *client.CrowdStrikeAPISpecification, memberCID string, cloud string, cfg config.Config, cat catalog.Catalog, req api.SyncRequest, previous images.ImageList, tracker *jobs.Tracker) (images.ImageList, []runs.SensorRun, error) {
+	slog.Info("Starting image retrieval process", "cloud", cloud, "member_cid", memberCID)
+	startTime := time.Now()
+
+	cid, err := falconapi.GetCID(ctx, client)
+	if err != nil {
+		return images.ImageList{}, nil, fmt.Errorf("error getting Falcon CID from the %s cloud: %v", cloud, err)
+	}
+	slog.Debug("Retrieved CID successfully", "cid", cid)
+
+	type sensorResult struct {
+		image images.Image
+		run   runs.SensorRun
+		index int
+		err   error
+	}
+
+	targets := []catalog.Target{}
+	for _, target := range cat.Targets(falcon.Cloud(cloud)) {
+		if req.Includes(target.SensorType) {
+			targets = append(targets, target)
+		}
+	}
+	resultChan := make(chan sensorResult, len(targets))
+	var wg sync.WaitGroup
+
+	recheck := cfg.Sync.EntitlementRecheck()
+	for i, target := range targets {
+		before, ok := previous.Find(target.SensorType)
+		if ok && before.State == images.StateNotEntitled && !req.Force && time.Since(before.Updated) < recheck {
+			slog.Info("Skipping sensor type without entitlement", "type", target.SensorType, "recheck_after", before.Updated.Add(recheck))
+			tracker.SensorFinished(target.SensorType, nil)
+			resultChan <- sensorResult{
+				image: before,
+				run: runs.SensorRun{
+					SensorType: target.SensorType,
+					State:      runs.StateNotEntitled,
+					Reason:     strings.SplitN(before.StateReason, ":", 2)[0],
+				},
+				index: i,
+			}
+			continue
+		}
+
+		slog.Info("Processing sensor type", "type", target.SensorType, "source", target.Source)
+		wg.Add(1)
+		go func(target catalog.Target, index int) {
+			defer wg.Done()
+			sensorStart := time.Now()
+			stats := &registry.Stats{}
+			imageInfo, err := getImage(ctx, client, cid, target, imageOptions{
+				tagLimit:  req.Limit(target.SensorType),
+				archs:     req.Archs,
+				memberCID: memberCID,
+				tracker:   tracker,
+				stats:     stats,
+			})
+
+			run := runs.SensorRun{
+				SensorType:    target.SensorType,
+				State:         runs.StateSucceeded,
+				DurationMs:    time.Since(sensorStart).Milliseconds(),
+				Tags:          len(imageInfo.Tags),
+				RegistryCalls: stats.Calls(),
+				Retries:       stats.Retries(),
+			}
+			if reason, ok := diagnose.Entitlement(err); err != nil && ok {
+				slog.Warn("Sensor type not entitled, skipping it", "type", target.SensorType, "reason", reason, "error", err)
+				imageInfo = notEntitledImage(target, reason, err)
+				run.State = runs.StateNotEntitled
+				run.Reason = reason
+				run.Error = err.Error()
+				err = nil
+			} else if err != nil {
+				run.State = runs.StateFailed
+				run.Error = err.Error()
+			}
+			tracker.SensorFinished(target.SensorType, err)
+
+			resultChan <- sensorResult{
+				image: imageInfo,
+				run:   run,
+				index: index,
+				err:   err,
+			}
+		}(target, i)
+	}
+
+	// Close result channel once all goroutines complete
+	go func() {
+		wg.Wait()
+		close(resultChan)
+	}()
+
+	// Collect all results, so the run history records every failed sensor type
+	results := make([]sensorResult, 0, len(targets))
+	for result := range resultChan {
+		results = append(results, result)
+	}
+
+	// Sort results based on original index
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].index < results[j].index
+	})
+
+	// Extract images and sensor runs in correct order
+	synced := make([]images.Image, len(results))
+	sensorRuns := make([]runs.SensorRun, len(results))
+	for i, result := range results {
+		synced[i] = result.image
+		sensorRuns[i] = result.run
+	}
+	for _, result := range results {
+		if result.err != nil {
+			return images.ImageList{}, sensorRuns, result.err
+		}
+	}
+
+	var discovered *discovery.Result
+	if cfg.Discovery.Enabled {
+		result := discoverRepositories(ctx, targets, synced, cfg.Discovery.Prefixes)
+		discovered = &result
+	}
+
+	maskedCID := cid
+	if !cfg.Sync.UnmaskCID {
+		maskedCID = falconapi.MaskCID(cid)
+	}
+	regInfo := images.ImageList{
+		Updated:    time.Now(),
+		DurationMs: time.Since(startTime).Milliseconds(),
+		Images:     synced,
+		Discovered: discovered,
+		Metadata: &images.Metadata{
+			SchemaVersion:   images.SchemaVersion,
+			CID:             maskedCID,
+			Cloud:           cloud,
+			RegistryHost:    catalog.RegistryHost(falcon.Cloud(cloud)),
+			FunctionVersion: version.Current(),
+			Dependencies:    version.Dependencies(),
+		},
+	}
+
+	slog.Info("Completed image retrieval", "duration_ms", regInfo.DurationMs, "image_count", len(regInfo.Images))
+	return regInfo, sensorRuns, nil
+}
+
+// imageOptions are the per sync options of getImage.
+type imageOptions struct {
+	// tagLimit keeps only the newest tags, 0 keeps all tags.
+	tagLimit int
+	// archs keeps only the tags available for one of the architectures when set.
+	archs []string
+	// memberCID is the MSSP member CID synced, empty for the CID of the app.
+	memberCID string
+	// tracker receives the progress of asynchronous syncs, nil otherwise.
+	tracker *jobs.Tracker
+	// stats counts the registry calls.
+	stats *registry.Stats
+}
+
+// notEntitledImage returns the image of a target the tenant is not entitled to, without tags.
+func notEntitledImage(target catalog.Target, reason string, err error) images.Image {
+	return images.Image{
+		SensorType:  target.SensorType,
+		Source:      target.Source,
+		Name:        target.Name,
+		Description: target.Description,
+		DocsURL:     target.DocsURL,
+		Registry:    strings.Split(target.Repository, "/")[0],
+		Repository:  target.Repository,
+		Tags:        []images.Tag{},
+		Updated:     time.Now(),
+		State:       images.StateNotEntitled,
+		StateReason: fmt.Sprintf("%s: %v", reason, err),
+	}
+}
+
+// getImage returns the image and its tags for a single catalog target.
+func getImage(ctx context.Context, client *client.CrowdStrikeAPISpecification, cid string, target catalog.Target, opts imageOptions) (images.Image, error) {
+	startTime := time.Now()
+	imageInfo := images.Image{
+		SensorType:  target.SensorType,
+		Source:      target.Source,
+		Name:        target.Name,
+		Description: target.Description,
+		DocsURL:     target.DocsURL,
+	}
+
+	slog.Debug("Getting registry credentials", "sensor_type", target.SensorType, "credentials_source", target.Credentials.Source, "login_prefix", target.Credentials.LoginPrefix, "token_source", target.Credentials.TokenSource)
+	user, pass, err := registryCredentials(ctx, client, cid, target.Credentials)
+	if err != nil {
+		return images.Image{}, fmt.Errorf("error getting registry token for %v: %w", target.SensorType, err)
+	}
+
+	rc := registry.NewRegistryConfig(user, pass).WithContext(ctx).WithStats(opts.stats)
+	if target.Insecure {
+		rc = rc.Insecure()
+	}
+	imageInfo.CredentialRef = credentials.MemberRef(opts.memberCID, target.CredentialRef())
+	imageInfo.Login = user
+	imageInfo.Password = pass
+
+	sensor := target.Repository
+	slog.Debug("Constructed sensor URI", "sensor_type", target.SensorType, "uri", sensor)
+
+	imageInfo.Registry = strings.Split(sensor, "/")[0]
+	imageInfo.Repository = sensor
+
+	dockerConfigJson := rc.DockerConfigJson(imageInfo.Registry)
+	slog.Debug("Generated docker config", "registry", imageInfo.Registry, "config_length", len(dockerConfigJson))
+	imageInfo.DockerJson = dockerConfigJson
+
+	slog.Debug("Getting repository tags", "repository", sensor)
+	tags, err := rc.GetRepositoryTags(sensor)
+	if err != nil {
+		return images.Image{}, fmt.Errorf("error listing repository tags for %v: %w", sensor, err)
+	}
+	slog.Debug("Retrieved tags", "repository", sensor, "tag_count", len(tags), "tags", tags)
+
+	// The release positions are computed on all the tags, before the tag limit and architecture
+	// filter of the request.
+	all := target.ProcessTags(tags)
+	tags = images.NewestTags(all, opts.tagLimit)
+
+	opts.tracker.SensorStarted(target.SensorType, len(tags))
+	if err := processTagsConcurrently(tags, &imageInfo, rc, opts.tracker); err != nil {
+		return images.Image{}, fmt.Errorf("error processing tags for %v: %v", target.SensorType, err)
+	}
+	// The tags excluded by the architecture filter were fetched, so they are not unsynced and the
+	// stored ones are not kept.
+	imageInfo.SetUnsynced(all)
+	if len(opts.archs) > 0 {
+		imageInfo.FilterArchs(opts.archs)
+	}
+
+	imageInfo.SetLatest()
+	imageInfo.SetReleases(all)
+	imageInfo.SetSupport(target.SupportedReleases)
+	imageInfo.Updated = time.Now()
+	imageInfo.DurationMs = time.Since(startTime).Milliseconds()
+	slog.Debug("Computed latest tags", "repository", imageInfo.Repository, "latest", imageInfo.LatestTag, "latest_by_arch", imageInfo.LatestByArch, "latest_by_stream", imageInfo.LatestByStream)
+
+	return imageInfo, nil
+}
+
+// discoverRepositories reports registry repositories not covered by the catalog, using the
+// credentials already obtained for the synced images. It stops with the sync context.
+func discoverRepositories(ctx context.Context, targets []catalog.Target, synced []images.Image, prefixes []string) discovery.Result {
+	known := make([]string, 0, len(synced))
+	credentials := make([]discovery.Credential, 0, len(synced))
+	defaultPrefixes := []string{}
+	seenPrefixes := map[string]bool{}
+
+	for i, image := range synced {
+		known = append(known, image.Repository)
+		if image.State == images.StateNotEntitled {
+			continue
+		}
+		credentials = append(credentials, discovery.Credential{
+			Registry: image.Registry,
+			User:     image.Login,
+			Pass:     image.Password,
+			Insecure: targets[i].Insecure,
+		})
+
+		// Default to the first path segment (namespace) of every catalog repository
+		path := strings.TrimPrefix(image.Repository, image.Registry+"/")
+		prefix := strings.Split(path, "/")[0] + "/"
+		if !seenPrefixes[prefix] {
+			seenPrefixes[prefix] = true
+			defaultPrefixes = append(defaultPrefixes, prefix)
+		}
+	}
+
+	// Only configured prefixes are walked, the namespaces of the catalog hold no new ones
+	walk := len(prefixes) > 0
+	if !walk {
+		prefixes = defaultPrefixes
+	}
+
+	slog.Info("Discovering unclassified repositories", "prefixes", prefixes, "walk", walk)
+	return discovery.Discover(ctx, credentials, known, prefixes, walk)
+}
+
+// registryCredentials returns the registry user and password for the credentials source.
+func registryCredentials(ctx context.Context, client *client.CrowdStrikeAPISpecification, cid string, creds catalog.Credentials) (string, string, error) {
+	switch creds.Source {
+	case catalog.CredentialSourceFalcon:
+		user := falconapi.RegistryLogin(creds.LoginPrefix, cid)
+		pass, err := falconapi.RegistryToken(ctx, client, creds.TokenSource)
+		return user, pass, err
+	case catalog.CredentialSourceStatic:
+		return creds.Username, creds.StaticPassword(), nil
+	default:
+		return "", "", nil
+	}
+}
+
+// processTagsConcurrently processes container image tags concurrently, reporting each processed
+// tag to the tracker.
+func processTagsConcurrently(tags []string, imageInfo *images.Image, rc registry.Config, tracker *jobs.Tracker) error {
+	type result struct {
+		tag    string
+		digest string
+		archs  []string
+		err    error
+		index  int
+	}
+
+	resultChan := make(chan result, len(tags))
+	var wg sync.WaitGroup
+
+	// Create a semaphore to limit concurrent operations
+	maxConcurrent := 10
+	semaphore := make(chan struct{}, maxConcurrent)
+
+	// Launch goroutines for each tag
+	for i, tag := range tags {
+		wg.Add(1)
+		go func(tag string, index int) {
+			// Acquire semaphore
+			semaphore <- struct{}{}
+			defer func() {
+				// Release semaphore
+				<-semaphore
+				wg.Done()
+			}()
+
+			slog.Debug("Processing image tag", "repository", imageInfo.Repository, "tag", tag)
+
+			digest, err := rc.GetImageDigest(imageInfo.Repository, tag)
+			if err != nil {
+				resultChan <- result{
+					tag:   tag,
+					err:   fmt.Errorf("error getting digest for tag: %v", err),
+					index: index,
+				}
+				return
+			}
+
+			archs := archInTag(tag, *imageInfo, rc)
+			slog.Debug("Image tag details", "tag", tag, "digest", digest, "architectures", archs)
+
+			resultChan <- result{
+				tag:    tag,
+				digest: digest,
+				archs:  archs,
+				index:  index,
+			}
+		}(tag, i)
+	}
+
+	// Close result channel once all goroutines complete
+	go func() {
+		wg.Wait()
+		close(resultChan)
+	}()
+
+	// Collect results
+	results := make([]result, 0, len(tags))
+	for r := range resultChan {
+		if r.err != nil {
+			return fmt.Errorf("error getting digest: %w", r.err)
+		}
+		tracker.TagProcessed(imageInfo.SensorType)
+		results = append(results, r)
+	}
+
+	// Sort results based on original index
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].index < results[j].index
+	})
+
+	// Append sorted results to imageInfo.Tags
+	for _, r := range results {
+		imageInfo.Tags = append(imageInfo.Tags, images.Tag{
+			Name:   r.tag,
+			Digest: r.digest,
+			Arch:   r.archs,
+		})
+	}
+
+	return nil
+}
+
+// archInTag returns the architecture from the tag.
+func archInTag(tag string, imageInfo images.Image, rc registry.Config) []string {
+	archs, err := rc.GetImageArchitecture(imageInfo.Repository, tag)
+	if err != nil {
+		slog.Warn("Failed to get architectures from manifest", "repository", imageInfo.Repository, "tag", tag, "error", err, "falling_back_to", []string{"unknown"})
+		return []string{"unknown"}
+	}
+
+	slog.Debug("Got architectures from manifest", "repository", imageInfo.Repository, "tag", tag, "architectures", archs)
+	return archs
+}
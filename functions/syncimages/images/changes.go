@@ -0,0 +1,126 @@
+package images
+
+import "time"
+
+// ChangesCollection is the collection storing a ChangeSet for every sync that changed tags,
+// keyed by the sync run ID.
+const ChangesCollection = "changes"
+
+// Change types.
+const (
+	ChangeAdded         = "added"
+	ChangeRemoved       = "removed"
+	ChangeDigestChanged = "digestChanged"
+	// ChangeCredentials is an image whose registry login or password changed. The change does
+	// not carry the credentials.
+	ChangeCredentials = "credentialsChanged"
+)
+
+// Change is a tag added, removed or re-pointed to a new digest between two syncs, or new
+// credentials of an image (see the credentials package).
+type Change struct {
+	Type           string    `json:"type"`
+	SensorType     string    `json:"sensorType"`
+	Repository     string    `json:"repository"`
+	Tag            string    `json:"tag,omitempty"`
+	Digest         string    `json:"digest,omitempty"`
+	PreviousDigest string    `json:"previousDigest,omitempty"`
+	Arch           []string  `json:"arch,omitempty"`
+	Time           time.Time `json:"time"`
+	// MemberCID is the MSSP member CID of the image, empty for the CID of the app.
+	MemberCID string `json:"memberCid,omitempty"`
+}
+
+// ChangeSet is the document stored in the changes collection.
+type ChangeSet struct {
+	ID      string    `json:"id"`
+	Time    time.Time `json:"time"`
+	Changes []Change  `json:"changes"`
+}
+
+// ForMember returns the change set with only the changes of the member CID, empty for the CID of
+// the app.
+func (s ChangeSet) ForMember(memberCID string) ChangeSet {
+	changes := []Change{}
+	for _, change := range s.Changes {
+		if change.MemberCID == memberCID {
+			changes = append(changes, change)
+		}
+	}
+	s.Changes = changes
+
+	return s
+}
+
+// Diff returns the changes of the tags since the previous list. Images that were not stored
+// before are new to the catalog and have no changes, so the first sync does not report every tag.
+// The same applies to images the tenant was or is not entitled to.
+// Tags the syncs did not fetch or excluded by architecture (see Image.Unsynced and Image.Filtered)
+// are still in the registry: they are neither added when a later sync fetches them nor removed
+// when a sync skips them.
+func (l ImageList) Diff(previous ImageList) []Change {
+	changes := []Change{}
+
+	for _, img := range l.Images {
+		before, ok := previous.Find(img.SensorType)
+		if !ok || before.State == StateNotEntitled || img.State == StateNotEntitled {
+			continue
+		}
+
+		beforeTags := map[string]Tag{}
+		for _, tag := range before.Tags {
+			beforeTags[tag.Name] = tag
+		}
+		beforeUnsynced := map[string]bool{}
+		for _, name := range append(append([]string{}, before.Unsynced...), before.Filtered...) {
+			beforeUnsynced[name] = true
+		}
+		currentTags := map[string]bool{}
+		for _, name := range append(append([]string{}, img.Unsynced...), img.Filtered...) {
+			currentTags[name] = true
+		}
+
+		for _, tag := range img.Tags {
+			currentTags[tag.Name] = true
+			change := Change{
+				SensorType: img.SensorType,
+				Repository: img.Repository,
+				Tag:        tag.Name,
+				Digest:     tag.Digest,
+				Arch:       tag.Arch,
+				Time:       l.Updated,
+			}
+
+			beforeTag, existed := beforeTags[tag.Name]
+			switch {
+			case !existed && beforeUnsynced[tag.Name]:
+				continue
+			case !existed:
+				change.Type = ChangeAdded
+			case beforeTag.Digest != tag.Digest:
+				change.Type = ChangeDigestChanged
+				change.PreviousDigest = beforeTag.Digest
+			default:
+				continue
+			}
+			changes = append(changes, change)
+		}
+
+		for _, tag := range before.Tags {
+			if currentTags[tag.Name] {
+				continue
+			}
+			changes = append(changes, Change{
+				Type:           ChangeRemoved,
+				SensorType:     img.SensorType,
+				Repository:     img.Repository,
+				Tag:            tag.Name,
+				PreviousDigest: tag.Digest,
+				Arch:           tag.Arch,
+				Time:           l.Updated,
+			})
+		}
+	}
+
+	return changes
+}
@@ -0,0 +1,112 @@
+package images
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiff(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	image := func(tags []Tag, unsynced ...string) Image {
+		return Image{SensorType: "falcon-sensor", Tags: tags, Unsynced: unsynced}
+	}
+	a := Tag{Name: "a", Digest: "sha256:a"}
+	b := Tag{Name: "b", Digest: "sha256:b"}
+	moved := Tag{Name: "a", Digest: "sha256:c"}
+
+	tests := []struct {
+		name     string
+		previous []Image
+		current  Image
+		want     []string
+	}{
+		{name: "first sync", current: image([]Tag{a}), want: nil},
+		{name: "unchanged", previous: []Image{image([]Tag{a})}, current: image([]Tag{a}), want: nil},
+		{name: "added", previous: []Image{image([]Tag{a})}, current: image([]Tag{a, b}), want: []string{ChangeAdded + " b"}},
+		{name: "removed", previous: []Image{image([]Tag{a, b})}, current: image([]Tag{a}), want: []string{ChangeRemoved + " b"}},
+		{name: "digest changed", previous: []Image{image([]Tag{a})}, current: image([]Tag{moved}), want: []string{ChangeDigestChanged + " a"}},
+		{name: "previously unsynced", previous: []Image{image([]Tag{a}, "b")}, current: image([]Tag{a, b}), want: nil},
+		{name: "skipped by the sync", previous: []Image{image([]Tag{a, b})}, current: image([]Tag{a}, "b"), want: nil},
+		{
+			name:     "not entitled before",
+			previous: []Image{{SensorType: "falcon-sensor", State: StateNotEntitled}},
+			current:  image([]Tag{a}),
+			want:     nil,
+		},
+		{
+			name:     "not entitled now",
+			previous: []Image{image([]Tag{a, b})},
+			current:  Image{SensorType: "falcon-sensor", State: StateNotEntitled, Tags: []Tag{}},
+			want:     nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := ImageList{Updated: now, Images: []Image{tt.current}}
+			changes := l.Diff(ImageList{Images: tt.previous})
+
+			if len(changes) != len(tt.want) {
+				t.Fatalf("Diff = %+v, want %v", changes, tt.want)
+			}
+			for i, change := range changes {
+				if got := change.Type + " " + change.Tag; got != tt.want[i] {
+					t.Errorf("change %d = %q, want %q", i, got, tt.want[i])
+				}
+				if !change.Time.Equal(now) {
+					t.Errorf("change %d time = %v, want %v", i, change.Time, now)
+				}
+			}
+		})
+	}
+}
+
+func TestDiffDetails(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	image := func(tags ...Tag) Image {
+		return Image{SensorType: "falcon-sensor", Repository: "registry.example.com/falcon-sensor", Tags: tags}
+	}
+	previous := ImageList{Images: []Image{image(
+		Tag{Name: "moved", Digest: "sha256:a", Arch: []string{"x86_64"}},
+		Tag{Name: "gone", Digest: "sha256:b", Arch: []string{"aarch64"}},
+	)}}
+	l := ImageList{Updated: now, Images: []Image{image(
+		Tag{Name: "moved", Digest: "sha256:c", Arch: []string{"x86_64"}},
+		Tag{Name: "new", Digest: "sha256:d", Arch: []string{"x86_64", "aarch64"}},
+	)}}
+
+	want := []Change{
+		{Type: ChangeDigestChanged, Tag: "moved", Digest: "sha256:c", PreviousDigest: "sha256:a", Arch: []string{"x86_64"}},
+		{Type: ChangeAdded, Tag: "new", Digest: "sha256:d", Arch: []string{"x86_64", "aarch64"}},
+		{Type: ChangeRemoved, Tag: "gone", PreviousDigest: "sha256:b", Arch: []string{"aarch64"}},
+	}
+	for i := range want {
+		want[i].SensorType, want[i].Repository, want[i].Time = "falcon-sensor", "registry.example.com/falcon-sensor", now
+	}
+
+	if got := l.Diff(previous); !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff() = %+v, want %+v", got, want)
+	}
+}
+
+func TestChangeSetForMember(t *testing.T) {
+	set := ChangeSet{ID: "run", Changes: []Change{{Tag: "a"}, {Tag: "b", MemberCID: "member"}}}
+
+	tests := []struct {
+		memberCID string
+		want      string
+	}{
+		{memberCID: "", want: "a"},
+		{memberCID: "member", want: "b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			got := set.ForMember(tt.memberCID)
+			if len(got.Changes) != 1 || got.Changes[0].Tag != tt.want {
+				t.Errorf("ForMember(%q) = %+v, want tag %s", tt.memberCID, got.Changes, tt.want)
+			}
+		})
+	}
+}
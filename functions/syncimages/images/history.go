@@ -0,0 +1,180 @@
+package images
+
+import (
+	"sort"
+	"time"
+)
+
+// CarryOver copies the first seen time and the digest history of the tags from the previously
+// stored image list, and records the current digest of every tag as seen now. Tags that were not
+// stored before are first seen now. A tag that pointed to another digest before is mutated.
+func (l *ImageList) CarryOver(previous ImageList, now time.Time) {
+	stored := map[string]Tag{}
+	for _, img := range previous.Images {
+		for _, tag := range img.Tags {
+			stored[img.SensorType+"/"+tag.Name] = tag
+		}
+	}
+
+	for i := range l.Images {
+		img := &l.Images[i]
+		for j := range img.Tags {
+			tag := &img.Tags[j]
+			tag.FirstSeen = now
+			tag.Digests = nil
+			if before, ok := stored[img.SensorType+"/"+tag.Name]; ok {
+				if !before.FirstSeen.IsZero() {
+					tag.FirstSeen = before.FirstSeen
+				}
+				tag.Digests = before.digestHistory()
+			}
+			tag.observe(now)
+		}
+	}
+}
+
+// digestHistory returns the digest history of a stored tag. Tags stored before the history was
+// recorded start it with their digest.
+func (t Tag) digestHistory() []DigestSeen {
+	if len(t.Digests) > 0 {
+		return append([]DigestSeen{}, t.Digests...)
+	}
+	if t.Digest == "" {
+		return nil
+	}
+
+	lastSeen := t.LastSeen
+	if lastSeen.IsZero() {
+		lastSeen = t.FirstSeen
+	}
+
+	return []DigestSeen{{Digest: t.Digest, FirstSeen: t.FirstSeen, LastSeen: lastSeen}}
+}
+
+// observe records the current digest of the tag as seen now and flags the tag as mutated when
+// its history holds other digests.
+func (t *Tag) observe(now time.Time) {
+	t.LastSeen = now
+
+	if n := len(t.Digests); n > 0 && t.Digests[n-1].Digest == t.Digest {
+		t.Digests[n-1].LastSeen = now
+	} else {
+		t.Digests = append(t.Digests, DigestSeen{Digest: t.Digest, FirstSeen: now, LastSeen: now})
+	}
+
+	t.PreviousDigests = nil
+	seen := map[string]bool{t.Digest: true}
+	for i := len(t.Digests) - 1; i >= 0; i-- {
+		if digest := t.Digests[i].Digest; !seen[digest] {
+			seen[digest] = true
+			t.PreviousDigests = append(t.PreviousDigests, digest)
+		}
+	}
+	t.Mutated = len(t.PreviousDigests) > 0
+}
+
+// KeepStoredTags adds the stored tags of the previous list that the sync did not fetch (see
+// Unsynced) to the synced images, with their history, so a sync with a tag limit does not drop
+// them from the stored list. The tags are inserted in version order, see insertTag. Stored tags no
+// longer in the registry, or excluded by the architecture filter (see Filtered), are not kept.
+// The latest tags and release labels are computed again on all the tags.
+// CarryOver must be called first, so the kept tags are not marked as seen by this sync.
+func (l *ImageList) KeepStoredTags(previous ImageList) {
+	for i := range l.Images {
+		img := &l.Images[i]
+		before, ok := previous.Find(img.SensorType)
+		if len(img.Unsynced) == 0 || !ok {
+			continue
+		}
+
+		unsynced := map[string]bool{}
+		for _, name := range img.Unsynced {
+			unsynced[name] = true
+		}
+		for _, tag := range before.Tags {
+			if unsynced[tag.Name] {
+				img.insertTag(tag)
+				delete(unsynced, tag.Name)
+			}
+		}
+
+		remaining := []string{}
+		for _, name := range img.Unsynced {
+			if unsynced[name] {
+				remaining = append(remaining, name)
+			}
+		}
+		if len(remaining) == 0 {
+			remaining = nil
+		}
+		img.Unsynced = remaining
+
+		img.SetLatest()
+		img.SetReleases(append(append([]string{}, img.Unsynced...), img.Filtered...))
+		img.SetSupport(img.supportWindow)
+	}
+}
+
+// insertTag inserts the tag before the first tag of a newer version, keeping the oldest first
+// order of the registry. Tags without a version are older than the versioned ones, like in
+// NewestTags, and ordered by name.
+func (img *Image) insertTag(tag Tag) {
+	i := sort.Search(len(img.Tags), func(i int) bool {
+		return tagLess(tag.Name, img.Tags[i].Name)
+	})
+
+	img.Tags = append(img.Tags, Tag{})
+	copy(img.Tags[i+1:], img.Tags[i:])
+	img.Tags[i] = tag
+}
+
+// tagLess reports whether the tag a orders before b: by version, then by name.
+func tagLess(a string, b string) bool {
+	va, okA := ParseVersion(a)
+	vb, okB := ParseVersion(b)
+	switch {
+	case okA && okB && va.Compare(vb) != 0:
+		return va.Compare(vb) < 0
+	case okA != okB:
+		return okB
+	default:
+		return a < b
+	}
+}
+
+// Merge keeps the images of the previous list that were not synced in this list, so a sync of
+// a subset of the sensor types does not drop the others or their timestamps. The order follows
+// sensorTypes. The previous discovery result is kept when this sync did not run discovery.
+func (l *ImageList) Merge(previous ImageList, sensorTypes []string) {
+	byType := map[string]Image{}
+	for _, img := range previous.Images {
+		byType[img.SensorType] = img
+	}
+	for _, img := range l.Images {
+		byType[img.SensorType] = img
+	}
+
+	merged := make([]Image, 0, len(byType))
+	for _, sensorType := range sensorTypes {
+		if img, ok := byType[sensorType]; ok {
+			merged = append(merged, img)
+		}
+	}
+
+	l.Images = merged
+	if l.Discovered == nil {
+		l.Discovered = previous.Discovered
+	}
+}
+
+// Fresh reports whether every image of the sensor types was synced less than maxAge ago.
+func (l ImageList) Fresh(sensorTypes []string, maxAge time.Duration, now time.Time) bool {
+	for _, sensorType := range sensorTypes {
+		img, ok := l.Find(sensorType)
+		if !ok || img.Updated.IsZero() || now.Sub(img.Updated) >= maxAge {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,296 @@
+package images
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"syncimages/discovery"
+)
+
+// syncList returns the list a sync of the tags stores, computed as the sync does. all are the
+// names of every tag of the repository, archs the architecture filter of the sync.
+func syncList(previous ImageList, tags []Tag, all []string, archs []string, now time.Time) ImageList {
+	img := Image{SensorType: "falcon-sensor", Repository: "registry.example.com/falcon-sensor", Tags: append([]Tag{}, tags...)}
+	img.SetUnsynced(all)
+	if len(archs) > 0 {
+		img.FilterArchs(archs)
+	}
+	img.SetLatest()
+	img.SetReleases(all)
+	img.SetSupport(2)
+
+	l := ImageList{Updated: now, Images: []Image{img}}
+	l.CarryOver(previous, now)
+	l.KeepStoredTags(previous)
+	l.Changes = l.Diff(previous)
+
+	return l
+}
+
+func TestPartialSyncs(t *testing.T) {
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	all := []string{"7.16.0-1", "7.17.0-1", "7.18.0-1", "7.19.0-1"}
+	tags := []Tag{
+		{Name: "7.16.0-1", Digest: "sha256:16", Arch: []string{"x86_64"}},
+		{Name: "7.17.0-1", Digest: "sha256:17", Arch: []string{"aarch64"}},
+		{Name: "7.18.0-1", Digest: "sha256:18", Arch: []string{"x86_64"}},
+		{Name: "7.19.0-1", Digest: "sha256:19", Arch: []string{"aarch64"}},
+	}
+	x86 := []string{"x86_64"}
+
+	type sync struct {
+		tags  []Tag
+		archs []string
+	}
+
+	tests := []struct {
+		name  string
+		syncs []sync
+		// stored are the names of the tags stored by the last sync, in order
+		stored []string
+		latest string
+	}{
+		{name: "limited then full", syncs: []sync{{tags: tags[2:]}, {tags: tags}}, stored: all, latest: "7.19.0-1"},
+		{name: "full then limited", syncs: []sync{{tags: tags}, {tags: tags[3:]}}, stored: all, latest: "7.19.0-1"},
+		{name: "full then limited then full", syncs: []sync{{tags: tags}, {tags: tags[3:]}, {tags: tags}}, stored: all, latest: "7.19.0-1"},
+		{name: "architecture filter then full", syncs: []sync{{tags: tags, archs: x86}, {tags: tags}}, stored: all, latest: "7.19.0-1"},
+		{name: "full then architecture filter", syncs: []sync{{tags: tags}, {tags: tags, archs: x86}}, stored: []string{"7.16.0-1", "7.18.0-1"}, latest: "7.18.0-1"},
+		{name: "limited then architecture filter", syncs: []sync{{tags: tags}, {tags: tags[2:], archs: x86}}, stored: []string{"7.16.0-1", "7.17.0-1", "7.18.0-1"}, latest: "7.18.0-1"},
+		{name: "limited then limited", syncs: []sync{{tags: tags[3:]}, {tags: tags[2:]}}, stored: []string{"7.18.0-1", "7.19.0-1"}, latest: "7.19.0-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stored ImageList
+			for i, synced := range tt.syncs {
+				now := t0.Add(time.Duration(i) * time.Hour)
+				stored = syncList(stored, synced.tags, all, synced.archs, now)
+				if i > 0 && len(stored.Changes) != 0 {
+					t.Fatalf("sync %d changes = %+v, want none", i, stored.Changes)
+				}
+			}
+
+			img := stored.Images[0]
+			names := []string{}
+			for _, tag := range img.Tags {
+				names = append(names, tag.Name)
+				if tag.Release == "" || tag.Support == "" {
+					t.Errorf("tag %s is not labeled: %+v", tag.Name, tag)
+				}
+			}
+			if !slices.Equal(names, tt.stored) {
+				t.Errorf("stored tags = %v, want %v", names, tt.stored)
+			}
+			if n := len(img.Tags) + len(img.Unsynced) + len(img.Filtered); n != len(all) {
+				t.Errorf("stored %d tags, %d unsynced and %d filtered tags, want %d tags in total", len(img.Tags), len(img.Unsynced), len(img.Filtered), len(all))
+			}
+			if img.LatestTag != tt.latest {
+				t.Errorf("LatestTag = %q, want %q", img.LatestTag, tt.latest)
+			}
+		})
+	}
+}
+
+func TestInsertTag(t *testing.T) {
+	tests := []struct {
+		name string
+		tags []string
+		tag  string
+		want []string
+	}{
+		{name: "oldest", tags: []string{"7.18.0-1", "7.19.0-1"}, tag: "7.17.0-1", want: []string{"7.17.0-1", "7.18.0-1", "7.19.0-1"}},
+		{name: "between", tags: []string{"7.17.0-1", "7.19.0-1"}, tag: "7.18.0-2", want: []string{"7.17.0-1", "7.18.0-2", "7.19.0-1"}},
+		{name: "newest", tags: []string{"7.17.0-1"}, tag: "7.18.0-1", want: []string{"7.17.0-1", "7.18.0-1"}},
+		{name: "unversioned", tags: []string{"7.17.0-1"}, tag: "latest", want: []string{"latest", "7.17.0-1"}},
+		{name: "empty", tag: "7.17.0-1", want: []string{"7.17.0-1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := Image{}
+			for _, name := range tt.tags {
+				img.Tags = append(img.Tags, Tag{Name: name})
+			}
+			img.insertTag(Tag{Name: tt.tag})
+
+			got := []string{}
+			for _, tag := range img.Tags {
+				got = append(got, tag.Name)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("insertTag(%s) = %v, want %v", tt.tag, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPartialSyncKeepsHistory(t *testing.T) {
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	all := []string{"7.17.0-1", "7.18.0-1"}
+	tags := []Tag{
+		{Name: "7.17.0-1", Digest: "sha256:17", Arch: []string{"x86_64"}},
+		{Name: "7.18.0-1", Digest: "sha256:18", Arch: []string{"x86_64"}},
+	}
+
+	stored := syncList(ImageList{}, tags, all, nil, t0)
+	stored = syncList(stored, tags[1:], all, nil, t0.Add(time.Hour))
+	stored = syncList(stored, tags, all, nil, t0.Add(2*time.Hour))
+
+	if len(stored.Changes) != 0 {
+		t.Fatalf("changes = %+v, want none", stored.Changes)
+	}
+	for _, tag := range stored.Images[0].Tags {
+		if !tag.FirstSeen.Equal(t0) {
+			t.Errorf("tag %s first seen %v, want %v", tag.Name, tag.FirstSeen, t0)
+		}
+		if len(tag.Digests) != 1 || tag.Mutated {
+			t.Errorf("tag %s digest history = %+v, want a single digest", tag.Name, tag.Digests)
+		}
+	}
+	if unsynced := stored.Images[0].Unsynced; len(unsynced) != 0 {
+		t.Errorf("Unsynced = %v, want none", unsynced)
+	}
+}
+
+func TestCarryOver(t *testing.T) {
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	t1 := t0.Add(time.Hour)
+	previous := ImageList{Images: []Image{{SensorType: "falcon-sensor", Tags: []Tag{
+		{Name: "same", Digest: "sha256:a", FirstSeen: t0, LastSeen: t0},
+		{Name: "moved", Digest: "sha256:a", FirstSeen: t0, LastSeen: t0},
+		{Name: "legacy", Digest: "sha256:a"},
+	}}}}
+
+	tests := []struct {
+		name      string
+		tag       Tag
+		firstSeen time.Time
+		digests   int
+		previous  []string
+	}{
+		{name: "unchanged", tag: Tag{Name: "same", Digest: "sha256:a"}, firstSeen: t0, digests: 1},
+		{name: "mutated", tag: Tag{Name: "moved", Digest: "sha256:b"}, firstSeen: t0, digests: 2, previous: []string{"sha256:a"}},
+		{name: "stored without history", tag: Tag{Name: "legacy", Digest: "sha256:a"}, firstSeen: t1, digests: 1},
+		{name: "new", tag: Tag{Name: "new", Digest: "sha256:c"}, firstSeen: t1, digests: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := ImageList{Images: []Image{{SensorType: "falcon-sensor", Tags: []Tag{tt.tag}}}}
+			l.CarryOver(previous, t1)
+
+			got := l.Images[0].Tags[0]
+			if !got.FirstSeen.Equal(tt.firstSeen) {
+				t.Errorf("FirstSeen = %v, want %v", got.FirstSeen, tt.firstSeen)
+			}
+			if !got.LastSeen.Equal(t1) {
+				t.Errorf("LastSeen = %v, want %v", got.LastSeen, t1)
+			}
+			if len(got.Digests) != tt.digests {
+				t.Errorf("Digests = %+v, want %d", got.Digests, tt.digests)
+			}
+			if got.Mutated != (len(tt.previous) > 0) || !slices.Equal(got.PreviousDigests, tt.previous) {
+				t.Errorf("Mutated = %v, PreviousDigests = %v, want %v", got.Mutated, got.PreviousDigests, tt.previous)
+			}
+		})
+	}
+}
+
+func TestDigestHistory(t *testing.T) {
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// The tag moves from a to b and back to a over four syncs
+	var l ImageList
+	for i, digest := range []string{"sha256:a", "sha256:b", "sha256:a", "sha256:a"} {
+		previous := l
+		l = ImageList{Images: []Image{{SensorType: "falcon-sensor", Tags: []Tag{{Name: "7.10.0", Digest: digest}}}}}
+		l.CarryOver(previous, t0.Add(time.Duration(i)*time.Hour))
+	}
+
+	tag := l.Images[0].Tags[0]
+	want := []DigestSeen{
+		{Digest: "sha256:a", FirstSeen: t0, LastSeen: t0},
+		{Digest: "sha256:b", FirstSeen: t0.Add(time.Hour), LastSeen: t0.Add(time.Hour)},
+		{Digest: "sha256:a", FirstSeen: t0.Add(2 * time.Hour), LastSeen: t0.Add(3 * time.Hour)},
+	}
+	if !slices.Equal(tag.Digests, want) {
+		t.Errorf("Digests = %+v, want %+v", tag.Digests, want)
+	}
+	if !tag.Mutated || !slices.Equal(tag.PreviousDigests, []string{"sha256:b"}) {
+		t.Errorf("Mutated = %v, PreviousDigests = %v, want sha256:b", tag.Mutated, tag.PreviousDigests)
+	}
+	if !tag.FirstSeen.Equal(t0) || !tag.LastSeen.Equal(t0.Add(3*time.Hour)) {
+		t.Errorf("FirstSeen, LastSeen = %v, %v, want %v, %v", tag.FirstSeen, tag.LastSeen, t0, t0.Add(3*time.Hour))
+	}
+}
+
+func TestMerge(t *testing.T) {
+	previousDiscovered := &discovery.Result{Errors: []string{"previous"}}
+	previous := ImageList{
+		Images:     []Image{{SensorType: "a", Name: "old a"}, {SensorType: "b", Name: "old b"}, {SensorType: "removed", Name: "old removed"}},
+		Discovered: previousDiscovered,
+	}
+
+	tests := []struct {
+		name       string
+		images     []Image
+		discovered *discovery.Result
+		want       []string
+	}{
+		{name: "subset", images: []Image{{SensorType: "b", Name: "new b"}}, want: []string{"old a", "new b"}},
+		{name: "catalog order", images: []Image{{SensorType: "c", Name: "new c"}, {SensorType: "a", Name: "new a"}}, want: []string{"new a", "old b", "new c"}},
+		{name: "discovery run", images: []Image{{SensorType: "a", Name: "new a"}}, discovered: &discovery.Result{}, want: []string{"new a", "old b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := ImageList{Images: tt.images, Discovered: tt.discovered}
+			l.Merge(previous, []string{"a", "b", "c"})
+
+			var names []string
+			for _, img := range l.Images {
+				names = append(names, img.Name)
+			}
+			if !slices.Equal(names, tt.want) {
+				t.Errorf("Merge() = %v, want %v", names, tt.want)
+			}
+
+			want := previousDiscovered
+			if tt.discovered != nil {
+				want = tt.discovered
+			}
+			if l.Discovered != want {
+				t.Errorf("Discovered = %+v, want %+v", l.Discovered, want)
+			}
+		})
+	}
+}
+
+func TestFresh(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := ImageList{Images: []Image{
+		{SensorType: "recent", Updated: now.Add(-time.Minute)},
+		{SensorType: "old", Updated: now.Add(-time.Hour)},
+		{SensorType: "never"},
+	}}
+
+	tests := []struct {
+		name        string
+		sensorTypes []string
+		want        bool
+	}{
+		{name: "recent", sensorTypes: []string{"recent"}, want: true},
+		{name: "old", sensorTypes: []string{"recent", "old"}},
+		{name: "never synced", sensorTypes: []string{"never"}},
+		{name: "not stored", sensorTypes: []string{"recent", "missing"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := l.Fresh(tt.sensorTypes, time.Hour, now); got != tt.want {
+				t.Errorf("Fresh() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package images
+
+import (
+	"time"
+
+	"syncimages/discovery"
+)
+
+// ImageList is the document stored in the images collection.
+type ImageList struct {
+	Updated    time.Time         `json:"updated"`
+	DurationMs int64             `json:"duration"`
+	Images     []Image           `json:"images"`
+	Discovered *discovery.Result `json:"discovered,omitempty"`
+	// Changes are the tag changes found by the sync that wrote the list.
+	Changes []Change `json:"changes,omitempty"`
+	// MemberCID is the MSSP member CID the images were synced for, empty for the CID of the app.
+	MemberCID string `json:"memberCid,omitempty"`
+	// Metadata identifies the tenant, function and request that produced the list.
+	Metadata *Metadata `json:"metadata,omitempty"`
+}
+
+// ListKey returns the object key of the image list of the member CID in the images collection,
+// all for the CID of the app.
+func ListKey(memberCID string) string {
+	if memberCID == "" {
+		return "all"
+	}
+
+	return "member-" + memberCID
+}
+
+// Image is a synced repository and its tags.
+type Image struct {
+	SensorType     string               `json:"sensorType"`
+	Source         string               `json:"source"`
+	Name           string               `json:"name"`
+	Description    string               `json:"description"`
+	DocsURL        string               `json:"docsUrl,omitempty"`
+	Registry       string               `json:"registry"`
+	Repository     string               `json:"repository"`
+	LatestTag      string               `json:"latest"`
+	LatestDigest   string               `json:"digest"`
+	LatestByArch   map[string]LatestRef `json:"latestByArch,omitempty"`
+	LatestByStream map[string]LatestRef `json:"latestByStream,omitempty"`
+	// CredentialRef references the registry credential of the image in the credentials
+	// collection, empty for anonymous pulls.
+	CredentialRef string `json:"credentialRef,omitempty"`
+	// Login, Password and DockerJson are the registry credentials used by the sync. They are
+	// never part of the stored image list.
+	Login      string `json:"-"`
+	Password   string `json:"-"`
+	DockerJson string `json:"-"`
+	Tags       []Tag  `json:"tags"`
+	// Updated is when the image was last synced, which can be older than the list when only
+	// some of the images were refreshed.
+	Updated    time.Time `json:"updated"`
+	DurationMs int64     `json:"durationMs"`
+	// State is StateNotEntitled when the tenant lacks the API scope or subscription of the image,
+	// empty when the image was synced.
+	State string `json:"state,omitempty"`
+	// StateReason is why the image is not entitled.
+	StateReason string `json:"stateReason,omitempty"`
+	// Unsynced are the names of the registry tags the sync did not fetch, beyond a tag limit,
+	// and that have no stored details. They are not reported as added when a later sync fetches
+	// them.
+	Unsynced []string `json:"unsynced,omitempty"`
+	// Filtered are the names of the fetched tags the architecture filter of the sync excluded.
+	// They are not stored, and neither reported as removed nor as added by a later sync.
+	Filtered []string `json:"filtered,omitempty"`
+
+	// supportWindow is the number of supported minor versions set by SetSupport, used to label
+	// the stored tags kept by KeepStoredTags.
+	supportWindow int
+}
+
+// StateNotEntitled marks an image the tenant is not entitled to. It has no tags and is rechecked
+// by the syncs after the entitlement recheck interval.
+const StateNotEntitled = "notEntitled"
+
+// Tag is a single tag of an image.
+type Tag struct {
+	Name   string   `json:"name"`
+	Digest string   `json:"digest"`
+	Arch   []string `json:"arch"`
+	// Release is the release position of the tag's minor version, e.g. N, N-1.
+	Release string `json:"release,omitempty"`
+	// Newest marks the newest build of the minor version for at least one architecture.
+	Newest bool `json:"newest,omitempty"`
+	// Support is the support status of the tag's minor version, empty when unknown.
+	Support string `json:"support,omitempty"`
+	// FirstSeen is when the sync first found the tag.
+	FirstSeen time.Time `json:"firstSeen"`
+	// LastSeen is when the sync last found the tag.
+	LastSeen time.Time `json:"lastSeen"`
+	// Digests is every digest the tag pointed to, oldest first.
+	Digests []DigestSeen `json:"digests,omitempty"`
+	// Mutated marks a tag that pointed to another digest before.
+	Mutated bool `json:"mutated,omitempty"`
+	// PreviousDigests are the other digests the tag pointed to, newest first.
+	PreviousDigests []string `json:"previousDigests,omitempty"`
+}
+
+// DigestSeen is a digest a tag pointed to and when the sync found it.
+type DigestSeen struct {
+	Digest    string    `json:"digest"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
+
+// LatestRef references the latest tag of a platform or release stream.
+type LatestRef struct {
+	Tag    string `json:"tag"`
+	Digest string `json:"digest"`
+}
+
+// SetUnsynced sets the names of the registry tags that are not tags of the image. all are the
+// names of every tag of the repository.
+func (img *Image) SetUnsynced(all []string) {
+	synced := map[string]bool{}
+	for _, tag := range img.Tags {
+		synced[tag.Name] = true
+	}
+
+	img.Unsynced = nil
+	for _, name := range all {
+		if !synced[name] {
+			img.Unsynced = append(img.Unsynced, name)
+		}
+	}
+}
+
+// FilterArchs keeps only the tags available for at least one of the architectures, and records
+// the names of the others in Filtered.
+func (img *Image) FilterArchs(archs []string) {
+	tags := make([]Tag, 0, len(img.Tags))
+	img.Filtered = nil
+	for _, tag := range img.Tags {
+		if tag.hasAnyArch(archs) {
+			tags = append(tags, tag)
+		} else {
+			img.Filtered = append(img.Filtered, tag.Name)
+		}
+	}
+
+	img.Tags = tags
+}
+
+// hasAnyArch reports whether the tag is available for at least one of the architectures.
+func (t Tag) hasAnyArch(archs []string) bool {
+	for _, arch := range archs {
+		if t.HasArch(arch) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,12 @@
+package images
+
+import "testing"
+
+func TestListKey(t *testing.T) {
+	if got := ListKey(""); got != "all" {
+		t.Errorf(`ListKey("") = %q, want "all"`, got)
+	}
+	if got := ListKey("0123456789abcdef0123456789abcdef-ab"); got != "member-0123456789abcdef0123456789abcdef-ab" {
+		t.Errorf("ListKey(member) = %q, want the member key", got)
+	}
+}
@@ -0,0 +1,58 @@
+package images
+
+// SetLatest computes the latest tag of the image overall, per architecture and per release stream
+// using the structured version ordering. Tags without a version are only considered when no tag has
+// one, in which case the last tag in registry order is the latest.
+func (img *Image) SetLatest() {
+	img.LatestTag = ""
+	img.LatestDigest = ""
+	img.LatestByArch = map[string]LatestRef{}
+	img.LatestByStream = map[string]LatestRef{}
+
+	if len(img.Tags) == 0 {
+		return
+	}
+
+	latest := -1
+	var latestVersion Version
+	archVersions := map[string]Version{}
+	streamVersions := map[string]Version{}
+
+	for i, tag := range img.Tags {
+		v, ok := ParseVersion(tag.Name)
+		if !ok {
+			continue
+		}
+		ref := LatestRef{Tag: tag.Name, Digest: tag.Digest}
+
+		// Ties keep the later tag, matching the registry order
+		if latest < 0 || v.Compare(latestVersion) >= 0 {
+			latest, latestVersion = i, v
+		}
+
+		for _, arch := range tag.Arch {
+			if current, ok := archVersions[arch]; !ok || v.Compare(current) >= 0 {
+				archVersions[arch] = v
+				img.LatestByArch[arch] = ref
+			}
+		}
+
+		stream := v.Stream()
+		if current, ok := streamVersions[stream]; !ok || v.Compare(current) >= 0 {
+			streamVersions[stream] = v
+			img.LatestByStream[stream] = ref
+		}
+	}
+
+	if latest < 0 {
+		latest = len(img.Tags) - 1
+		for _, tag := range img.Tags {
+			for _, arch := range tag.Arch {
+				img.LatestByArch[arch] = LatestRef{Tag: tag.Name, Digest: tag.Digest}
+			}
+		}
+	}
+
+	img.LatestTag = img.Tags[latest].Name
+	img.LatestDigest = img.Tags[latest].Digest
+}
@@ -0,0 +1,63 @@
+package images
+
+import "testing"
+
+func TestSetLatest(t *testing.T) {
+	tests := []struct {
+		name     string
+		tags     []Tag
+		latest   string
+		byArch   map[string]string
+		byStream map[string]string
+	}{
+		{name: "no tags", byArch: map[string]string{}, byStream: map[string]string{}},
+		{
+			name: "structured ordering",
+			tags: []Tag{
+				{Name: "7.9.0-1", Digest: "sha256:a", Arch: []string{"x86_64"}},
+				{Name: "7.10.0-1", Digest: "sha256:b", Arch: []string{"x86_64"}},
+				{Name: "7.10.0-2", Digest: "sha256:c", Arch: []string{"aarch64"}},
+				{Name: "latest", Digest: "sha256:d", Arch: []string{"x86_64"}},
+			},
+			latest:   "7.10.0-2",
+			byArch:   map[string]string{"x86_64": "7.10.0-1", "aarch64": "7.10.0-2"},
+			byStream: map[string]string{"7.9": "7.9.0-1", "7.10": "7.10.0-2"},
+		},
+		{
+			name: "unversioned tags",
+			tags: []Tag{
+				{Name: "stable", Digest: "sha256:a", Arch: []string{"x86_64"}},
+				{Name: "edge", Digest: "sha256:b", Arch: []string{"x86_64"}},
+			},
+			latest:   "edge",
+			byArch:   map[string]string{"x86_64": "edge"},
+			byStream: map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := Image{Tags: tt.tags}
+			img.SetLatest()
+			if img.LatestTag != tt.latest {
+				t.Errorf("LatestTag = %q, want %q", img.LatestTag, tt.latest)
+			}
+			if len(img.LatestByArch) != len(tt.byArch) {
+				t.Errorf("LatestByArch = %v, want %v", img.LatestByArch, tt.byArch)
+			}
+			for arch, tag := range tt.byArch {
+				if img.LatestByArch[arch].Tag != tag {
+					t.Errorf("LatestByArch[%s] = %q, want %q", arch, img.LatestByArch[arch].Tag, tag)
+				}
+			}
+			if len(img.LatestByStream) != len(tt.byStream) {
+				t.Errorf("LatestByStream = %v, want %v", img.LatestByStream, tt.byStream)
+			}
+			for stream, tag := range tt.byStream {
+				if img.LatestByStream[stream].Tag != tag {
+					t.Errorf("LatestByStream[%s] = %q, want %q", stream, img.LatestByStream[stream].Tag, tag)
+				}
+			}
+		})
+	}
+}
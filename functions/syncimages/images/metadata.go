@@ -0,0 +1,34 @@
+package images
+
+// SchemaVersion is the version of the stored image list format. It is increased when a change
+// breaks consumers of the images collection.
+const SchemaVersion = 1
+
+// Metadata identifies the tenant, function and request that produced an image list, for support
+// tickets and exports of several tenants.
+type Metadata struct {
+	SchemaVersion int `json:"schemaVersion"`
+	// CID is the CID with its checksum, masked unless the function config unmasks it.
+	CID string `json:"cid"`
+	// Cloud is the Falcon cloud the images were synced from, resolved when autodiscovered.
+	Cloud string `json:"cloud"`
+	// RegistryHost is the CrowdStrike registry host of the cloud.
+	RegistryHost string `json:"registryHost"`
+	// FunctionVersion is the version of the function, Dependencies the versions of the modules
+	// it talks to CrowdStrike and the registries with.
+	FunctionVersion string            `json:"functionVersion"`
+	Dependencies    map[string]string `json:"dependencies,omitempty"`
+	// Request identifies the request of the sync.
+	Request Request `json:"request"`
+}
+
+// Request identifies the function request of a sync.
+type Request struct {
+	// FnID and FnVersion identify the deployed function.
+	FnID      string `json:"fnId,omitempty"`
+	FnVersion int    `json:"fnVersion,omitempty"`
+	TraceID   string `json:"traceId,omitempty"`
+	Trigger   string `json:"trigger"`
+	RunID     string `json:"runId"`
+	JobID     string `json:"jobId,omitempty"`
+}
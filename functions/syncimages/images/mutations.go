@@ -0,0 +1,76 @@
+package images
+
+import (
+	"sort"
+	"time"
+
+	"syncimages/pagination"
+)
+
+// Mutation is a tag that pointed to another digest before.
+type Mutation struct {
+	SensorType      string   `json:"sensorType"`
+	Repository      string   `json:"repository"`
+	Tag             string   `json:"tag"`
+	Digest          string   `json:"digest"`
+	PreviousDigests []string `json:"previousDigests"`
+	Arch            []string `json:"arch"`
+	// DetectedAt is when the sync first found the current digest.
+	DetectedAt time.Time    `json:"detectedAt"`
+	Digests    []DigestSeen `json:"digests"`
+}
+
+// MutationPage is a page of mutated tags, most recently detected first.
+type MutationPage struct {
+	Meta      PageMeta   `json:"meta"`
+	Resources []Mutation `json:"resources"`
+}
+
+// Mutations returns the page of mutated tags matching the query. The since and until parameters
+// filter on when the mutation was detected instead of when the tag was first seen.
+func (q Query) Mutations(l ImageList) MutationPage {
+	detected := q
+	detected.Since, detected.Until = time.Time{}, time.Time{}
+
+	mutations := []Mutation{}
+	for _, img := range l.Images {
+		if len(q.SensorTypes) > 0 && !contains(q.SensorTypes, img.SensorType) {
+			continue
+		}
+
+		for _, tag := range img.Tags {
+			if !tag.Mutated || !detected.matchTag(tag) {
+				continue
+			}
+
+			m := Mutation{
+				SensorType:      img.SensorType,
+				Repository:      img.Repository,
+				Tag:             tag.Name,
+				Digest:          tag.Digest,
+				PreviousDigests: tag.PreviousDigests,
+				Arch:            tag.Arch,
+				Digests:         tag.Digests,
+			}
+			if n := len(tag.Digests); n > 0 {
+				m.DetectedAt = tag.Digests[n-1].FirstSeen
+			}
+			if !q.Since.IsZero() && m.DetectedAt.Before(q.Since) {
+				continue
+			}
+			if !q.Until.IsZero() && m.DetectedAt.After(q.Until) {
+				continue
+			}
+			mutations = append(mutations, m)
+		}
+	}
+
+	sort.SliceStable(mutations, func(i, j int) bool {
+		return mutations[i].DetectedAt.After(mutations[j].DetectedAt)
+	})
+
+	return MutationPage{
+		Meta:      PageMeta{Meta: pagination.Meta{Total: len(mutations), Offset: q.Offset, Limit: q.Limit}, Updated: l.Updated},
+		Resources: pagination.Slice(mutations, q.Offset, q.Limit),
+	}
+}
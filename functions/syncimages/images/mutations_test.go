@@ -0,0 +1,65 @@
+package images
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQueryMutations(t *testing.T) {
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	mutated := func(name string, detected time.Time, arch ...string) Tag {
+		return Tag{
+			Name:            name,
+			Digest:          "sha256:b",
+			Arch:            arch,
+			FirstSeen:       t0,
+			Digests:         []DigestSeen{{Digest: "sha256:a", FirstSeen: t0, LastSeen: t0}, {Digest: "sha256:b", FirstSeen: detected, LastSeen: detected}},
+			Mutated:         true,
+			PreviousDigests: []string{"sha256:a"},
+		}
+	}
+	l := ImageList{Updated: t0, Images: []Image{
+		{SensorType: "falcon-sensor", Tags: []Tag{
+			mutated("7.10.0", t0.AddDate(0, 0, 1), "x86_64"),
+			{Name: "7.11.0", Digest: "sha256:c", FirstSeen: t0},
+			mutated("7.12.0", t0.AddDate(0, 0, 3), "aarch64"),
+		}},
+		{SensorType: "falcon-kac", Tags: []Tag{mutated("7.10.0", t0.AddDate(0, 0, 2), "x86_64")}},
+	}}
+
+	tests := []struct {
+		name  string
+		query Query
+		total int
+		want  []string
+	}{
+		{name: "newest detection first", query: Query{Limit: 10}, total: 3, want: []string{"falcon-sensor/7.12.0", "falcon-kac/7.10.0", "falcon-sensor/7.10.0"}},
+		{name: "sensor type", query: Query{SensorTypes: []string{"falcon-kac"}, Limit: 10}, total: 1, want: []string{"falcon-kac/7.10.0"}},
+		{name: "arch", query: Query{Archs: []string{"x86_64"}, Limit: 10}, total: 2, want: []string{"falcon-kac/7.10.0", "falcon-sensor/7.10.0"}},
+		// since and until filter on the detection even though the tags were first seen at t0
+		{name: "detected since", query: Query{Since: t0.AddDate(0, 0, 2), Limit: 10}, total: 2, want: []string{"falcon-sensor/7.12.0", "falcon-kac/7.10.0"}},
+		{name: "detected until", query: Query{Until: t0.AddDate(0, 0, 1), Limit: 10}, total: 1, want: []string{"falcon-sensor/7.10.0"}},
+		{name: "page", query: Query{Offset: 1, Limit: 1}, total: 3, want: []string{"falcon-kac/7.10.0"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := tt.query.Mutations(l)
+			if page.Meta.Total != tt.total || !page.Meta.Updated.Equal(t0) {
+				t.Errorf("Meta = %+v, want total %d updated %v", page.Meta, tt.total, t0)
+			}
+
+			var got []string
+			for _, m := range page.Resources {
+				got = append(got, m.SensorType+"/"+m.Tag)
+				if n := len(m.Digests); n == 0 || !m.DetectedAt.Equal(m.Digests[n-1].FirstSeen) {
+					t.Errorf("%s DetectedAt = %v, want the first seen time of its digest", m.Tag, m.DetectedAt)
+				}
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Mutations() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
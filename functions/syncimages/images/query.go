@@ -0,0 +1,278 @@
+package images
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"syncimages/pagination"
+
+	"github.com/Masterminds/semver"
+)
+
+const (
+	// DefaultLimit is the page size used when no limit is requested.
+	DefaultLimit = 100
+	// MaxLimit is the largest page size accepted.
+	MaxLimit = 1000
+)
+
+// Query filters, paginates and selects the fields of stored images.
+type Query struct {
+	SensorTypes []string
+	Archs       []string
+	Constraint  *semver.Constraints
+	Support     string
+	Since       time.Time
+	Until       time.Time
+	Offset      int
+	Limit       int
+	Fields      []string
+}
+
+// Page is a paginated list of selected resources.
+type Page struct {
+	Meta      PageMeta                 `json:"meta"`
+	Resources []map[string]interface{} `json:"resources"`
+}
+
+// PageMeta describes the position of a page in the full result.
+type PageMeta struct {
+	pagination.Meta
+	// Updated is when the image list was synced.
+	Updated time.Time `json:"updated"`
+}
+
+// ParseQuery parses the query parameters sensorType, arch, version, support, since, until,
+// offset, limit and fields. Multi-valued parameters accept repeated or comma separated values.
+func ParseQuery(values url.Values) (Query, error) {
+	q := Query{
+		SensorTypes: listParam(values, "sensorType"),
+		Archs:       listParam(values, "arch"),
+		Support:     values.Get("support"),
+		Fields:      listParam(values, "fields"),
+	}
+
+	if v := values.Get("version"); v != "" {
+		c, err := semver.NewConstraint(v)
+		if err != nil {
+			return Query{}, fmt.Errorf("invalid version constraint %q: %v", v, err)
+		}
+		q.Constraint = c
+	}
+
+	switch q.Support {
+	case "", SupportSupported, SupportUnsupported, "unknown":
+	default:
+		return Query{}, fmt.Errorf("invalid support %q, expected %s, %s or unknown", q.Support, SupportSupported, SupportUnsupported)
+	}
+
+	var err error
+	if q.Since, err = timeParam(values, "since", false); err != nil {
+		return Query{}, err
+	}
+	if q.Until, err = timeParam(values, "until", true); err != nil {
+		return Query{}, err
+	}
+	if q.Offset, q.Limit, err = pagination.Parse(values, DefaultLimit, MaxLimit); err != nil {
+		return Query{}, err
+	}
+
+	return q, nil
+}
+
+// Images returns the page of images matching the query. Tag filters restrict the tags of each
+// image, and images without matching tags are left out.
+func (q Query) Images(l ImageList) (Page, error) {
+	matched := []Image{}
+	for _, img := range l.Images {
+		if len(q.SensorTypes) > 0 && !contains(q.SensorTypes, img.SensorType) {
+			continue
+		}
+
+		filtered, ok := q.filterTags(img)
+		if !ok {
+			continue
+		}
+		matched = append(matched, filtered)
+	}
+
+	page := Page{
+		Meta:      PageMeta{Meta: pagination.Meta{Total: len(matched), Offset: q.Offset, Limit: q.Limit}, Updated: l.Updated},
+		Resources: []map[string]interface{}{},
+	}
+	for _, img := range pagination.Slice(matched, q.Offset, q.Limit) {
+		selected, err := q.selectFields(img)
+		if err != nil {
+			return Page{}, err
+		}
+		page.Resources = append(page.Resources, selected)
+	}
+
+	return page, nil
+}
+
+// Image returns the selected fields of a single image of the list with its tags filtered and
+// paginated. The meta of the page describes the tags.
+func (q Query) Image(l ImageList, img Image) (Page, error) {
+	filtered, _ := q.filterTags(img)
+	total := len(filtered.Tags)
+	filtered.Tags = pagination.Slice(filtered.Tags, q.Offset, q.Limit)
+
+	selected, err := q.selectFields(filtered)
+	if err != nil {
+		return Page{}, err
+	}
+
+	return Page{
+		Meta:      PageMeta{Meta: pagination.Meta{Total: total, Offset: q.Offset, Limit: q.Limit}, Updated: l.Updated},
+		Resources: []map[string]interface{}{selected},
+	}, nil
+}
+
+// tagFiltered reports whether the query filters on tags.
+func (q Query) tagFiltered() bool {
+	return len(q.Archs) > 0 || q.Constraint != nil || q.Support != "" || !q.Since.IsZero() || !q.Until.IsZero()
+}
+
+// filterTags returns the image with only the matching tags, and whether the image matches.
+func (q Query) filterTags(img Image) (Image, bool) {
+	if !q.tagFiltered() {
+		return img, true
+	}
+
+	tags := []Tag{}
+	for _, tag := range img.Tags {
+		if q.matchTag(tag) {
+			tags = append(tags, tag)
+		}
+	}
+	img.Tags = tags
+
+	return img, len(tags) > 0
+}
+
+// matchTag reports whether the tag matches every tag filter of the query.
+func (q Query) matchTag(tag Tag) bool {
+	if len(q.Archs) > 0 {
+		found := false
+		for _, arch := range q.Archs {
+			if tag.HasArch(arch) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if q.Constraint != nil {
+		v, ok := ParseVersion(tag.Name)
+		if !ok {
+			return false
+		}
+		sv, err := semver.NewVersion(fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch))
+		if err != nil || !q.Constraint.Check(sv) {
+			return false
+		}
+	}
+
+	switch q.Support {
+	case "":
+	case "unknown":
+		if tag.Support != "" {
+			return false
+		}
+	default:
+		if tag.Support != q.Support {
+			return false
+		}
+	}
+
+	if !q.Since.IsZero() && tag.FirstSeen.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && tag.FirstSeen.After(q.Until) {
+		return false
+	}
+
+	return true
+}
+
+// selectFields returns the image as a map restricted to the selected fields, or with all its
+// fields when none are selected. The registry credentials are never part of the stored images,
+// see the credentials package.
+func (q Query) selectFields(img Image) (map[string]interface{}, error) {
+	b, err := json.Marshal(img)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding image: %v", err)
+	}
+
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, fmt.Errorf("error decoding image: %v", err)
+	}
+
+	if len(q.Fields) == 0 {
+		return fields, nil
+	}
+
+	selected := map[string]interface{}{}
+	for _, field := range q.Fields {
+		if value, ok := fields[field]; ok {
+			selected[field] = value
+		}
+	}
+
+	return selected, nil
+}
+
+// listParam returns the values of a repeated or comma separated query parameter.
+func listParam(values url.Values, key string) []string {
+	list := []string{}
+	for _, value := range values[key] {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+	}
+
+	return list
+}
+
+// timeParam parses an RFC 3339 timestamp or a YYYY-MM-DD date query parameter. A date is the
+// start of the day (UTC), or its end when endOfDay is set, so an until date includes the day.
+func timeParam(values url.Values, key string, endOfDay bool) (time.Time, error) {
+	value := values.Get(key)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s %q, expected RFC 3339 or YYYY-MM-DD", key, value)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+
+	return t, nil
+}
+
+// contains reports whether the list contains the value, ignoring case.
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,158 @@
+package images
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+	"time"
+
+	"syncimages/pagination"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		check   func(t *testing.T, q Query)
+		wantErr bool
+	}{
+		{
+			name:  "defaults",
+			query: "",
+			check: func(t *testing.T, q Query) {
+				if q.Limit != DefaultLimit || q.Offset != 0 {
+					t.Errorf("offset, limit = %d, %d, want 0, %d", q.Offset, q.Limit, DefaultLimit)
+				}
+			},
+		},
+		{
+			name:  "lists",
+			query: "sensorType=falcon-sensor,falcon-kac&arch=x86_64&arch=aarch64",
+			check: func(t *testing.T, q Query) {
+				if len(q.SensorTypes) != 2 || len(q.Archs) != 2 {
+					t.Errorf("sensor types %v, archs %v, want two of each", q.SensorTypes, q.Archs)
+				}
+			},
+		},
+		{
+			name:  "until date includes the day",
+			query: "since=2024-06-01&until=2024-06-01",
+			check: func(t *testing.T, q Query) {
+				if want := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC); !q.Since.Equal(want) {
+					t.Errorf("Since = %v, want %v", q.Since, want)
+				}
+				if want := time.Date(2024, 6, 1, 23, 59, 59, 999999999, time.UTC); !q.Until.Equal(want) {
+					t.Errorf("Until = %v, want %v", q.Until, want)
+				}
+			},
+		},
+		{
+			name:  "until timestamp",
+			query: "until=2024-06-01T12:00:00Z",
+			check: func(t *testing.T, q Query) {
+				if want := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC); !q.Until.Equal(want) {
+					t.Errorf("Until = %v, want %v", q.Until, want)
+				}
+			},
+		},
+		{name: "invalid version", query: "version=>>1", wantErr: true},
+		{name: "invalid support", query: "support=maybe", wantErr: true},
+		{name: "invalid date", query: "since=yesterday", wantErr: true},
+		{name: "limit too large", query: "limit=1001", wantErr: true},
+		{name: "negative offset", query: "offset=-1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			q, err := ParseQuery(values)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseQuery(%q) error = %v, want error %v", tt.query, err, tt.wantErr)
+			}
+			if tt.check != nil {
+				tt.check(t, q)
+			}
+		})
+	}
+}
+
+func TestQueryImages(t *testing.T) {
+	day := time.Date(2024, 6, 1, 15, 0, 0, 0, time.UTC)
+	list := ImageList{Images: []Image{
+		{SensorType: "falcon-sensor", Tags: []Tag{
+			{Name: "7.18.0-1", Arch: []string{"x86_64"}, Support: SupportSupported, FirstSeen: day},
+			{Name: "7.10.0-1", Arch: []string{"aarch64"}, Support: SupportUnsupported, FirstSeen: day.AddDate(0, 0, -30)},
+		}},
+		{SensorType: "falcon-kac", Tags: []Tag{{Name: "7.20.0-1", Arch: []string{"x86_64"}, FirstSeen: day}}},
+	}}
+
+	tests := []struct {
+		name  string
+		query string
+		total int
+		tags  int
+	}{
+		{name: "all", query: "", total: 2, tags: 2},
+		{name: "sensor type", query: "sensorType=falcon-kac", total: 1, tags: 1},
+		{name: "arch", query: "sensorType=falcon-sensor&arch=aarch64", total: 1, tags: 1},
+		{name: "version", query: "version=>=7.19", total: 1, tags: 1},
+		{name: "supported", query: "support=supported", total: 1, tags: 1},
+		{name: "unknown support", query: "support=unknown", total: 1, tags: 1},
+		{name: "until day", query: "sensorType=falcon-sensor&until=2024-06-01", total: 1, tags: 2},
+		{name: "since day", query: "sensorType=falcon-sensor&since=2024-06-01", total: 1, tags: 1},
+		{name: "no match", query: "version=>=9", total: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, _ := url.ParseQuery(tt.query)
+			q, err := ParseQuery(values)
+			if err != nil {
+				t.Fatal(err)
+			}
+			page, err := q.Images(list)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if page.Meta.Total != tt.total {
+				t.Fatalf("total = %d, want %d", page.Meta.Total, tt.total)
+			}
+			if tt.total > 0 {
+				if tags := page.Resources[0]["tags"].([]interface{}); len(tags) != tt.tags {
+					t.Errorf("tags = %v, want %d", tags, tt.tags)
+				}
+			}
+		})
+	}
+}
+
+func TestQueryFields(t *testing.T) {
+	list := ImageList{Images: []Image{{SensorType: "falcon-sensor", Name: "sensor", Password: "secret"}}}
+	q, err := ParseQuery(url.Values{"fields": {"sensorType,password"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	page, err := q.Images(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := page.Resources[0]
+	if len(got) != 1 || got["sensorType"] != "falcon-sensor" {
+		t.Errorf("selected fields = %v, want only sensorType", got)
+	}
+}
+
+func TestPageMetaJSON(t *testing.T) {
+	meta := PageMeta{Meta: pagination.Meta{Total: 3, Offset: 1, Limit: 2}, Updated: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)}
+	b, err := json.Marshal(meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"total":3,"offset":1,"limit":2,"updated":"2024-06-01T00:00:00Z"}`; string(b) != want {
+		t.Errorf("json.Marshal(PageMeta) = %s, want %s", b, want)
+	}
+}
@@ -0,0 +1,199 @@
+package images
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// SupportSupported marks tags whose minor version is within the supported release window.
+	SupportSupported = "supported"
+	// SupportUnsupported marks tags whose minor version is outside the supported release window.
+	SupportUnsupported = "unsupported"
+)
+
+// ReleasePosition formats the release position of a minor version, e.g. N, N-1, N-2.
+func ReleasePosition(n int) string {
+	if n == 0 {
+		return "N"
+	}
+	return fmt.Sprintf("N-%d", n)
+}
+
+// ParseReleasePosition parses a release position such as N, N-1 or n-2.
+func ParseReleasePosition(position string) (int, error) {
+	p := strings.ToUpper(strings.TrimSpace(position))
+	if p == "N" {
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(strings.TrimPrefix(p, "N-"))
+	if !strings.HasPrefix(p, "N-") || err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid release position %q, expected N or N-<number>", position)
+	}
+
+	return n, nil
+}
+
+// SetReleases labels each tag with the release position of its minor version (N for the newest
+// minor, N-1 for the one before, ...) and flags the newest build of each minor per architecture.
+// The positions are computed on the tag names of the whole repository, so a sync that keeps only
+// some of the tags (e.g. by a tag limit or an architecture filter) does not shift them. The tags of
+// the image are always part of it. Tags without a version are not labeled.
+func (img *Image) SetReleases(all []string) {
+	versions := make([]*Version, len(img.Tags))
+	streams := map[string]Version{}
+
+	for _, name := range all {
+		if v, ok := ParseVersion(name); ok {
+			streams[v.Stream()] = v
+		}
+	}
+	for i := range img.Tags {
+		img.Tags[i].Release = ""
+		img.Tags[i].Newest = false
+
+		v, ok := ParseVersion(img.Tags[i].Name)
+		if !ok {
+			continue
+		}
+		versions[i] = &v
+		streams[v.Stream()] = v
+	}
+
+	positions := releasePositions(streams)
+
+	// newest holds the index of the newest tag per minor version and architecture
+	newest := map[string]int{}
+	for i, v := range versions {
+		if v == nil {
+			continue
+		}
+		img.Tags[i].Release = positions[v.Stream()]
+
+		for _, arch := range img.Tags[i].Arch {
+			key := v.Stream() + "/" + arch
+			if current, ok := newest[key]; !ok || v.Compare(*versions[current]) >= 0 {
+				newest[key] = i
+			}
+		}
+	}
+
+	for _, i := range newest {
+		img.Tags[i].Newest = true
+	}
+}
+
+// releasePositions returns the release position of each minor version by stream, ordered from
+// the newest to the oldest.
+func releasePositions(streams map[string]Version) map[string]string {
+	ordered := make([]Version, 0, len(streams))
+	for _, v := range streams {
+		ordered = append(ordered, v)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		a, b := ordered[i], ordered[j]
+		if a.Major != b.Major {
+			return a.Major > b.Major
+		}
+		return a.Minor > b.Minor
+	})
+
+	positions := map[string]string{}
+	for n, v := range ordered {
+		positions[v.Stream()] = ReleasePosition(n)
+	}
+
+	return positions
+}
+
+// SetSupport sets the support status of the labeled tags from the number of supported minor
+// versions, e.g. 3 supports N, N-1 and N-2. A window of 0 leaves the support status unknown.
+// SetReleases must be called first.
+func (img *Image) SetSupport(window int) {
+	img.supportWindow = window
+	for i := range img.Tags {
+		img.Tags[i].Support = ""
+		if window <= 0 || img.Tags[i].Release == "" {
+			continue
+		}
+
+		n, err := ParseReleasePosition(img.Tags[i].Release)
+		if err != nil {
+			continue
+		}
+		if n < window {
+			img.Tags[i].Support = SupportSupported
+		} else {
+			img.Tags[i].Support = SupportUnsupported
+		}
+	}
+}
+
+// Release returns the newest tag of the release position for the architecture.
+// An empty architecture matches any architecture.
+func (img Image) Release(position string, arch string) (Tag, bool) {
+	n, err := ParseReleasePosition(position)
+	if err != nil {
+		return Tag{}, false
+	}
+	label := ReleasePosition(n)
+
+	found := -1
+	var foundVersion Version
+	for i, tag := range img.Tags {
+		if tag.Release != label || (arch != "" && !tag.HasArch(arch)) {
+			continue
+		}
+
+		v, _ := ParseVersion(tag.Name)
+		if found < 0 || v.Compare(foundVersion) >= 0 {
+			found, foundVersion = i, v
+		}
+	}
+
+	if found < 0 {
+		return Tag{}, false
+	}
+
+	return img.Tags[found], true
+}
+
+// HasArch reports whether the tag is available for the architecture.
+func (t Tag) HasArch(arch string) bool {
+	for _, a := range t.Arch {
+		if strings.EqualFold(a, arch) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Lookup returns the image with the sensor type or, failing that, the name (ignoring case).
+func (l ImageList) Lookup(name string) (Image, bool) {
+	if img, ok := l.Find(name); ok {
+		return img, true
+	}
+
+	for _, img := range l.Images {
+		if strings.EqualFold(img.Name, name) {
+			return img, true
+		}
+	}
+
+	return Image{}, false
+}
+
+// Find returns the image of the sensor type.
+func (l ImageList) Find(sensorType string) (Image, bool) {
+	for _, img := range l.Images {
+		if img.SensorType == sensorType {
+			return img, true
+		}
+	}
+
+	return Image{}, false
+}
@@ -0,0 +1,120 @@
+package images
+
+import "testing"
+
+func TestSetReleases(t *testing.T) {
+	tests := []struct {
+		name    string
+		tags    []Tag
+		all     []string
+		release map[string]string
+		newest  map[string]bool
+	}{
+		{
+			name: "positions by minor",
+			tags: []Tag{
+				{Name: "7.16.0-1", Arch: []string{"x86_64"}},
+				{Name: "7.18.0-1", Arch: []string{"x86_64"}},
+				{Name: "7.18.0-2", Arch: []string{"x86_64"}},
+				{Name: "7.17.1-1", Arch: []string{"x86_64"}},
+				{Name: "latest", Arch: []string{"x86_64"}},
+			},
+			release: map[string]string{"7.16.0-1": "N-2", "7.18.0-1": "N", "7.18.0-2": "N", "7.17.1-1": "N-1", "latest": ""},
+			newest:  map[string]bool{"7.16.0-1": true, "7.18.0-2": true, "7.17.1-1": true},
+		},
+		{
+			name: "newest per architecture",
+			tags: []Tag{
+				{Name: "7.18.0-1", Arch: []string{"x86_64", "aarch64"}},
+				{Name: "7.18.0-2", Arch: []string{"x86_64"}},
+			},
+			release: map[string]string{"7.18.0-1": "N", "7.18.0-2": "N"},
+			newest:  map[string]bool{"7.18.0-1": true, "7.18.0-2": true},
+		},
+		{
+			name: "positions on all tags before filtering",
+			tags: []Tag{
+				{Name: "7.17.0-1", Arch: []string{"x86_64"}},
+			},
+			all:     []string{"7.16.0-1", "7.17.0-1", "7.18.0-1", "7.19.0-1"},
+			release: map[string]string{"7.17.0-1": "N-2"},
+			newest:  map[string]bool{"7.17.0-1": true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := Image{Tags: tt.tags}
+			img.SetReleases(tt.all)
+			for _, tag := range img.Tags {
+				if tag.Release != tt.release[tag.Name] {
+					t.Errorf("tag %s release = %q, want %q", tag.Name, tag.Release, tt.release[tag.Name])
+				}
+				if tag.Newest != tt.newest[tag.Name] {
+					t.Errorf("tag %s newest = %v, want %v", tag.Name, tag.Newest, tt.newest[tag.Name])
+				}
+			}
+		})
+	}
+}
+
+func TestSetSupport(t *testing.T) {
+	tags := []Tag{{Name: "7.19.0-1"}, {Name: "7.18.0-1"}, {Name: "7.17.0-1"}, {Name: "7.16.0-1"}, {Name: "latest"}}
+	tests := []struct {
+		name    string
+		window  int
+		support map[string]string
+	}{
+		{name: "no window", window: 0, support: map[string]string{}},
+		{
+			name:    "three releases",
+			window:  3,
+			support: map[string]string{"7.19.0-1": SupportSupported, "7.18.0-1": SupportSupported, "7.17.0-1": SupportSupported, "7.16.0-1": SupportUnsupported},
+		},
+		{
+			name:    "latest only",
+			window:  1,
+			support: map[string]string{"7.19.0-1": SupportSupported, "7.18.0-1": SupportUnsupported, "7.17.0-1": SupportUnsupported, "7.16.0-1": SupportUnsupported},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := Image{Tags: append([]Tag{}, tags...)}
+			img.SetReleases(nil)
+			img.SetSupport(tt.window)
+			for _, tag := range img.Tags {
+				if tag.Support != tt.support[tag.Name] {
+					t.Errorf("tag %s support = %q, want %q", tag.Name, tag.Support, tt.support[tag.Name])
+				}
+			}
+		})
+	}
+}
+
+func TestParseReleasePosition(t *testing.T) {
+	tests := []struct {
+		position string
+		want     int
+		wantErr  bool
+	}{
+		{position: "N", want: 0},
+		{position: " n-2 ", want: 2},
+		{position: "N-10", want: 10},
+		{position: "N-", wantErr: true},
+		{position: "N+1", wantErr: true},
+		{position: "2", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.position, func(t *testing.T) {
+			got, err := ParseReleasePosition(tt.position)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseReleasePosition(%q) error = %v, want error %v", tt.position, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseReleasePosition(%q) = %d, want %d", tt.position, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package images
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// versionPattern matches the leading major.minor[.patch] of a tag, e.g. 7.18.0 in 7.18.0-17106-1.falcon-linux.Release.US-1.
+var versionPattern = regexp.MustCompile(`^v?(\d+)\.(\d+)(?:\.(\d+))?`)
+
+// Version is the structured version of a tag. CrowdStrike tags carry a build number after the
+// version (e.g. 7.18.0-17106-1), which is part of the ordering.
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+	Build []int
+}
+
+// ParseVersion parses the version of a tag. It returns false for tags without a leading version.
+func ParseVersion(tag string) (Version, bool) {
+	m := versionPattern.FindStringSubmatch(tag)
+	if m == nil {
+		return Version{}, false
+	}
+
+	var v Version
+	v.Major, _ = strconv.Atoi(m[1])
+	v.Minor, _ = strconv.Atoi(m[2])
+	if m[3] != "" {
+		v.Patch, _ = strconv.Atoi(m[3])
+	}
+
+	// Build numbers are the numeric components following the version, up to the first non-numeric one
+	rest := strings.TrimPrefix(tag[len(m[0]):], "-")
+	for _, part := range strings.FieldsFunc(rest, func(r rune) bool { return r == '-' || r == '.' }) {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			break
+		}
+		v.Build = append(v.Build, n)
+	}
+
+	return v, true
+}
+
+// Compare returns -1, 0 or 1 when v is lower than, equal to or higher than o.
+func (v Version) Compare(o Version) int {
+	for _, pair := range [][2]int{{v.Major, o.Major}, {v.Minor, o.Minor}, {v.Patch, o.Patch}} {
+		if c := compareInt(pair[0], pair[1]); c != 0 {
+			return c
+		}
+	}
+
+	for i := 0; i < len(v.Build) && i < len(o.Build); i++ {
+		if c := compareInt(v.Build[i], o.Build[i]); c != 0 {
+			return c
+		}
+	}
+
+	return compareInt(len(v.Build), len(o.Build))
+}
+
+// Stream returns the major.minor release stream of the version, e.g. 7.18. It is built from the
+// parsed numbers, so 7.04 and 7.4 are the same stream.
+func (v Version) Stream() string {
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
+
+// String returns the version with its build numbers, e.g. 7.18.0-17106-1.
+func (v Version) String() string {
+	s := fmt.Sprintf("%s.%d", v.Stream(), v.Patch)
+	for _, build := range v.Build {
+		s += fmt.Sprintf("-%d", build)
+	}
+
+	return s
+}
+
+func compareInt(a int, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// NewestTags returns the n tags with the highest version, keeping their original order. Tags
+// without a version rank lowest. An n of 0 or less returns all tags.
+func NewestTags(tags []string, n int) []string {
+	if n <= 0 || len(tags) <= n {
+		return tags
+	}
+
+	indexes := make([]int, len(tags))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(a, b int) bool {
+		va, okA := ParseVersion(tags[indexes[a]])
+		vb, okB := ParseVersion(tags[indexes[b]])
+		if okA != okB {
+			return okA
+		}
+		return va.Compare(vb) > 0
+	})
+
+	keep := map[int]bool{}
+	for _, i := range indexes[:n] {
+		keep[i] = true
+	}
+
+	newest := make([]string, 0, n)
+	for i, tag := range tags {
+		if keep[i] {
+			newest = append(newest, tag)
+		}
+	}
+
+	return newest
+}
@@ -0,0 +1,87 @@
+package images
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		tag    string
+		want   Version
+		stream string
+		ok     bool
+	}{
+		{tag: "7.18.0-17106-1.falcon-linux.Release.US-1", want: Version{Major: 7, Minor: 18, Build: []int{17106, 1}}, stream: "7.18", ok: true},
+		{tag: "v1.2.3", want: Version{Major: 1, Minor: 2, Patch: 3}, stream: "1.2", ok: true},
+		{tag: "7.04", want: Version{Major: 7, Minor: 4}, stream: "7.4", ok: true},
+		{tag: "7.4.1-202", want: Version{Major: 7, Minor: 4, Patch: 1, Build: []int{202}}, stream: "7.4", ok: true},
+		{tag: "latest", ok: false},
+		{tag: "", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			got, ok := ParseVersion(tt.tag)
+			if ok != tt.ok {
+				t.Fatalf("ParseVersion(%q) ok = %v, want %v", tt.tag, ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			if got.Major != tt.want.Major || got.Minor != tt.want.Minor || got.Patch != tt.want.Patch || !slices.Equal(got.Build, tt.want.Build) {
+				t.Errorf("ParseVersion(%q) = %+v, want %+v", tt.tag, got, tt.want)
+			}
+			if got.Stream() != tt.stream {
+				t.Errorf("ParseVersion(%q).Stream() = %q, want %q", tt.tag, got.Stream(), tt.stream)
+			}
+		})
+	}
+}
+
+func TestVersionCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "7.18.0", b: "7.18.0", want: 0},
+		{a: "7.18.0", b: "7.19.0", want: -1},
+		{a: "8.0.0", b: "7.99.99", want: 1},
+		{a: "7.18.1", b: "7.18.0", want: 1},
+		{a: "7.18.0-17106-2", b: "7.18.0-17106-1", want: 1},
+		{a: "7.18.0-17106", b: "7.18.0-17106-1", want: -1},
+		{a: "7.04.0", b: "7.4.0", want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+"_"+tt.b, func(t *testing.T) {
+			a, _ := ParseVersion(tt.a)
+			b, _ := ParseVersion(tt.b)
+			if got := a.Compare(b); got != tt.want {
+				t.Errorf("Compare(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewestTags(t *testing.T) {
+	tags := []string{"7.16.0-1", "latest", "7.18.0-1", "7.17.0-1", "7.18.0-2"}
+	tests := []struct {
+		name string
+		n    int
+		want []string
+	}{
+		{name: "all", n: 0, want: tags},
+		{name: "more than available", n: 10, want: tags},
+		{name: "newest two", n: 2, want: []string{"7.18.0-1", "7.18.0-2"}},
+		{name: "unversioned last", n: 4, want: []string{"7.16.0-1", "7.18.0-1", "7.17.0-1", "7.18.0-2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewestTags(tags, tt.n); !slices.Equal(got, tt.want) {
+				t.Errorf("NewestTags(%d) = %v, want %v", tt.n, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"syncimages/api"
+	"syncimages/images"
+
+	fdk "github.com/CrowdStrike/foundry-fn-go"
+)
+
+// imagesHandler returns a page of the stored images filtered by the query parameters. With the
+// release query parameter it returns the newest tag of a release position instead,
+// e.g. ?sensorType=falcon-sensor&arch=aarch64&release=N-1.
+func imagesHandler(logger *slog.Logger) fdk.Handler {
+	return jsonHandler(logger, func(ctx context.Context, r fdk.Request) (interface{}, error) {
+		q, err := images.ParseQuery(r.Queries)
+		if err != nil {
+			return nil, badRequest(err)
+		}
+		memberCID, err := memberCIDParam(r.Queries)
+		if err != nil {
+			return nil, badRequest(err)
+		}
+
+		_, imageData, err := storedImages(r, memberCID)
+		if err != nil {
+			return nil, err
+		}
+
+		if r.Queries.Get("release") == "" {
+			return q.Images(imageData)
+		}
+
+		return resolveRelease(imageData, r.Queries.Get("sensorType"), r.Queries.Get("arch"), r.Queries.Get("release"))
+	})
+}
+
+// imageHandler returns a single stored image, looked up by sensor type or name, with its tags
+// filtered and paginated by the query parameters.
+func imageHandler(logger *slog.Logger) fdk.Handler {
+	return jsonHandler(logger, func(ctx context.Context, r fdk.Request) (interface{}, error) {
+		q, err := images.ParseQuery(r.Queries)
+		if err != nil {
+			return nil, badRequest(err)
+		}
+		memberCID, err := memberCIDParam(r.Queries)
+		if err != nil {
+			return nil, badRequest(err)
+		}
+
+		_, imageData, err := storedImages(r, memberCID)
+		if err != nil {
+			return nil, err
+		}
+
+		name := pathParam(ctx, "name")
+		img, ok := imageData.Lookup(name)
+		if !ok {
+			return nil, notFound(fmt.Errorf("image not found: %q", name))
+		}
+
+		return q.Image(imageData, img)
+	})
+}
+
+// mutatedTagsHandler returns the stored tags whose digest changed, most recently detected first,
+// filtered by the sensorType, arch, version, since, until, offset and limit query parameters.
+func mutatedTagsHandler(logger *slog.Logger) fdk.Handler {
+	return jsonHandler(logger, func(ctx context.Context, r fdk.Request) (interface{}, error) {
+		q, err := images.ParseQuery(r.Queries)
+		if err != nil {
+			return nil, badRequest(err)
+		}
+		memberCID, err := memberCIDParam(r.Queries)
+		if err != nil {
+			return nil, badRequest(err)
+		}
+
+		_, imageData, err := storedImages(r, memberCID)
+		if err != nil {
+			return nil, err
+		}
+
+		return q.Mutations(imageData), nil
+	})
+}
+
+// recommendedImageHandler returns the image reference of a sensor type for an architecture and
+// release position, for workflows that deploy or pin a sensor.
+func recommendedImageHandler(logger *slog.Logger) fdk.Handler {
+	return jsonHandler(logger, func(ctx context.Context, r fdk.Request) (interface{}, error) {
+		req, err := api.DecodeRecommendedImageRequest(r.Body)
+		if err != nil {
+			return nil, badRequest(err)
+		}
+
+		_, imageData, err := storedImages(r, req.MemberCID)
+		if err != nil {
+			return nil, err
+		}
+
+		return resolveRelease(imageData, req.SensorType, req.Arch, req.Release)
+	})
+}
+
+// resolveRelease returns the newest tag of the release position of the sensor type for the architecture.
+func resolveRelease(imageData images.ImageList, sensorType string, arch string, release string) (api.ImageRef, error) {
+	if sensorType == "" {
+		return api.ImageRef{}, badRequest(fmt.Errorf("sensorType is required with release"))
+	}
+	n, err := images.ParseReleasePosition(release)
+	if err != nil {
+		return api.ImageRef{}, badRequest(err)
+	}
+
+	img, ok := imageData.Find(sensorType)
+	if !ok {
+		return api.ImageRef{}, notFound(fmt.Errorf("unknown sensorType: %q", sensorType))
+	}
+
+	position := images.ReleasePosition(n)
+	tag, ok := img.Release(position, arch)
+	if !ok {
+		return api.ImageRef{}, notFound(fmt.Errorf("no %s release of %s for arch %q", position, sensorType, arch))
+	}
+
+	return api.ImageRef{
+		SensorType: sensorType,
+		Release:    position,
+		Arch:       arch,
+		Tag:        tag.Name,
+		Digest:     tag.Digest,
+		Archs:      tag.Arch,
+		Image:      img.Repository + "@" + tag.Digest,
+	}, nil
+}
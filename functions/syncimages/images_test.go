@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"syncimages/api"
+	"syncimages/catalog"
+	"syncimages/config"
+	"syncimages/diagnose"
+	"syncimages/images"
+	"syncimages/runs"
+	"syncimages/version"
+
+	fdk "github.com/CrowdStrike/foundry-fn-go"
+)
+
+func TestNotEntitledImage(t *testing.T) {
+	target := catalog.Target{SensorType: "falcon-kac", Name: "KAC", Repository: "registry.example.com/falcon-kac/release/falcon-kac"}
+
+	img := notEntitledImage(target, diagnose.ReasonMissingScope, errors.New("[403] access denied"))
+	if img.State != images.StateNotEntitled || img.StateReason != "missingScope: [403] access denied" {
+		t.Errorf("State, StateReason = %q, %q", img.State, img.StateReason)
+	}
+	if img.SensorType != "falcon-kac" || img.Registry != "registry.example.com" || img.Repository != target.Repository {
+		t.Errorf("image = %+v, want the target", img)
+	}
+	if img.Tags == nil || len(img.Tags) != 0 || img.Updated.IsZero() {
+		t.Errorf("Tags, Updated = %v, %v, want no tags checked now", img.Tags, img.Updated)
+	}
+}
+
+func TestGetImagesNotEntitled(t *testing.T) {
+	cat, err := catalog.Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := api.SyncRequest{SensorTypes: []string{"falcon-kac"}}
+	notEntitled := func(checked time.Time) images.ImageList {
+		return images.ImageList{Images: []images.Image{{SensorType: "falcon-kac", State: images.StateNotEntitled, StateReason: "missingScope: [403] Forbidden", Updated: checked}}}
+	}
+
+	tests := []struct {
+		name     string
+		previous images.ImageList
+		force    bool
+		checked  bool
+	}{
+		{name: "skipped until the recheck", previous: notEntitled(time.Now().Add(-time.Hour))},
+		{name: "rechecked after the interval", previous: notEntitled(time.Now().Add(-25 * time.Hour)), checked: true},
+		{name: "forced recheck", previous: notEntitled(time.Now().Add(-time.Hour)), force: true, checked: true},
+		{name: "first sync", checked: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := withFakeAPI(t)
+			// Only the CID is readable, the registry credentials of the API are forbidden
+			fake.code = http.StatusForbidden
+			fake.responses = map[string]string{"/sensors/queries/installers/ccid/v1": `{"resources":["` + strings.ToUpper(testMemberCID) + `"]}`}
+			client, _, err := newFalconClient("token")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r := req
+			r.Force = tt.force
+			l, sensorRuns, err := getImages(context.Background(), client, "", "us-1", config.Config{}, cat, r, tt.previous, nil)
+			if err != nil {
+				t.Fatalf("getImages() error = %v", err)
+			}
+			if len(l.Images) != 1 || l.Images[0].State != images.StateNotEntitled {
+				t.Fatalf("getImages() = %+v, want falcon-kac not entitled", l.Images)
+			}
+			if len(sensorRuns) != 1 || sensorRuns[0].State != runs.StateNotEntitled || sensorRuns[0].Reason != diagnose.ReasonMissingScope {
+				t.Errorf("sensor runs = %+v, want falcon-kac not entitled for a missing scope", sensorRuns)
+			}
+
+			// A skipped sensor type keeps its stored image, a checked one records why it failed
+			if checked := sensorRuns[0].Error != ""; checked != tt.checked {
+				t.Errorf("checked = %v, want %v", checked, tt.checked)
+			}
+			if !tt.checked && !l.Images[0].Updated.Equal(tt.previous.Images[0].Updated) {
+				t.Errorf("Updated = %v, want the stored image", l.Images[0].Updated)
+			}
+		})
+	}
+}
+
+func TestGetImagesMetadata(t *testing.T) {
+	cat, err := catalog.Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The stored image is skipped, so only the CID is read from the API
+	previous := images.ImageList{Images: []images.Image{{SensorType: "falcon-kac", State: images.StateNotEntitled, Updated: time.Now()}}}
+	req := api.SyncRequest{SensorTypes: []string{"falcon-kac"}}
+
+	tests := []struct {
+		name   string
+		config config.Config
+		cid    string
+	}{
+		{name: "masked CID", cid: "0123************************cdef-ab"},
+		{name: "unmasked CID", config: config.Config{Sync: config.Sync{UnmaskCID: true}}, cid: testMemberCID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := withFakeAPI(t)
+			fake.responses = map[string]string{"/sensors/queries/installers/ccid/v1": `{"resources":["` + testMemberCID + `"]}`}
+			client, _, err := newFalconClient("token")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			l, _, err := getImages(context.Background(), client, "", "us-1", tt.config, cat, req, previous, nil)
+			if err != nil {
+				t.Fatalf("getImages() error = %v", err)
+			}
+			want := images.Metadata{
+				SchemaVersion:   images.SchemaVersion,
+				CID:             tt.cid,
+				Cloud:           "us-1",
+				RegistryHost:    "registry.crowdstrike.com",
+				FunctionVersion: version.Current(),
+			}
+			got := *l.Metadata
+			got.Dependencies = nil
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Metadata = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestNewRun(t *testing.T) {
+	run := newRun(api.SyncRequest{Trigger: "workflow"}, "us-2", fdk.Request{TraceID: "trace", FnID: "fn", FnVersion: 3})
+	if run.Trigger != "workflow" || run.Cloud != "us-2" || run.TraceID != "trace" || run.FnID != "fn" || run.FnVersion != 3 {
+		t.Errorf("newRun() = %+v, want the request identified", run)
+	}
+}
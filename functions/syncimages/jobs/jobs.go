@@ -0,0 +1,272 @@
+package jobs
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"syncimages/images"
+)
+
+// Collection is the collection storing the jobs, keyed by job ID. Job IDs start with the creation
+// time, so sorting the object keys sorts the jobs chronologically.
+const Collection = "sync_jobs"
+
+// Job states.
+const (
+	StatePending   = "pending"
+	StateRunning   = "running"
+	StateSucceeded = "succeeded"
+	StateFailed    = "failed"
+)
+
+// saveInterval limits how often tag progress is persisted. State changes are always persisted.
+const saveInterval = 2 * time.Second
+
+// heartbeatInterval is how often a running job is persisted without progress, so a job that is
+// no longer updated can be told apart from a slow one.
+const heartbeatInterval = 30 * time.Second
+
+// StaleAfter is how long a pending or running job can go without an update before it is reported
+// as failed: the function instance running it was stopped.
+const StaleAfter = 5 * heartbeatInterval
+
+// Job is the document stored in the sync_jobs collection for an asynchronous sync.
+type Job struct {
+	ID       string            `json:"id"`
+	State    string            `json:"state"`
+	Created  time.Time         `json:"created"`
+	Updated  time.Time         `json:"updated"`
+	Finished *time.Time        `json:"finished,omitempty"`
+	Sensors  []Sensor          `json:"sensors"`
+	Error    string            `json:"error,omitempty"`
+	Result   *images.ImageList `json:"result,omitempty"`
+}
+
+// Sensor is the progress of a single sensor type of the job.
+type Sensor struct {
+	SensorType    string `json:"sensorType"`
+	State         string `json:"state"`
+	TagsTotal     int    `json:"tagsTotal"`
+	TagsProcessed int    `json:"tagsProcessed"`
+	Error         string `json:"error,omitempty"`
+}
+
+// CheckStale returns the job, failed when it is pending or running and was not updated for
+// StaleAfter.
+func (j Job) CheckStale(now time.Time) Job {
+	if (j.State != StatePending && j.State != StateRunning) || now.Sub(j.Updated) < StaleAfter {
+		return j
+	}
+
+	j.State = StateFailed
+	j.Error = fmt.Sprintf("the job stopped reporting progress at %s, the function instance running it was stopped", j.Updated.UTC().Format(time.RFC3339))
+	j.Sensors = append([]Sensor{}, j.Sensors...)
+	for i := range j.Sensors {
+		if j.Sensors[i].State == StatePending || j.Sensors[i].State == StateRunning {
+			j.Sensors[i].State = StateFailed
+		}
+	}
+
+	return j
+}
+
+// Tracker records the progress of a job and persists it with the save function. A nil Tracker
+// ignores progress, so synchronous syncs can pass nil.
+type Tracker struct {
+	// mu guards the job and the save bookkeeping. It is not held while saving, so progress
+	// updates do not wait for the storage.
+	mu       sync.Mutex
+	job      Job
+	lastSave time.Time
+	// version counts the updates of the job, saved is the version of the last saved job.
+	version int
+	saved   int
+
+	// saveMu orders the saves, so an older job never replaces a newer one.
+	saveMu sync.Mutex
+	save   func(Job) error
+
+	stop chan struct{}
+}
+
+// NewID returns a job ID made of the creation time and a random suffix.
+func NewID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating job ID: %v", err)
+	}
+
+	return time.Now().UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(b), nil
+}
+
+// NewTracker creates a pending job for the sensor types and persists it.
+func NewTracker(id string, sensorTypes []string, save func(Job) error) (*Tracker, error) {
+	now := time.Now()
+	job := Job{
+		ID:      id,
+		State:   StatePending,
+		Created: now,
+		Updated: now,
+		Sensors: make([]Sensor, len(sensorTypes)),
+	}
+	for i, sensorType := range sensorTypes {
+		job.Sensors[i] = Sensor{SensorType: sensorType, State: StatePending}
+	}
+
+	t := &Tracker{job: job, save: save, stop: make(chan struct{})}
+	if err := t.persist(true); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// Job returns a copy of the current job.
+func (t *Tracker) Job() Job {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.job.copy()
+}
+
+// Start marks the job as running and persists it every heartbeatInterval until it finishes.
+func (t *Tracker) Start() {
+	if t == nil {
+		return
+	}
+
+	t.update(true, func(job *Job) {
+		job.State = StateRunning
+	})
+
+	go func() {
+		ticker := time.NewTicker(heartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-t.stop:
+				return
+			case <-ticker.C:
+				t.update(true, func(*Job) {})
+			}
+		}
+	}()
+}
+
+// SensorStarted marks the sensor type as running with the number of tags to process.
+func (t *Tracker) SensorStarted(sensorType string, tags int) {
+	if t == nil {
+		return
+	}
+
+	t.updateSensor(sensorType, true, func(s *Sensor) {
+		s.State = StateRunning
+		s.TagsTotal = tags
+	})
+}
+
+// TagProcessed counts a processed tag of the sensor type.
+func (t *Tracker) TagProcessed(sensorType string) {
+	if t == nil {
+		return
+	}
+
+	t.updateSensor(sensorType, false, func(s *Sensor) {
+		s.TagsProcessed++
+	})
+}
+
+// SensorFinished marks the sensor type as succeeded, or failed when err is set.
+func (t *Tracker) SensorFinished(sensorType string, err error) {
+	if t == nil {
+		return
+	}
+
+	t.updateSensor(sensorType, true, func(s *Sensor) {
+		s.State = StateSucceeded
+		if err != nil {
+			s.State = StateFailed
+			s.Error = err.Error()
+		}
+	})
+}
+
+// Finish marks the job as succeeded with the result, or failed when err is set.
+func (t *Tracker) Finish(result images.ImageList, err error) {
+	if t == nil {
+		return
+	}
+
+	close(t.stop)
+	t.update(true, func(job *Job) {
+		now := time.Now()
+		job.Finished = &now
+		if err != nil {
+			job.State = StateFailed
+			job.Error = err.Error()
+			return
+		}
+		job.State = StateSucceeded
+		job.Result = &result
+	})
+}
+
+func (t *Tracker) updateSensor(sensorType string, force bool, fn func(*Sensor)) {
+	t.update(force, func(job *Job) {
+		for i := range job.Sensors {
+			if job.Sensors[i].SensorType == sensorType {
+				fn(&job.Sensors[i])
+				return
+			}
+		}
+	})
+}
+
+func (t *Tracker) update(force bool, fn func(*Job)) {
+	t.mu.Lock()
+	fn(&t.job)
+	t.job.Updated = time.Now()
+	t.version++
+	id := t.job.ID
+	t.mu.Unlock()
+
+	if err := t.persist(force); err != nil {
+		// Progress is best effort, the job continues and the next update retries.
+		slog.Warn("failed to persist sync job", "job_id", id, "error", err)
+	}
+}
+
+// persist saves the job, at most once per saveInterval unless forced.
+func (t *Tracker) persist(force bool) error {
+	t.mu.Lock()
+	if !force && time.Since(t.lastSave) < saveInterval {
+		t.mu.Unlock()
+		return nil
+	}
+	t.lastSave = time.Now()
+	job, version := t.job.copy(), t.version
+	t.mu.Unlock()
+
+	t.saveMu.Lock()
+	defer t.saveMu.Unlock()
+	if version < t.saved {
+		// A newer job was saved meanwhile
+		return nil
+	}
+	if err := t.save(job); err != nil {
+		return err
+	}
+	t.saved = version
+
+	return nil
+}
+
+// copy returns the job with its own sensors, so it can be saved while the tracker updates them.
+func (j Job) copy() Job {
+	j.Sensors = append([]Sensor{}, j.Sensors...)
+	return j
+}
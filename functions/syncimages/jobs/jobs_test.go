@@ -0,0 +1,138 @@
+package jobs
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"syncimages/images"
+)
+
+func TestNewIDSortsChronologically(t *testing.T) {
+	first, err := NewID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := time.Parse("20060102T150405Z", strings.SplitN(first, "-", 2)[0]); err != nil {
+		t.Fatalf("NewID() = %q, want a creation time prefix: %v", first, err)
+	}
+
+	time.Sleep(time.Second)
+	second, err := NewID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{second, first}
+	sort.Strings(ids)
+	if ids[0] != first {
+		t.Errorf("sorted IDs = %v, want %q first", ids, first)
+	}
+}
+
+func TestCheckStale(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name    string
+		state   string
+		updated time.Time
+		want    string
+	}{
+		{name: "recent running", state: StateRunning, updated: now.Add(-time.Minute), want: StateRunning},
+		{name: "stale running", state: StateRunning, updated: now.Add(-StaleAfter), want: StateFailed},
+		{name: "stale pending", state: StatePending, updated: now.Add(-time.Hour), want: StateFailed},
+		{name: "old succeeded", state: StateSucceeded, updated: now.Add(-time.Hour), want: StateSucceeded},
+		{name: "old failed", state: StateFailed, updated: now.Add(-time.Hour), want: StateFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := Job{
+				State:   tt.state,
+				Updated: tt.updated,
+				Sensors: []Sensor{{SensorType: "falcon-sensor", State: StateRunning}, {SensorType: "falcon-kac", State: StateSucceeded}},
+			}
+			got := job.CheckStale(now)
+			if got.State != tt.want {
+				t.Errorf("CheckStale() state = %q, want %q", got.State, tt.want)
+			}
+			stale := got.State == StateFailed && tt.state != StateFailed
+			if stale != (got.Error != "") {
+				t.Errorf("CheckStale() error = %q, want an error only for stale jobs", got.Error)
+			}
+			if stale && (got.Sensors[0].State != StateFailed || got.Sensors[1].State != StateSucceeded) {
+				t.Errorf("CheckStale() sensors = %+v, want the running sensor failed", got.Sensors)
+			}
+			if job.Sensors[0].State != StateRunning {
+				t.Error("CheckStale() modified the sensors of the job")
+			}
+		})
+	}
+}
+
+func TestTracker(t *testing.T) {
+	var mu sync.Mutex
+	var saved []Job
+	tracker, err := NewTracker("job", []string{"falcon-sensor"}, func(job Job) error {
+		mu.Lock()
+		defer mu.Unlock()
+		saved = append(saved, job)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tracker.Start()
+	tracker.SensorStarted("falcon-sensor", 2)
+	tracker.TagProcessed("falcon-sensor")
+	tracker.TagProcessed("falcon-sensor")
+	tracker.SensorFinished("falcon-sensor", nil)
+	tracker.Finish(images.ImageList{}, nil)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(saved) == 0 {
+		t.Fatal("no job saved")
+	}
+	last := saved[len(saved)-1]
+	if last.State != StateSucceeded || last.Finished == nil || last.Result == nil {
+		t.Errorf("last saved job = %+v, want it succeeded with its result", last)
+	}
+	if s := last.Sensors[0]; s.State != StateSucceeded || s.TagsProcessed != 2 || s.TagsTotal != 2 {
+		t.Errorf("last saved sensor = %+v, want it succeeded with 2 of 2 tags", s)
+	}
+	for i := 1; i < len(saved); i++ {
+		if saved[i].Updated.Before(saved[i-1].Updated) {
+			t.Errorf("job saved at %s after the newer job saved at %s", saved[i].Updated, saved[i-1].Updated)
+		}
+	}
+}
+
+func TestTrackerFailed(t *testing.T) {
+	var last Job
+	tracker, err := NewTracker("job", []string{"falcon-sensor"}, func(job Job) error {
+		last = job
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tracker.Start()
+	tracker.Finish(images.ImageList{}, errors.New("registry unavailable"))
+	if last.State != StateFailed || last.Error != "registry unavailable" || last.Result != nil {
+		t.Errorf("saved job = %+v, want it failed with the error", last)
+	}
+}
+
+func TestNilTracker(t *testing.T) {
+	var tracker *Tracker
+	tracker.Start()
+	tracker.SensorStarted("falcon-sensor", 1)
+	tracker.TagProcessed("falcon-sensor")
+	tracker.SensorFinished("falcon-sensor", nil)
+	tracker.Finish(images.ImageList{}, nil)
+}
@@ -0,0 +1,218 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Redacted replaces the masked values.
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys are attribute keys masked as a whole, compared without case and separators.
+var sensitiveKeys = map[string]bool{
+	"auth":             true,
+	"authorization":    true,
+	"dockerauthconfig": true,
+	"dockerjson":       true,
+	"username":         true,
+}
+
+// sensitiveWords mask an attribute whose key ends with one of them, e.g. client_secret or
+// access_token, but not token_source or user_agent.
+var sensitiveWords = map[string]bool{
+	"auth":          true,
+	"authorization": true,
+	"secret":        true,
+	"token":         true,
+	"password":      true,
+	"passwd":        true,
+	"pass":          true,
+	"user":          true,
+	"username":      true,
+	"login":         true,
+}
+
+// sensitiveValues match secrets inside any logged string: bearer tokens, JWTs, base64 encoded
+// docker configs (which start with {"auths":) and auth fields of docker configs.
+var sensitiveValues = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile(`(?i)\b(bearer|basic)\s+[A-Za-z0-9\-._~+/]{16,}=*`), "$1 " + Redacted},
+	{regexp.MustCompile(`eyJ[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]*`), Redacted},
+	{regexp.MustCompile(`eyJhdXRocyI6[A-Za-z0-9+/=]*`), Redacted},
+	{regexp.MustCompile(`("auth"\s*:\s*)"[^"]*"`), `$1"` + Redacted + `"`},
+}
+
+// RedactingHandler masks secrets in the records before passing them to the wrapped handler.
+type RedactingHandler struct {
+	inner slog.Handler
+}
+
+// NewRedactingHandler returns a handler masking the attributes with sensitive keys and the
+// values that look like secrets.
+func NewRedactingHandler(inner slog.Handler) *RedactingHandler {
+	return &RedactingHandler{inner: inner}
+}
+
+// Enabled implements slog.Handler.
+func (h *RedactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+// Handle implements slog.Handler.
+func (h *RedactingHandler) Handle(ctx context.Context, record slog.Record) error {
+	redacted := slog.NewRecord(record.Time, record.Level, RedactString(record.Message), record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		redacted.AddAttrs(redactAttr(attr))
+		return true
+	})
+
+	return h.inner.Handle(ctx, redacted)
+}
+
+// WithAttrs implements slog.Handler.
+func (h *RedactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, 0, len(attrs))
+	for _, attr := range attrs {
+		redacted = append(redacted, redactAttr(attr))
+	}
+
+	return &RedactingHandler{inner: h.inner.WithAttrs(redacted)}
+}
+
+// WithGroup implements slog.Handler.
+func (h *RedactingHandler) WithGroup(name string) slog.Handler {
+	return &RedactingHandler{inner: h.inner.WithGroup(name)}
+}
+
+// RedactString masks the secrets found in the string.
+func RedactString(s string) string {
+	for _, v := range sensitiveValues {
+		s = v.pattern.ReplaceAllString(s, v.replacement)
+	}
+
+	return s
+}
+
+// redactAttr masks the attribute when its key is sensitive, and the secrets found in its value
+// otherwise.
+func redactAttr(attr slog.Attr) slog.Attr {
+	attr.Value = attr.Value.Resolve()
+	if attr.Value.Kind() != slog.KindGroup && sensitiveKey(attr.Key) {
+		if attr.Value.Kind() == slog.KindString && attr.Value.String() == "" {
+			return attr
+		}
+		return slog.String(attr.Key, Redacted)
+	}
+
+	switch attr.Value.Kind() {
+	case slog.KindString:
+		attr.Value = slog.StringValue(RedactString(attr.Value.String()))
+	case slog.KindGroup:
+		group := attr.Value.Group()
+		redacted := make([]slog.Attr, 0, len(group))
+		for _, a := range group {
+			redacted = append(redacted, redactAttr(a))
+		}
+		attr.Value = slog.GroupValue(redacted...)
+	case slog.KindAny:
+		switch v := attr.Value.Any().(type) {
+		case error:
+			attr.Value = slog.StringValue(RedactString(v.Error()))
+		case fmt.Stringer:
+			attr.Value = slog.StringValue(RedactString(v.String()))
+		case []string:
+			redacted := make([]string, 0, len(v))
+			for _, s := range v {
+				redacted = append(redacted, RedactString(s))
+			}
+			attr.Value = slog.AnyValue(redacted)
+		default:
+			attr.Value = slog.AnyValue(redactAny(v))
+		}
+	}
+
+	return attr
+}
+
+// redactAny returns the value as JSON data with the sensitive fields masked and the secrets
+// found in its strings masked. Values that cannot be encoded as JSON are formatted as strings.
+func redactAny(v interface{}) interface{} {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return RedactString(fmt.Sprintf("%+v", v))
+	}
+
+	var data interface{}
+	if err := json.Unmarshal(b, &data); err != nil {
+		return RedactString(string(b))
+	}
+
+	return redactJSON(data)
+}
+
+// redactJSON masks the fields of the decoded JSON data whose keys are sensitive and the secrets
+// found in its strings.
+func redactJSON(data interface{}) interface{} {
+	switch v := data.(type) {
+	case string:
+		return RedactString(v)
+	case []interface{}:
+		for i := range v {
+			v[i] = redactJSON(v[i])
+		}
+	case map[string]interface{}:
+		for key, value := range v {
+			if _, isObject := value.(map[string]interface{}); !isObject && value != "" && value != nil && sensitiveKey(key) {
+				v[key] = Redacted
+				continue
+			}
+			v[key] = redactJSON(value)
+		}
+	}
+
+	return data
+}
+
+// sensitiveKey reports whether the attribute key names a secret.
+func sensitiveKey(key string) bool {
+	words := splitWords(key)
+	if len(words) == 0 {
+		return false
+	}
+
+	return sensitiveKeys[strings.Join(words, "")] || sensitiveWords[words[len(words)-1]]
+}
+
+// splitWords splits a snake, kebab, dotted or camel case key into lower case words.
+func splitWords(key string) []string {
+	words := []string{}
+	var word strings.Builder
+	flush := func() {
+		if word.Len() > 0 {
+			words = append(words, word.String())
+			word.Reset()
+		}
+	}
+
+	runes := []rune(key)
+	for i, r := range runes {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			flush()
+			continue
+		case unicode.IsUpper(r) && i > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))):
+			flush()
+		}
+		word.WriteRune(unicode.ToLower(r))
+	}
+	flush()
+
+	return words
+}
@@ -0,0 +1,62 @@
+package pagination
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// Meta describes the position of a page in the full result.
+type Meta struct {
+	Total  int `json:"total"`
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+}
+
+// Parse parses the offset and limit query parameters. The limit defaults to defaultLimit and must
+// not exceed maxLimit.
+func Parse(values url.Values, defaultLimit int, maxLimit int) (offset int, limit int, err error) {
+	if offset, err = IntParam(values, "offset", 0); err != nil {
+		return 0, 0, err
+	}
+	if offset < 0 {
+		return 0, 0, fmt.Errorf("offset must not be negative")
+	}
+	if limit, err = IntParam(values, "limit", defaultLimit); err != nil {
+		return 0, 0, err
+	}
+	if limit < 1 || limit > maxLimit {
+		return 0, 0, fmt.Errorf("limit must be between 1 and %d", maxLimit)
+	}
+
+	return offset, limit, nil
+}
+
+// Slice returns the items between offset and offset+limit.
+func Slice[T any](items []T, offset int, limit int) []T {
+	if offset >= len(items) {
+		return []T{}
+	}
+
+	end := offset + limit
+	if end > len(items) {
+		end = len(items)
+	}
+
+	return items[offset:end]
+}
+
+// IntParam parses an integer query parameter.
+func IntParam(values url.Values, key string, fallback int) (int, error) {
+	value := values.Get(key)
+	if value == "" {
+		return fallback, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %v", key, value, err)
+	}
+
+	return n, nil
+}
@@ -0,0 +1,71 @@
+package pagination
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		offset int
+		limit  int
+		err    string
+	}{
+		{name: "defaults", limit: 20},
+		{name: "page", query: "offset=40&limit=100", offset: 40, limit: 100},
+		{name: "invalid offset", query: "offset=first", err: `invalid offset "first"`},
+		{name: "negative offset", query: "offset=-1", err: "offset must not be negative"},
+		{name: "zero limit", query: "limit=0", err: "limit must be between 1 and 100"},
+		{name: "large limit", query: "limit=101", err: "limit must be between 1 and 100"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			offset, limit, err := Parse(values, 20, 100)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("Parse() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if offset != tt.offset || limit != tt.limit {
+				t.Errorf("Parse() = %d, %d, want %d, %d", offset, limit, tt.offset, tt.limit)
+			}
+		})
+	}
+}
+
+func TestSlice(t *testing.T) {
+	items := []int{1, 2, 3, 4, 5}
+
+	tests := []struct {
+		name   string
+		offset int
+		limit  int
+		want   []int
+	}{
+		{name: "first page", limit: 2, want: []int{1, 2}},
+		{name: "last page", offset: 4, limit: 2, want: []int{5}},
+		{name: "past the end", offset: 5, limit: 2, want: []int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Slice(items, tt.offset, tt.limit); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Slice() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
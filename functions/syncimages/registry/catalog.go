@@ -0,0 +1,288 @@
+package registry
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/containers/image/v5/docker"
+	"github.com/containers/image/v5/types"
+	"github.com/docker/distribution/registry/api/errcode"
+)
+
+// catalogPageSize is the number of repositories requested per _catalog page.
+const catalogPageSize = 1000
+
+// ListRepositories returns the repositories of the registry using the _catalog endpoint.
+// Registries that do not implement the endpoint return an error.
+func (rc Config) ListRepositories(registry string) ([]string, error) {
+	repositories := []string{}
+	next := fmt.Sprintf("/v2/_catalog?n=%d", catalogPageSize)
+
+	for next != "" {
+		res, err := rc.get(rc.ctx, registry, next, "registry:catalog:*")
+		if err != nil {
+			return nil, fmt.Errorf("error listing repositories: %w", err)
+		}
+
+		var page struct {
+			Repositories []string `json:"repositories"`
+		}
+		err = json.NewDecoder(res.Body).Decode(&page)
+		res.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error parsing repository list: %w", err)
+		}
+
+		repositories = append(repositories, page.Repositories...)
+		next = nextLink(res.Header.Get("Link"))
+	}
+
+	return repositories, nil
+}
+
+// Ping checks the credentials with an authenticated request to the /v2/ endpoint of the registry.
+// Rejected requests return a StatusError.
+func (rc Config) Ping(registry string) error {
+	return statusError(docker.CheckAuth(rc.ctx, rc.sysCtx, rc.User, rc.Pass, registry))
+}
+
+// statusError returns the HTTP status of a containers/image registry error as a StatusError, so it
+// is classified like the errors of the _catalog requests. Other errors are returned as they are.
+func statusError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var unauthorized docker.ErrUnauthorizedForCredentials
+	if errors.As(err, &unauthorized) {
+		return &StatusError{Code: http.StatusUnauthorized, Body: err.Error()}
+	}
+
+	var registryErr errcode.Error
+	if errors.As(err, &registryErr) {
+		return &StatusError{Code: registryErr.Code.Descriptor().HTTPStatusCode, Body: err.Error()}
+	}
+
+	if match := statusPattern.FindStringSubmatch(err.Error()); match != nil {
+		code, _ := strconv.Atoi(match[1])
+		return &StatusError{Code: code, Body: err.Error()}
+	}
+
+	return err
+}
+
+// get performs an authenticated GET request against the registry API, following the
+// Basic or Bearer token challenge returned by the registry. containers/image has no call for
+// the _catalog endpoint and does not expose its token handling, so the challenge is answered
+// here. Discovery checks the credentials with Ping before listing the repositories.
+func (rc Config) get(ctx context.Context, registry string, path string, scope string) (*http.Response, error) {
+	client := rc.httpClient()
+	scheme := "https"
+
+	res, err := rc.do(ctx, client, scheme+"://"+registry+path, "")
+	if err != nil && rc.insecure() {
+		scheme = "http"
+		res, err = rc.do(ctx, client, scheme+"://"+registry+path, "")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode == http.StatusUnauthorized {
+		challenge := res.Header.Get("WWW-Authenticate")
+		res.Body.Close()
+
+		authorization, err := rc.authorize(ctx, client, challenge, scope)
+		if err != nil {
+			return nil, err
+		}
+
+		res, err = rc.do(ctx, client, scheme+"://"+registry+path, authorization)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if res.StatusCode != http.StatusOK {
+		defer res.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return nil, &StatusError{Code: res.StatusCode, Body: strings.TrimSpace(string(body))}
+	}
+
+	return res, nil
+}
+
+// do sends a GET request with the optional Authorization header.
+func (rc Config) do(ctx context.Context, client *http.Client, u string, authorization string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	return client.Do(req)
+}
+
+// authorize returns the Authorization header answering the registry challenge.
+func (rc Config) authorize(ctx context.Context, client *http.Client, challenge string, scope string) (string, error) {
+	scheme, params := parseChallenge(challenge)
+
+	switch scheme {
+	case "basic":
+		if rc.User == "" && rc.Pass == "" {
+			return "", &StatusError{Code: http.StatusUnauthorized, Body: "registry requires credentials"}
+		}
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		req.SetBasicAuth(rc.User, rc.Pass)
+		return req.Header.Get("Authorization"), nil
+	case "bearer":
+		token, err := rc.fetchToken(ctx, client, params, scope)
+		if err != nil {
+			return "", err
+		}
+		return "Bearer " + token, nil
+	default:
+		return "", fmt.Errorf("unsupported authentication challenge: %q", challenge)
+	}
+}
+
+// fetchToken requests a bearer token from the realm of the challenge.
+func (rc Config) fetchToken(ctx context.Context, client *http.Client, params map[string]string, scope string) (string, error) {
+	realm, err := url.Parse(params["realm"])
+	if err != nil || realm.Host == "" {
+		return "", fmt.Errorf("invalid token realm: %q", params["realm"])
+	}
+
+	query := realm.Query()
+	if service := params["service"]; service != "" {
+		query.Set("service", service)
+	}
+	if scope != "" {
+		query.Set("scope", scope)
+	}
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("error creating token request: %w", err)
+	}
+	if rc.User != "" || rc.Pass != "" {
+		req.SetBasicAuth(rc.User, rc.Pass)
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error requesting token: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return "", &StatusError{Code: res.StatusCode, Body: strings.TrimSpace(string(body))}
+	}
+
+	var payload struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&payload); err != nil {
+		return "", fmt.Errorf("error parsing token response: %w", err)
+	}
+	if payload.Token != "" {
+		return payload.Token, nil
+	}
+	if payload.AccessToken != "" {
+		return payload.AccessToken, nil
+	}
+
+	return "", fmt.Errorf("token response did not contain a token")
+}
+
+// httpClient returns the HTTP client used for registry API requests.
+func (rc Config) httpClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone() //nolint:forcetypeassert
+	if rc.insecure() {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true} // #nosec G402 -- only for registries configured as insecure
+	}
+
+	return &http.Client{Transport: transport, Timeout: 30 * time.Second}
+}
+
+// insecure reports whether TLS verification is disabled for the registry.
+func (rc Config) insecure() bool {
+	return rc.sysCtx != nil && rc.sysCtx.DockerInsecureSkipTLSVerify == types.OptionalBoolTrue
+}
+
+// StatusError is returned when the registry answers with an unexpected HTTP status.
+type StatusError struct {
+	Code int
+	Body string
+}
+
+func (e *StatusError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("registry returned status %d", e.Code)
+	}
+	return fmt.Sprintf("registry returned status %d: %s", e.Code, e.Body)
+}
+
+// parseChallenge parses a WWW-Authenticate header into its scheme and parameters.
+func parseChallenge(header string) (string, map[string]string) {
+	params := map[string]string{}
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(header), " ")
+
+	// Split on commas outside of quoted values, e.g. scope="repository:x:pull,push".
+	var parts []string
+	start, quoted := 0, false
+	for i, r := range rest {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == ',' && !quoted:
+			parts = append(parts, rest[start:i])
+			start = i + 1
+		}
+	}
+	parts = append(parts, rest[start:])
+
+	for _, part := range parts {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		params[strings.ToLower(key)] = strings.Trim(value, `"`)
+	}
+
+	return strings.ToLower(scheme), params
+}
+
+// nextLink returns the path of the next page from a Link header, or an empty string.
+func nextLink(header string) string {
+	if header == "" || !strings.Contains(header, `rel="next"`) {
+		return ""
+	}
+
+	start := strings.Index(header, "<")
+	end := strings.Index(header, ">")
+	if start < 0 || end <= start {
+		return ""
+	}
+
+	u, err := url.Parse(header[start+1 : end])
+	if err != nil {
+		return ""
+	}
+
+	return u.RequestURI()
+}
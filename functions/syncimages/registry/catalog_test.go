@@ -0,0 +1,192 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testToken = "test-token"
+
+// testRegistry returns a registry answering with Bearer token challenges. The token is issued to
+// the user and password, the catalog is paged by 2 repositories unless it is not implemented.
+func testRegistry(t *testing.T, repositories []string, catalog bool) string {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	var server *httptest.Server
+	authorized := func(w http.ResponseWriter, r *http.Request, scope string) bool {
+		if r.Header.Get("Authorization") == "Bearer "+testToken {
+			return true
+		}
+		challenge := fmt.Sprintf(`Bearer realm="%s/token",service="test"`, server.URL)
+		if scope != "" {
+			challenge += fmt.Sprintf(`,scope="%s"`, scope)
+		}
+		w.Header().Set("WWW-Authenticate", challenge)
+		w.WriteHeader(http.StatusUnauthorized)
+		return false
+	}
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"token": testToken})
+	})
+	mux.HandleFunc("/v2/", func(w http.ResponseWriter, r *http.Request) {
+		if authorized(w, r, "") {
+			w.WriteHeader(http.StatusOK)
+		}
+	})
+	mux.HandleFunc("/v2/_catalog", func(w http.ResponseWriter, r *http.Request) {
+		if !catalog {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if !authorized(w, r, "registry:catalog:*") {
+			return
+		}
+
+		page := repositories
+		if last := r.URL.Query().Get("last"); last != "" {
+			for i, repo := range repositories {
+				if repo == last {
+					page = repositories[i+1:]
+				}
+			}
+		}
+		if len(page) > 2 {
+			page = page[:2]
+			w.Header().Set("Link", fmt.Sprintf(`</v2/_catalog?last=%s&n=2>; rel="next"`, page[1]))
+		}
+		_ = json.NewEncoder(w).Encode(map[string][]string{"repositories": page})
+	})
+
+	server = httptest.NewTLSServer(mux)
+	t.Cleanup(server.Close)
+
+	return strings.TrimPrefix(server.URL, "https://")
+}
+
+func TestListRepositories(t *testing.T) {
+	repositories := []string{"falcon-kac/release/falcon-kac", "falcon-sensor/release/falcon-sensor", "falcon-jobcontroller/release/falcon-jobcontroller"}
+
+	tests := []struct {
+		name    string
+		user    string
+		pass    string
+		catalog bool
+		want    []string
+		code    int
+	}{
+		{name: "paged", user: "user", pass: "pass", catalog: true, want: repositories},
+		{name: "wrong password", user: "user", pass: "wrong", catalog: true, code: http.StatusUnauthorized},
+		{name: "not implemented", user: "user", pass: "pass", code: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host := testRegistry(t, repositories, tt.catalog)
+			rc := NewRegistryConfig(tt.user, tt.pass).WithContext(context.Background()).Insecure()
+
+			got, err := rc.ListRepositories(host)
+			var statusErr *StatusError
+			switch {
+			case tt.code != 0 && (!errors.As(err, &statusErr) || statusErr.Code != tt.code):
+				t.Fatalf("ListRepositories() error = %v, want status %d", err, tt.code)
+			case tt.code == 0 && err != nil:
+				t.Fatalf("ListRepositories() error = %v", err)
+			}
+			if tt.code == 0 && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ListRepositories() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListRepositoriesCanceled(t *testing.T) {
+	host := testRegistry(t, []string{"falcon-sensor/release/falcon-sensor"}, true)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := NewRegistryConfig("user", "pass").WithContext(ctx).Insecure().ListRepositories(host); !errors.Is(err, context.Canceled) {
+		t.Errorf("ListRepositories() error = %v, want context.Canceled", err)
+	}
+}
+
+func TestPing(t *testing.T) {
+	host := testRegistry(t, nil, true)
+
+	tests := []struct {
+		name string
+		pass string
+		code int
+	}{
+		{name: "valid", pass: "pass"},
+		{name: "wrong password", pass: "wrong", code: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewRegistryConfig("user", tt.pass).WithContext(context.Background()).Insecure().Ping(host)
+			var statusErr *StatusError
+			if tt.code == 0 && err != nil {
+				t.Fatalf("Ping() error = %v", err)
+			}
+			if tt.code != 0 && (!errors.As(err, &statusErr) || statusErr.Code != tt.code) {
+				t.Fatalf("Ping() error = %v, want status %d", err, tt.code)
+			}
+		})
+	}
+}
+
+func TestParseChallenge(t *testing.T) {
+	tests := []struct {
+		header string
+		scheme string
+		params map[string]string
+	}{
+		{header: `Basic realm="registry"`, scheme: "basic", params: map[string]string{"realm": "registry"}},
+		{
+			header: `Bearer realm="https://auth.example.com/token",service="registry",scope="repository:a/b:pull,push"`,
+			scheme: "bearer",
+			params: map[string]string{"realm": "https://auth.example.com/token", "service": "registry", "scope": "repository:a/b:pull,push"},
+		},
+		{header: "", scheme: "", params: map[string]string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.scheme, func(t *testing.T) {
+			scheme, params := parseChallenge(tt.header)
+			if scheme != tt.scheme || !reflect.DeepEqual(params, tt.params) {
+				t.Errorf("parseChallenge(%q) = %q, %v, want %q, %v", tt.header, scheme, params, tt.scheme, tt.params)
+			}
+		})
+	}
+}
+
+func TestNextLink(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{header: `</v2/_catalog?last=b&n=2>; rel="next"`, want: "/v2/_catalog?last=b&n=2"},
+		{header: `<https://registry.example.com/v2/_catalog?last=b&n=2>; rel="next"`, want: "/v2/_catalog?last=b&n=2"},
+		{header: `</v2/_catalog?last=b&n=2>; rel="prev"`, want: ""},
+		{header: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := nextLink(tt.header); got != tt.want {
+			t.Errorf("nextLink(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
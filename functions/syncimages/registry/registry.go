@@ -24,16 +24,18 @@ type Config struct {
 
 	ctx    context.Context
 	sysCtx *types.SystemContext
+	stats  *Stats
 }
 
-// NewRegistryConfig returns a new registry configuration.
+// NewRegistryConfig returns a new registry configuration. Empty credentials result in anonymous access.
 func NewRegistryConfig(user string, pass string) Config {
 	ctx := context.Background()
-	sysCtx := &types.SystemContext{
-		DockerAuthConfig: &types.DockerAuthConfig{
+	sysCtx := &types.SystemContext{}
+	if user != "" || pass != "" {
+		sysCtx.DockerAuthConfig = &types.DockerAuthConfig{
 			Username: user,
 			Password: pass,
-		},
+		}
 	}
 
 	return Config{
@@ -44,6 +46,22 @@ func NewRegistryConfig(user string, pass string) Config {
 	}
 }
 
+// WithContext returns a copy of the configuration using the context for registry requests.
+func (rc Config) WithContext(ctx context.Context) Config {
+	rc.ctx = ctx
+
+	return rc
+}
+
+// Insecure returns a copy of the configuration that skips TLS verification and falls back to plain HTTP.
+func (rc Config) Insecure() Config {
+	sysCtx := *rc.sysCtx
+	sysCtx.DockerInsecureSkipTLSVerify = types.OptionalBoolTrue
+	rc.sysCtx = &sysCtx
+
+	return rc
+}
+
 // getImageRef returns a reference to the specified image.
 func getImageRef(sensor string) (types.ImageReference, error) {
 	ref, err := reference.ParseNormalizedNamed(sensor)
@@ -65,7 +83,11 @@ func (rc Config) GetRepositoryTags(image string) ([]string, error) {
 		return nil, fmt.Errorf("error creating image reference: %v", err)
 	}
 
-	tags, err := docker.GetRepositoryTags(rc.ctx, rc.sysCtx, imgRef)
+	var tags []string
+	err = rc.call("list tags", func() (err error) {
+		tags, err = docker.GetRepositoryTags(rc.ctx, rc.sysCtx, imgRef)
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("error listing repository tags: %v", err)
 	}
@@ -81,16 +103,32 @@ func (rc Config) GetImageDigest(image string, tag string) (string, error) {
 		return "", fmt.Errorf("error parsing reference: %v", err)
 	}
 
-	digest, err := docker.GetDigest(rc.ctx, rc.sysCtx, imgRef)
+	var imageDigest string
+	err = rc.call("get digest", func() error {
+		d, err := docker.GetDigest(rc.ctx, rc.sysCtx, imgRef)
+		imageDigest = d.String()
+		return err
+	})
 	if err != nil {
 		return "", fmt.Errorf("error getting digest: %v", err)
 	}
 
-	return digest.String(), nil
+	return imageDigest, nil
 }
 
 // GetImageArchitecture returns the architecture for the specified image and tag.
 func (rc Config) GetImageArchitecture(image string, tag string) ([]string, error) {
+	var archs []string
+	err := rc.call("get architecture", func() (err error) {
+		archs, err = rc.getImageArchitecture(image, tag)
+		return err
+	})
+
+	return archs, err
+}
+
+// getImageArchitecture returns the architecture for the specified image and tag.
+func (rc Config) getImageArchitecture(image string, tag string) ([]string, error) {
 	image = fmt.Sprintf("//%s:%s", image, tag)
 	imgRef, err := docker.ParseReference(image)
 	if err != nil {
@@ -118,8 +156,12 @@ func (rc Config) GetImageArchitecture(image string, tag string) ([]string, error
 	}
 }
 
-// DockerConfigJson returns the Docker configuration JSON for the registry.
+// DockerConfigJson returns the Docker configuration JSON for the registry, or an empty string for anonymous access.
 func (rc Config) DockerConfigJson(registry string) string {
+	if rc.User == "" && rc.Pass == "" {
+		return ""
+	}
+
 	base64EncodedCreds := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf(`%s:%s`, rc.User, rc.Pass)))
 	base64EncodedAuth := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf(`{"auths":{"%s":{"auth": "%s"}}}`, registry, base64EncodedCreds)))
 
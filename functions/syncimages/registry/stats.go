@@ -0,0 +1,124 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/containers/image/v5/docker"
+	"github.com/docker/distribution/registry/api/errcode"
+)
+
+// maxAttempts is the number of attempts of a registry call before its error is returned.
+const maxAttempts = 3
+
+// retryBackoff is the delay before the first retry, doubled for every further retry.
+const retryBackoff = 500 * time.Millisecond
+
+// Stats counts the registry calls and retries made with a configuration. It is safe for
+// concurrent use.
+type Stats struct {
+	calls   atomic.Int64
+	retries atomic.Int64
+}
+
+// Calls returns the number of registry calls, including retries.
+func (s *Stats) Calls() int64 {
+	if s == nil {
+		return 0
+	}
+
+	return s.calls.Load()
+}
+
+// Retries returns the number of retried registry calls.
+func (s *Stats) Retries() int64 {
+	if s == nil {
+		return 0
+	}
+
+	return s.retries.Load()
+}
+
+// WithStats returns a copy of the configuration counting its registry calls in stats.
+func (rc Config) WithStats(stats *Stats) Config {
+	rc.stats = stats
+
+	return rc
+}
+
+// call runs the registry call, retrying transient errors with an exponential backoff, see
+// retryable.
+func (rc Config) call(name string, fn func() error) error {
+	backoff := retryBackoff
+
+	for attempt := 1; ; attempt++ {
+		if rc.stats != nil {
+			rc.stats.calls.Add(1)
+		}
+
+		err := fn()
+		if err == nil || attempt == maxAttempts || !retryable(rc.ctx, err) {
+			return err
+		}
+
+		slog.Debug("Retrying registry call", "call", name, "attempt", attempt, "error", err)
+		if rc.stats != nil {
+			rc.stats.retries.Add(1)
+		}
+
+		select {
+		case <-rc.ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// statusPattern matches the HTTP status codes in the errors of containers/image for responses
+// without a registry error code.
+var statusPattern = regexp.MustCompile(`(?:unexpected HTTP status: |invalid status code from registry |StatusCode: )(\d{3})`)
+
+// retryable reports whether the error of a registry call is transient: the registry failed
+// (5xx) or throttled the call (429), the call timed out or the connection failed. Other errors,
+// like unknown repositories or manifests and denied credentials, fail the same way again. Calls
+// are not retried once the context is done.
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var unauthorized docker.ErrUnauthorizedForCredentials
+	if errors.As(err, &unauthorized) {
+		return false
+	}
+
+	var registryErr errcode.Error
+	if errors.Is(err, docker.ErrTooManyRequests) || errors.As(err, &registryErr) && registryErr.Code == errcode.ErrorCodeTooManyRequests {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+
+	if match := statusPattern.FindStringSubmatch(err.Error()); match != nil {
+		status, _ := strconv.Atoi(match[1])
+		return status >= http.StatusInternalServerError || status == http.StatusTooManyRequests
+	}
+
+	return false
+}
@@ -0,0 +1,85 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/containers/image/v5/docker"
+	"github.com/docker/distribution/registry/api/errcode"
+)
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "server error", err: errors.New("reading manifest 7.18 in registry.example.com/sensor: received unexpected HTTP status: 503 Service Unavailable"), want: true},
+		{name: "invalid status", err: errors.New("fetching tags list: invalid status code from registry 502 (Bad Gateway)"), want: true},
+		{name: "too many requests", err: fmt.Errorf("fetching tags list: %w", docker.ErrTooManyRequests), want: true},
+		{name: "too many requests code", err: fmt.Errorf("reading manifest: %w", errcode.ErrorCodeTooManyRequests.WithMessage("slow down")), want: true},
+		{name: "throttled status", err: errors.New(`StatusCode: 429, "slow down"`), want: true},
+		{name: "timeout", err: fmt.Errorf("pinging container registry: %w", &net.DNSError{Err: "timeout", IsTimeout: true}), want: true},
+		{name: "connection reset", err: fmt.Errorf("reading manifest: %w", syscall.ECONNRESET), want: true},
+		{name: "unexpected EOF", err: fmt.Errorf("reading blob: %w", io.ErrUnexpectedEOF), want: true},
+		{name: "manifest unknown", err: fmt.Errorf("reading manifest: %w", errcode.Error{Code: errcode.ErrorCodeUnknown, Message: "manifest unknown"}), want: false},
+		{name: "not found status", err: errors.New("fetching tags list: invalid status code from registry 404 (Not Found)"), want: false},
+		{name: "denied", err: fmt.Errorf("reading manifest: %w", errcode.ErrorCodeDenied.WithMessage("requested access to the resource is denied")), want: false},
+		{name: "unauthorized", err: docker.ErrUnauthorizedForCredentials{Err: errors.New("invalid token")}, want: false},
+		{name: "canceled", err: fmt.Errorf("reading manifest: %w", context.Canceled), want: false},
+		{name: "other", err: errors.New("invalid reference format"), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryable(context.Background(), tt.err); got != tt.want {
+				t.Errorf("retryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if retryable(ctx, docker.ErrTooManyRequests) {
+		t.Error("retryable() = true with a canceled context, want false")
+	}
+}
+
+func TestCall(t *testing.T) {
+	tests := []struct {
+		name    string
+		errs    []error
+		calls   int64
+		retries int64
+		failed  bool
+	}{
+		{name: "success", errs: []error{nil}, calls: 1},
+		{name: "transient then success", errs: []error{docker.ErrTooManyRequests, nil}, calls: 2, retries: 1},
+		{name: "transient every time", errs: []error{docker.ErrTooManyRequests, docker.ErrTooManyRequests, docker.ErrTooManyRequests}, calls: 3, retries: 2, failed: true},
+		{name: "not found", errs: []error{errors.New("invalid status code from registry 404 (Not Found)")}, calls: 1, failed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats := &Stats{}
+			rc := Config{ctx: context.Background()}.WithStats(stats)
+			attempt := 0
+			err := rc.call("test", func() error {
+				err := tt.errs[attempt]
+				attempt++
+				return err
+			})
+			if (err != nil) != tt.failed {
+				t.Errorf("call() error = %v, want failed %v", err, tt.failed)
+			}
+			if stats.Calls() != tt.calls || stats.Retries() != tt.retries {
+				t.Errorf("call() made %d calls and %d retries, want %d and %d", stats.Calls(), stats.Retries(), tt.calls, tt.retries)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package runs
+
+import (
+	"fmt"
+	"net/url"
+
+	falconapi "syncimages/falcon"
+	"syncimages/pagination"
+)
+
+// Default and maximum page sizes of GET /sync-runs.
+const (
+	defaultLimit = 20
+	maxLimit     = 100
+)
+
+// Query selects the runs returned by GET /sync-runs.
+type Query struct {
+	// State only returns the runs with this state when set.
+	State string
+	// MemberCID only returns the runs of this MSSP member CID when set.
+	MemberCID string
+	Offset    int
+	Limit     int
+}
+
+// Page is a page of runs, newest first.
+type Page struct {
+	Meta      pagination.Meta `json:"meta"`
+	Resources []Run           `json:"resources"`
+}
+
+// ParseQuery parses the query parameters state, memberCid, offset and limit.
+func ParseQuery(values url.Values) (Query, error) {
+	q := Query{State: values.Get("state")}
+	switch q.State {
+	case "", StateSucceeded, StateFailed:
+	default:
+		return q, fmt.Errorf("invalid state %q, expected %s or %s", q.State, StateSucceeded, StateFailed)
+	}
+
+	var err error
+	if cid := values.Get("memberCid"); cid != "" {
+		if q.MemberCID, err = falconapi.ParseCID(cid); err != nil {
+			return q, err
+		}
+	}
+	if q.Offset, q.Limit, err = pagination.Parse(values, defaultLimit, maxLimit); err != nil {
+		return q, err
+	}
+
+	return q, nil
+}
+
+// Page returns the page of the keys, newest first. Without a state or member filter only the runs
+// of the page are read, otherwise every run is read to be filtered.
+func (q Query) Page(keys []string, read func(key string) (Run, error)) (Page, error) {
+	keys = Newest(keys)
+	page := Page{
+		Meta:      pagination.Meta{Offset: q.Offset, Limit: q.Limit},
+		Resources: []Run{},
+	}
+
+	if q.State == "" && q.MemberCID == "" {
+		page.Meta.Total = len(keys)
+		for _, key := range pagination.Slice(keys, q.Offset, q.Limit) {
+			run, err := read(key)
+			if err != nil {
+				return Page{}, err
+			}
+			page.Resources = append(page.Resources, run)
+		}
+		return page, nil
+	}
+
+	matching := []Run{}
+	for _, key := range keys {
+		run, err := read(key)
+		if err != nil {
+			return Page{}, err
+		}
+		if (q.State == "" || run.State == q.State) && (q.MemberCID == "" || run.MemberCID == q.MemberCID) {
+			matching = append(matching, run)
+		}
+	}
+	page.Meta.Total = len(matching)
+	page.Resources = append(page.Resources, pagination.Slice(matching, q.Offset, q.Limit)...)
+
+	return page, nil
+}
@@ -0,0 +1,119 @@
+package runs
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const testMemberCID = "0123456789abcdef0123456789abcdef-ab"
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  Query
+		err   string
+	}{
+		{name: "defaults", want: Query{Limit: defaultLimit}},
+		{name: "filters", query: "state=failed&memberCid=" + strings.ToUpper(testMemberCID) + "&offset=20&limit=10", want: Query{State: StateFailed, MemberCID: testMemberCID, Offset: 20, Limit: 10}},
+		{name: "unknown state", query: "state=running", err: `invalid state "running"`},
+		{name: "invalid member CID", query: "memberCid=abc", err: "invalid CID"},
+		{name: "large limit", query: "limit=1000", err: "limit must be between 1 and 100"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := ParseQuery(values)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("ParseQuery() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseQuery() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseQuery() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPage(t *testing.T) {
+	stored := map[string]Run{
+		"20240101T000000Z-a": {ID: "20240101T000000Z-a", State: StateSucceeded},
+		"20240102T000000Z-b": {ID: "20240102T000000Z-b", State: StateFailed, MemberCID: testMemberCID},
+		"20240103T000000Z-c": {ID: "20240103T000000Z-c", State: StateSucceeded, MemberCID: testMemberCID},
+		"20240104T000000Z-d": {ID: "20240104T000000Z-d", State: StateFailed},
+	}
+	keys := []string{"20240101T000000Z-a", "20240102T000000Z-b", "20240103T000000Z-c", "20240104T000000Z-d"}
+
+	tests := []struct {
+		name  string
+		query Query
+		total int
+		want  []string
+		read  int
+	}{
+		{name: "newest first", query: Query{Limit: 2}, total: 4, want: []string{"20240104T000000Z-d", "20240103T000000Z-c"}, read: 2},
+		{name: "second page", query: Query{Offset: 2, Limit: 2}, total: 4, want: []string{"20240102T000000Z-b", "20240101T000000Z-a"}, read: 2},
+		{name: "state", query: Query{State: StateFailed, Limit: 1}, total: 2, want: []string{"20240104T000000Z-d"}, read: 4},
+		{name: "member", query: Query{MemberCID: testMemberCID, Offset: 1, Limit: 5}, total: 2, want: []string{"20240102T000000Z-b"}, read: 4},
+		{name: "past the end", query: Query{Offset: 10, Limit: 5}, total: 4, want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			read := 0
+			page, err := tt.query.Page(keys, func(key string) (Run, error) {
+				read++
+				return stored[key], nil
+			})
+			if err != nil {
+				t.Fatalf("Page() error = %v", err)
+			}
+
+			got := []string{}
+			for _, run := range page.Resources {
+				got = append(got, run.ID)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Page() = %v, want %v", got, tt.want)
+			}
+			if page.Meta.Total != tt.total || page.Meta.Offset != tt.query.Offset || page.Meta.Limit != tt.query.Limit {
+				t.Errorf("Page().Meta = %+v, want total %d", page.Meta, tt.total)
+			}
+			if read != tt.read {
+				t.Errorf("Page() read %d runs, want %d", read, tt.read)
+			}
+		})
+	}
+}
+
+func TestPageReadError(t *testing.T) {
+	_, err := Query{Limit: 1}.Page([]string{"20240101T000000Z-a"}, func(string) (Run, error) {
+		return Run{}, errors.New("object not found")
+	})
+	if err == nil {
+		t.Error("Page() error = nil, want the read error")
+	}
+}
+
+func TestPageJSON(t *testing.T) {
+	b, err := json.Marshal(Page{Resources: []Run{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"meta":{"total":0,"offset":0,"limit":0},"resources":[]}`; string(b) != want {
+		t.Errorf("json.Marshal(Page) = %s, want %s", b, want)
+	}
+}
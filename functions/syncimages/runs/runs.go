@@ -0,0 +1,116 @@
+package runs
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"time"
+
+	"syncimages/webhook"
+)
+
+// Collection is the collection storing the sync runs. Run IDs start with the start time, so
+// sorting the object keys sorts the runs chronologically.
+const Collection = "sync_runs"
+
+// Run states.
+const (
+	StateSucceeded = "succeeded"
+	StateFailed    = "failed"
+	// StateNotEntitled is a sensor type skipped because the tenant lacks its API scope or
+	// subscription.
+	StateNotEntitled = "notEntitled"
+)
+
+// TriggerAPI is the trigger recorded when the request does not name one.
+const TriggerAPI = "api"
+
+// Run is the document stored in the sync_runs collection for every sync.
+type Run struct {
+	ID          string      `json:"id"`
+	Started     time.Time   `json:"started"`
+	Finished    time.Time   `json:"finished"`
+	DurationMs  int64       `json:"durationMs"`
+	Trigger     string      `json:"trigger"`
+	JobID       string      `json:"jobId,omitempty"`
+	TraceID     string      `json:"traceId,omitempty"`
+	FnID        string      `json:"fnId,omitempty"`
+	FnVersion   int         `json:"fnVersion,omitempty"`
+	Cloud       string      `json:"cloud"`
+	MemberCID   string      `json:"memberCid,omitempty"`
+	SensorTypes []string    `json:"sensorTypes,omitempty"`
+	DryRun      bool        `json:"dryRun,omitempty"`
+	State       string      `json:"state"`
+	Error       string      `json:"error,omitempty"`
+	Sensors     []SensorRun `json:"sensors"`
+	// Webhooks are the webhook deliveries of the changes found by the run.
+	Webhooks []webhook.Delivery `json:"webhooks,omitempty"`
+}
+
+// SensorRun is the outcome of a single sensor type of a run.
+type SensorRun struct {
+	SensorType    string `json:"sensorType"`
+	State         string `json:"state"`
+	DurationMs    int64  `json:"durationMs"`
+	Tags          int    `json:"tags"`
+	RegistryCalls int64  `json:"registryCalls"`
+	Retries       int64  `json:"retries"`
+	Error         string `json:"error,omitempty"`
+	// Reason is why a sensor type is not entitled, e.g. missingScope.
+	Reason string `json:"reason,omitempty"`
+}
+
+// New starts a run for the trigger and cloud.
+func New(trigger string, cloud string) Run {
+	if trigger == "" {
+		trigger = TriggerAPI
+	}
+
+	started := time.Now().UTC()
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+
+	return Run{
+		ID:      started.Format("20060102T150405Z") + "-" + hex.EncodeToString(suffix),
+		Started: started,
+		Trigger: trigger,
+		Cloud:   cloud,
+		Sensors: []SensorRun{},
+	}
+}
+
+// Finish records the end of the run with the outcome of its sensor types.
+func (r *Run) Finish(sensors []SensorRun, err error) {
+	r.Finished = time.Now().UTC()
+	r.DurationMs = r.Finished.Sub(r.Started).Milliseconds()
+	if sensors != nil {
+		r.Sensors = sensors
+	}
+
+	r.State = StateSucceeded
+	if err != nil {
+		r.State = StateFailed
+		r.Error = err.Error()
+	}
+}
+
+// Expired returns the oldest keys exceeding the retention, oldest first. Keys must sort
+// chronologically, like run IDs.
+func Expired(keys []string, retention int) []string {
+	if len(keys) <= retention {
+		return nil
+	}
+
+	sorted := append([]string{}, keys...)
+	sort.Strings(sorted)
+
+	return sorted[:len(sorted)-retention]
+}
+
+// Newest returns the keys sorted from the newest to the oldest run.
+func Newest(keys []string) []string {
+	sorted := append([]string{}, keys...)
+	sort.Sort(sort.Reverse(sort.StringSlice(sorted)))
+
+	return sorted
+}
@@ -0,0 +1,28 @@
+package runs
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpired(t *testing.T) {
+	keys := []string{"20240103T000000Z-c", "20240101T000000Z-a", "20240104T000000Z-d", "20240102T000000Z-b"}
+
+	tests := []struct {
+		name      string
+		retention int
+		want      []string
+	}{
+		{name: "within the retention", retention: 4},
+		{name: "oldest first", retention: 2, want: []string{"20240101T000000Z-a", "20240102T000000Z-b"}},
+		{name: "none kept", retention: 0, want: []string{"20240101T000000Z-a", "20240102T000000Z-b", "20240103T000000Z-c", "20240104T000000Z-d"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Expired(keys, tt.retention); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Expired() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
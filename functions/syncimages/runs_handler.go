@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	falconapi "syncimages/falcon"
+	"syncimages/jobs"
+	"syncimages/runs"
+
+	fdk "github.com/CrowdStrike/foundry-fn-go"
+)
+
+// syncRunsHandler returns a page of the recorded sync runs, newest first, optionally only those
+// with the state query parameter (succeeded or failed).
+func syncRunsHandler(logger *slog.Logger) fdk.Handler {
+	return jsonHandler(logger, func(ctx context.Context, r fdk.Request) (interface{}, error) {
+		q, err := runs.ParseQuery(r.Queries)
+		if err != nil {
+			return nil, badRequest(err)
+		}
+
+		client, err := apiClient(r)
+		if err != nil {
+			return nil, err
+		}
+
+		keys, err := falconapi.ListObjects(ctx, client, runs.Collection)
+		if err != nil {
+			return nil, fmt.Errorf("error listing sync runs: %w", err)
+		}
+
+		page, err := q.Page(keys, func(key string) (runs.Run, error) {
+			var run runs.Run
+			err := falconapi.ReadObject(ctx, client, runs.Collection, key, &run)
+			return run, err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error reading sync runs: %w", err)
+		}
+
+		return page, nil
+	})
+}
+
+// syncJobHandler returns the progress of an asynchronous sync job, and its result once it succeeded.
+// A job that stopped reporting progress is returned as failed.
+func syncJobHandler(logger *slog.Logger) fdk.Handler {
+	return jsonHandler(logger, func(ctx context.Context, r fdk.Request) (interface{}, error) {
+		client, err := apiClient(r)
+		if err != nil {
+			return nil, err
+		}
+
+		id := pathParam(ctx, "id")
+		var job jobs.Job
+		err = falconapi.ReadObject(ctx, client, jobs.Collection, id, &job)
+		if errors.Is(err, falconapi.ErrObjectNotFound) {
+			return nil, notFound(fmt.Errorf("sync job not found: %q", id))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading sync job %s: %w", id, err)
+		}
+
+		return job.CheckStale(time.Now()), nil
+	})
+}
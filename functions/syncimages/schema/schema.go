@@ -0,0 +1,118 @@
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema is a JSON schema document.
+type Schema map[string]interface{}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Generate returns the JSON schema of the value's type. Field names follow the json struct tags,
+// and the description, enum, minimum and maximum struct tags are added to the field schemas.
+// Fields tagged required:"true" are listed as required.
+// Strict schemas reject properties that are not defined on the structs.
+func Generate(v interface{}, strict bool) Schema {
+	s := generate(reflect.TypeOf(v), strict)
+	s["$schema"] = "https://json-schema.org/draft-07/schema#"
+
+	return s
+}
+
+// MarshalIndent returns the schema as indented JSON with a trailing newline.
+func (s Schema) MarshalIndent() ([]byte, error) {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(b, '\n'), nil
+}
+
+func generate(t reflect.Type, strict bool) Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t == timeType {
+		return Schema{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		properties := Schema{}
+		required := addFields(t, properties, strict)
+		s := Schema{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			s["required"] = required
+		}
+		if strict {
+			s["additionalProperties"] = false
+		}
+		return s
+	case reflect.Slice, reflect.Array:
+		return Schema{"type": "array", "items": generate(t.Elem(), strict)}
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": generate(t.Elem(), strict)}
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Schema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	default:
+		return Schema{}
+	}
+}
+
+// addFields adds the exported fields of the struct, including those of embedded structs, to properties
+// and returns the names of the required fields.
+func addFields(t reflect.Type, properties Schema, strict bool) []string {
+	required := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			required = append(required, addFields(field.Type, properties, strict)...)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		s := generate(field.Type, strict)
+		if description := field.Tag.Get("description"); description != "" {
+			s["description"] = description
+		}
+		if enum := field.Tag.Get("enum"); enum != "" {
+			s["enum"] = strings.Split(enum, ",")
+		}
+		if minimum, err := strconv.Atoi(field.Tag.Get("minimum")); err == nil {
+			s["minimum"] = minimum
+		}
+		if maximum, err := strconv.Atoi(field.Tag.Get("maximum")); err == nil {
+			s["maximum"] = maximum
+		}
+		properties[name] = s
+		if field.Tag.Get("required") == "true" {
+			required = append(required, name)
+		}
+	}
+
+	return required
+}
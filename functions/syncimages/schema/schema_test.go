@@ -0,0 +1,126 @@
+package schema
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"syncimages/api"
+)
+
+// Base is embedded by testRequest, whose schema holds its fields.
+type Base struct {
+	Inherited string `json:"inherited"`
+}
+
+type testRequest struct {
+	Base
+	Name     string         `json:"name" description:"Name of the request."`
+	Limit    int            `json:"limit,omitempty" minimum:"0" maximum:"10"`
+	Mode     string         `json:"mode" enum:"fast,slow"`
+	Ratio    float64        `json:"ratio"`
+	Enabled  bool           `json:"enabled"`
+	Tags     []string       `json:"tags"`
+	Labels   map[string]int `json:"labels"`
+	Started  time.Time      `json:"started"`
+	Parent   *Base          `json:"parent"`
+	Untagged string
+	Secret   string `json:"-"`
+	private  string
+	Options  map[string]string `json:"options,omitempty"`
+}
+
+func TestGenerate(t *testing.T) {
+	object := func(strict bool, properties Schema) Schema {
+		s := Schema{"type": "object", "properties": properties}
+		if strict {
+			s["additionalProperties"] = false
+		}
+		return s
+	}
+
+	for _, strict := range []bool{false, true} {
+		got := Generate(testRequest{}, strict)
+
+		want := object(strict, Schema{
+			"inherited": Schema{"type": "string"},
+			"name":      Schema{"type": "string", "description": "Name of the request."},
+			"limit":     Schema{"type": "integer", "minimum": 0, "maximum": 10},
+			"mode":      Schema{"type": "string", "enum": []string{"fast", "slow"}},
+			"ratio":     Schema{"type": "number"},
+			"enabled":   Schema{"type": "boolean"},
+			"tags":      Schema{"type": "array", "items": Schema{"type": "string"}},
+			"labels":    Schema{"type": "object", "additionalProperties": Schema{"type": "integer"}},
+			"started":   Schema{"type": "string", "format": "date-time"},
+			"parent":    object(strict, Schema{"inherited": Schema{"type": "string"}}),
+			"Untagged":  Schema{"type": "string"},
+			"options":   Schema{"type": "object", "additionalProperties": Schema{"type": "string"}},
+		})
+		want["$schema"] = "https://json-schema.org/draft-07/schema#"
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Generate(strict=%v) = %v, want %v", strict, got, want)
+		}
+	}
+}
+
+// TestSchemasUpToDate fails when the schemas referenced by manifest.yml were not regenerated with
+// go generate after a change of the api package.
+func TestSchemasUpToDate(t *testing.T) {
+	schemas := map[string]Schema{
+		"sync_images_request.json":        Generate(api.SyncRequest{}, true),
+		"sync_images_response.json":       Generate(api.SyncResponse{}, false),
+		"recommended_image_request.json":  Generate(api.RecommendedImageRequest{}, true),
+		"recommended_image_response.json": Generate(api.ImageRef{}, false),
+	}
+
+	for name, s := range schemas {
+		t.Run(name, func(t *testing.T) {
+			want, err := s.MarshalIndent()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := os.ReadFile(filepath.Join("..", "schemas", name))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var gotJSON, wantJSON interface{}
+			if err := json.Unmarshal(got, &gotJSON); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal(want, &wantJSON); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(gotJSON, wantJSON) {
+				t.Errorf("schemas/%s is outdated, run go generate ./...", name)
+			}
+		})
+	}
+}
+
+func TestGenerateRequired(t *testing.T) {
+	type required struct {
+		Base
+		Name   string `json:"name" required:"true"`
+		Note   string `json:"note,omitempty"`
+		Nested struct {
+			ID string `json:"id" required:"true"`
+		} `json:"nested"`
+	}
+
+	got := Generate(required{}, true)
+	if want := []string{"name"}; !reflect.DeepEqual(got["required"], want) {
+		t.Errorf("required = %v, want %v", got["required"], want)
+	}
+	nested := got["properties"].(Schema)["nested"].(Schema)
+	if want := []string{"id"}; !reflect.DeepEqual(nested["required"], want) {
+		t.Errorf("nested required = %v, want %v", nested["required"], want)
+	}
+	if _, ok := Generate(Base{}, true)["required"]; ok {
+		t.Error("Generate(Base) lists required fields, want none")
+	}
+}
@@ -0,0 +1,33 @@
+//go:build ignore
+
+// generate writes the JSON schemas of the function handlers referenced by manifest.yml.
+// Run it with go generate from functions/syncimages.
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+
+	"syncimages/api"
+	"syncimages/schema"
+)
+
+func main() {
+	schemas := map[string]schema.Schema{
+		"sync_images_request.json":        schema.Generate(api.SyncRequest{}, true),
+		"sync_images_response.json":       schema.Generate(api.SyncResponse{}, false),
+		"recommended_image_request.json":  schema.Generate(api.RecommendedImageRequest{}, true),
+		"recommended_image_response.json": schema.Generate(api.ImageRef{}, false),
+	}
+
+	for name, s := range schemas {
+		b, err := s.MarshalIndent()
+		if err != nil {
+			log.Fatalf("error encoding %s: %v", name, err)
+		}
+		if err := os.WriteFile(filepath.Join("schemas", name), b, 0o600); err != nil {
+			log.Fatalf("error writing %s: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,420 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"syncimages/api"
+	"syncimages/catalog"
+	"syncimages/config"
+	"syncimages/credentials"
+	"syncimages/encryption"
+	falconapi "syncimages/falcon"
+	"syncimages/images"
+	"syncimages/jobs"
+	"syncimages/registry"
+	"syncimages/runs"
+	"syncimages/webhook"
+
+	fdk "github.com/CrowdStrike/foundry-fn-go"
+	"github.com/crowdstrike/gofalcon/falcon"
+	"github.com/crowdstrike/gofalcon/falcon/client"
+)
+
+// member is the CID a sync gets the images and registry credentials of: the CID of the app, or an
+// MSSP member CID with an API client acting on it.
+type member struct {
+	// cid is the member CID, empty for the CID of the app.
+	cid string
+	// client is the API client of the CID.
+	client *client.CrowdStrikeAPISpecification
+}
+
+// syncMembers syncs the images of the MSSP member CIDs one after the other, each with an API
+// client acting on the member, and stores them with the client of the app. A failed member does
+// not stop the sync of the others.
+func syncMembers(ctx context.Context, client *client.CrowdStrikeAPISpecification, cloud string, cfg config.Config, cat catalog.Catalog, req api.SyncRequest, cids []string, store bool, r fdk.Request) api.SyncResponse {
+	lists := map[string]images.ImageList{}
+	errs := map[string]error{}
+	for _, cid := range cids {
+		imageData, err := syncMember(ctx, client, cloud, cfg, cat, req, cid, store, r)
+		if err != nil {
+			slog.Error("failed to sync member CID", "member_cid", cid, "error", err)
+			errs[cid] = err
+			continue
+		}
+		lists[cid] = imageData
+	}
+
+	return api.NewMembersSyncResponse(cids, lists, errs)
+}
+
+// syncMember syncs the images of a single MSSP member CID, unless its stored images are more
+// recent than the minimum sync interval.
+func syncMember(ctx context.Context, client *client.CrowdStrikeAPISpecification, cloud string, cfg config.Config, cat catalog.Catalog, req api.SyncRequest, cid string, store bool, r fdk.Request) (images.ImageList, error) {
+	if err := ctx.Err(); err != nil {
+		return images.ImageList{}, err
+	}
+
+	memberClient, err := newMemberClient(cloud, cid, cfg.Members)
+	if err != nil {
+		return images.ImageList{}, err
+	}
+
+	previous := images.ImageList{}
+	if store {
+		previous, err = readImages(client, cid)
+		if err != nil && statusFor(err) != http.StatusNotFound {
+			return images.ImageList{}, err
+		}
+	}
+
+	selected := req.SensorTypes
+	if len(selected) == 0 {
+		selected = cat.SensorTypes()
+	}
+	if minInterval := cfg.Sync.MinInterval(); !req.Force && minInterval > 0 && previous.Fresh(selected, minInterval, time.Now()) {
+		slog.Info("skipping sync of member CID, stored images are recent", "member_cid", cid, "min_interval", minInterval)
+		previous.Changes = nil
+		return previous, nil
+	}
+
+	return runSync(ctx, client, member{cid: cid, client: memberClient}, cloud, cfg, cat, req, previous, store, nil, newRun(req, cloud, r))
+}
+
+// newRun starts the run of a sync, identified by the function request.
+func newRun(req api.SyncRequest, cloud string, r fdk.Request) runs.Run {
+	run := runs.New(req.Trigger, cloud)
+	run.TraceID = r.TraceID
+	run.FnID = r.FnID
+	run.FnVersion = r.FnVersion
+
+	return run
+}
+
+// runSync syncs the images of the member selected by the request, merges them with the previously
+// stored images and, when store is set and this is not a dry run, writes them to the images
+// collection with the client of the app. When store is set the run is recorded in the sync_runs
+// collection.
+func runSync(ctx context.Context, client *client.CrowdStrikeAPISpecification, m member, cloud string, cfg config.Config, cat catalog.Catalog, req api.SyncRequest, previous images.ImageList, store bool, tracker *jobs.Tracker, run runs.Run) (images.ImageList, error) {
+	run.SensorTypes = req.SensorTypes
+	run.DryRun = req.DryRun
+	run.MemberCID = m.cid
+
+	imageData, sensorRuns, err := getImages(ctx, m.client, m.cid, cloud, cfg, cat, req, previous, tracker)
+	notify := false
+	if err == nil {
+		imageData.MemberCID = m.cid
+		imageData.Metadata.Request = images.Request{
+			FnID:      run.FnID,
+			FnVersion: run.FnVersion,
+			TraceID:   run.TraceID,
+			Trigger:   run.Trigger,
+			RunID:     run.ID,
+			JobID:     run.JobID,
+		}
+		// The history is carried over before merging, so the images and tags kept from the
+		// previous list are not marked as seen by this sync.
+		// Only the synced images hold credentials, the images kept by a merge do not.
+		synced := imageData.Images
+		imageData.CarryOver(previous, imageData.Updated)
+		imageData.KeepStoredTags(previous)
+		if len(req.SensorTypes) > 0 {
+			imageData.Merge(previous, cat.SensorTypes())
+		}
+		imageData.Changes = imageData.Diff(previous)
+
+		creds := []credentials.Credential{}
+		if store && !cfg.Sync.SkipCredentials {
+			var changes []images.Change
+			creds, changes, err = syncCredentials(ctx, client, m, cloud, cfg, cat, synced, imageData.Updated)
+			imageData.Changes = append(imageData.Changes, changes...)
+		}
+		for i := range imageData.Changes {
+			imageData.Changes[i].MemberCID = m.cid
+		}
+		slog.Info("Computed tag changes", "change_count", len(imageData.Changes))
+
+		if err == nil && store && !req.DryRun {
+			// The changes are written first: if the images were written without them, the next
+			// sync would diff against the new images and the changes would be lost.
+			err = recordChanges(ctx, client, run.ID, imageData, cfg.Sync.ChangesKept())
+			if err == nil {
+				// The credentials are written before the images referencing them.
+				err = storeCredentials(ctx, client, m.cid, creds, imageData, cfg.Encryption, cfg.Sync.SkipCredentials)
+			}
+			if err == nil {
+				err = falconapi.WriteToCollection(client, images.ListKey(m.cid), imageData)
+			}
+			notify = err == nil && len(cfg.Webhooks) > 0
+		}
+	}
+
+	run.Finish(sensorRuns, err)
+	if store {
+		// The run history must not fail the sync, and is recorded even when the sync timed out.
+		if recordErr := recordRun(context.WithoutCancel(ctx), client, run, cfg.Sync.RunsKept()); recordErr != nil {
+			slog.Warn("failed to record sync run", "run_id", run.ID, "error", recordErr)
+		}
+	}
+
+	if notify {
+		// The webhooks are called once the sync is recorded, so slow receivers cannot lose it. The
+		// images are stored, so a sync timeout must not cancel the notifications.
+		run.Webhooks = webhook.Notify(context.WithoutCancel(ctx), cfg.Webhooks, imageData.Changes, cfg.Sync.UnmaskCID)
+		if recordErr := falconapi.WriteObject(context.WithoutCancel(ctx), client, runs.Collection, run.ID, run); recordErr != nil {
+			slog.Warn("failed to record webhook deliveries", "run_id", run.ID, "error", recordErr)
+		}
+	}
+
+	if err != nil {
+		return images.ImageList{}, err
+	}
+
+	return imageData, nil
+}
+
+// recordRun writes the run to the sync_runs collection and deletes the runs exceeding the retention.
+func recordRun(ctx context.Context, client *client.CrowdStrikeAPISpecification, run runs.Run, retention int) error {
+	if err := falconapi.WriteObject(ctx, client, runs.Collection, run.ID, run); err != nil {
+		return err
+	}
+
+	return pruneCollection(ctx, client, runs.Collection, retention)
+}
+
+// syncCredentials returns the credentials of the synced images of the member and the changes of
+// their logins and passwords since they were stored. The stored credentials of the member that
+// were not synced and expire soon, or are encrypted with a key that is not configured anymore,
+// are renewed, so the pull secrets of consumers are replaced before they stop working.
+func syncCredentials(ctx context.Context, client *client.CrowdStrikeAPISpecification, m member, cloud string, cfg config.Config, cat catalog.Catalog, synced []images.Image, updated time.Time) ([]credentials.Credential, []images.Change, error) {
+	stored, err := readCredentials(ctx, client, m.cid)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	imgs := synced
+	syncedCreds := credentials.FromImages(synced, updated)
+	renew := append(credentials.Expiring(stored, syncedCreds, updated, cfg.Sync.CredentialRenewal()), credentials.Stale(cfg.Encryption, stored, syncedCreds)...)
+	if len(renew) > 0 {
+		renewed, err := renewCredentials(ctx, m, cloud, cat, renew)
+		if err != nil {
+			// Renewal is retried on the next sync
+			slog.Warn("failed to renew expiring or stale credentials", "error", err)
+		}
+		imgs = append(append([]images.Image{}, synced...), renewed...)
+	}
+
+	creds := credentials.FromImages(imgs, updated)
+	if err := credentials.CarryOver(cfg.Encryption, stored, creds); err != nil {
+		return nil, nil, err
+	}
+	syncedTypes := make([]string, 0, len(imgs))
+	for _, img := range imgs {
+		syncedTypes = append(syncedTypes, img.SensorType)
+	}
+	credentials.KeepSensorTypes(stored, creds, syncedTypes, cat.SensorTypes())
+	changes, err := credentials.Changes(cfg.Encryption, stored, imgs, updated)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return creds, changes, nil
+}
+
+// readCredentials returns the stored credentials of the member CID by reference.
+func readCredentials(ctx context.Context, client *client.CrowdStrikeAPISpecification, memberCID string) (map[string]credentials.Credential, error) {
+	refs, err := falconapi.ListObjects(ctx, client, credentials.Collection)
+	if err != nil {
+		return nil, fmt.Errorf("error listing credentials: %v", err)
+	}
+
+	stored := map[string]credentials.Credential{}
+	for _, ref := range refs {
+		if !credentials.Owned(memberCID, ref) {
+			continue
+		}
+
+		var cred credentials.Credential
+		if err := falconapi.ReadObject(ctx, client, credentials.Collection, ref, &cred); err != nil {
+			return nil, fmt.Errorf("error reading credential %q: %v", ref, err)
+		}
+		stored[ref] = cred
+	}
+
+	return stored, nil
+}
+
+// renewCredentials fetches new registry credentials of the member for the expiring or stale credentials
+// and verifies them against the registry. It returns an image holding only the renewed
+// credentials for every catalog target using one of them.
+func renewCredentials(ctx context.Context, m member, cloud string, cat catalog.Catalog, renew []credentials.Credential) ([]images.Image, error) {
+	refs := map[string]bool{}
+	for _, cred := range renew {
+		refs[cred.Ref] = true
+	}
+
+	cid, err := falconapi.GetCID(ctx, m.client)
+	if err != nil {
+		return nil, err
+	}
+
+	type login struct{ user, pass string }
+	renewedLogins := map[string]login{}
+	renewed := []images.Image{}
+	for _, target := range cat.Targets(falcon.Cloud(cloud)) {
+		ref := credentials.MemberRef(m.cid, target.CredentialRef())
+		if !refs[ref] {
+			continue
+		}
+
+		l, ok := renewedLogins[ref]
+		if !ok {
+			slog.Info("Renewing credential", "ref", ref)
+			l.user, l.pass, err = registryCredentials(ctx, m.client, cid, target.Credentials)
+			if err != nil {
+				return nil, fmt.Errorf("error renewing credential %q: %v", ref, err)
+			}
+		}
+
+		rc := registry.NewRegistryConfig(l.user, l.pass).WithContext(ctx)
+		if target.Insecure {
+			rc = rc.Insecure()
+		}
+		if !ok {
+			if _, err := rc.GetRepositoryTags(target.Repository); err != nil {
+				return nil, fmt.Errorf("error verifying renewed credential %q: %v", ref, err)
+			}
+			renewedLogins[ref] = l
+		}
+
+		host := strings.Split(target.Repository, "/")[0]
+		renewed = append(renewed, images.Image{
+			SensorType:    target.SensorType,
+			Registry:      host,
+			Repository:    target.Repository,
+			CredentialRef: ref,
+			Login:         l.user,
+			Password:      l.pass,
+			DockerJson:    rc.DockerConfigJson(host),
+		})
+	}
+
+	return renewed, nil
+}
+
+// storeCredentials writes the credentials of the member CID to the credentials collection,
+// encrypted when a key is configured, and deletes the stored credentials of the member no image of
+// the list references anymore, or every stored credential of the member when skip is set. The
+// stored credentials that were not synced are re-encrypted when the active key changed.
+func storeCredentials(ctx context.Context, client *client.CrowdStrikeAPISpecification, memberCID string, creds []credentials.Credential, imageData images.ImageList, keys encryption.Config, skip bool) error {
+	written := map[string]bool{}
+	referenced := map[string]bool{}
+	if !skip {
+		for _, cred := range creds {
+			if keys.Enabled() {
+				var err error
+				if cred, err = cred.Encrypt(keys); err != nil {
+					return err
+				}
+			}
+			if err := falconapi.WriteObject(ctx, client, credentials.Collection, cred.Ref, cred); err != nil {
+				return fmt.Errorf("error storing credential %q: %v", cred.Ref, err)
+			}
+			written[cred.Ref] = true
+		}
+		for _, img := range imageData.Images {
+			referenced[img.CredentialRef] = true
+		}
+	}
+
+	stored, err := falconapi.ListObjects(ctx, client, credentials.Collection)
+	if err != nil {
+		return fmt.Errorf("error listing credentials: %v", err)
+	}
+	for _, ref := range stored {
+		switch {
+		case !credentials.Owned(memberCID, ref), written[ref]:
+		case referenced[ref]:
+			if err := rotateCredential(ctx, client, ref, keys); err != nil {
+				return err
+			}
+		default:
+			if err := falconapi.DeleteObject(ctx, client, credentials.Collection, ref); err != nil {
+				return fmt.Errorf("error deleting credential %q: %v", ref, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// rotateCredential re-encrypts the stored credential with the active key when it is encrypted
+// with another key or not encrypted. A credential encrypted with a key that is not configured
+// anymore is left for the next sync to renew, see syncCredentials.
+func rotateCredential(ctx context.Context, client *client.CrowdStrikeAPISpecification, ref string, keys encryption.Config) error {
+	var cred credentials.Credential
+	if err := falconapi.ReadObject(ctx, client, credentials.Collection, ref, &cred); err != nil {
+		return fmt.Errorf("error reading credential %q: %v", ref, err)
+	}
+
+	rotated, changed, err := cred.Rotate(keys)
+	if errors.Is(err, encryption.ErrUnknownKey) {
+		slog.Warn("Stale credential not re-encrypted", "ref", ref, "error", err)
+		return nil
+	}
+	if err != nil || !changed {
+		return err
+	}
+	if err := falconapi.WriteObject(ctx, client, credentials.Collection, ref, rotated); err != nil {
+		return fmt.Errorf("error storing credential %q: %v", ref, err)
+	}
+	slog.Info("Re-encrypted credential", "ref", ref, "key_id", keys.ActiveKey)
+
+	return nil
+}
+
+// recordChanges writes the changes of the image list to the changes collection under the run ID
+// and deletes the change sets exceeding the retention. Nothing is written without changes.
+func recordChanges(ctx context.Context, client *client.CrowdStrikeAPISpecification, runID string, imageData images.ImageList, retention int) error {
+	if len(imageData.Changes) == 0 {
+		return nil
+	}
+
+	changeSet := images.ChangeSet{
+		ID:      runID,
+		Time:    imageData.Updated,
+		Changes: imageData.Changes,
+	}
+	if err := falconapi.WriteObject(ctx, client, images.ChangesCollection, changeSet.ID, changeSet); err != nil {
+		return fmt.Errorf("error storing changes: %v", err)
+	}
+
+	if err := pruneCollection(ctx, client, images.ChangesCollection, retention); err != nil {
+		// The changes are stored, pruning is retried on the next sync
+		slog.Warn("failed to prune changes", "error", err)
+	}
+
+	return nil
+}
+
+// pruneCollection deletes the oldest objects of a collection keyed by run or job ID beyond the
+// retention.
+func pruneCollection(ctx context.Context, client *client.CrowdStrikeAPISpecification, collection string, retention int) error {
+	keys, err := falconapi.ListObjects(ctx, client, collection)
+	if err != nil {
+		return err
+	}
+	for _, key := range runs.Expired(keys, retention) {
+		if err := falconapi.DeleteObject(ctx, client, collection, key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"syncimages/api"
+	"syncimages/catalog"
+	"syncimages/config"
+	falconapi "syncimages/falcon"
+	"syncimages/images"
+	"syncimages/jobs"
+
+	fdk "github.com/CrowdStrike/foundry-fn-go"
+)
+
+// syncImagesHandler syncs the images of the catalog, or of the sensor types of the request, and
+// stores them. The sync runs in the background when the request is asynchronous. It covers the CID
+// of the app, or the MSSP member CIDs when the request selects them.
+func syncImagesHandler(logger *slog.Logger, cfg config.Config, cat catalog.Catalog) fdk.Handler {
+	return fdk.HandlerFn(func(ctx context.Context, r fdk.Request) fdk.Response {
+		req, err := api.DecodeSyncRequest(r.Body)
+		if err != nil {
+			return errorResponse(http.StatusBadRequest, err)
+		}
+		if err := req.Validate(cat.SensorTypes()); err != nil {
+			return errorResponse(http.StatusBadRequest, err)
+		}
+		members := req.Members(cfg.Members.CIDs)
+		if req.AllMembers && len(members) == 0 {
+			return errorResponse(http.StatusBadRequest, fmt.Errorf("allMembers requires members.cids in the function config"))
+		}
+
+		accessToken := r.AccessToken
+
+		client, cloud, err := newFalconClient(accessToken)
+		if err == nil {
+			err = verifyCloud(ctx, accessToken, cloud)
+		}
+		if err != nil {
+			logger.Error("failed to create falcon client", "error", err)
+			return errorResponse(http.StatusInternalServerError, err)
+		}
+
+		store := accessToken != ""
+		if len(members) > 0 {
+			if timeout := req.Timeout(); timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, timeout)
+				defer cancel()
+			}
+
+			res := syncMembers(ctx, client, cloud, cfg, cat, req, members, store, r)
+			code := http.StatusOK
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				code = http.StatusGatewayTimeout
+			}
+			return fdk.Response{
+				Code: code,
+				Body: fdk.JSON(res),
+			}
+		}
+
+		// TODO: better way to determine we are running in a foundry function?
+		previous := images.ImageList{}
+		if accessToken != "" {
+			previous, err = readImages(client, "")
+			if err != nil && statusFor(err) != http.StatusNotFound {
+				logger.Error("failed to read stored images", "error", err)
+				return errorResponse(http.StatusInternalServerError, err)
+			}
+		}
+
+		selected := req.SensorTypes
+		if len(selected) == 0 {
+			selected = cat.SensorTypes()
+		}
+		if minInterval := cfg.Sync.MinInterval(); !req.Force && minInterval > 0 && previous.Fresh(selected, minInterval, time.Now()) {
+			logger.Info("skipping sync, stored images are recent", "sensor_types", selected, "min_interval", minInterval)
+			// Nothing changed since the stored images were synced
+			previous.Changes = nil
+			return fdk.Response{
+				Code: 200,
+				Body: fdk.JSON(api.NewSyncResponse(previous)),
+			}
+		}
+
+		run := newRun(req, cloud, r)
+
+		if req.Async {
+			if !store {
+				return errorResponse(http.StatusBadRequest, fmt.Errorf("async syncs require the %s collection and cannot run locally", jobs.Collection))
+			}
+
+			id, err := jobs.NewID()
+			if err != nil {
+				return errorResponse(http.StatusInternalServerError, err)
+			}
+			tracker, err := jobs.NewTracker(id, selected, func(job jobs.Job) error {
+				return falconapi.WriteObject(context.Background(), client, jobs.Collection, job.ID, job)
+			})
+			if err != nil {
+				logger.Error("failed to create sync job", "error", err)
+				return errorResponse(http.StatusInternalServerError, err)
+			}
+
+			// The job outlives the request, so it must not be canceled with it.
+			jobCtx := context.WithoutCancel(ctx)
+			go func() {
+				if timeout := req.Timeout(); timeout > 0 {
+					var cancel context.CancelFunc
+					jobCtx, cancel = context.WithTimeout(jobCtx, timeout)
+					defer cancel()
+				}
+
+				tracker.Start()
+				run.JobID = id
+				imageData, err := runSync(jobCtx, client, member{client: client}, cloud, cfg, cat, req, previous, store, tracker, run)
+				if err != nil {
+					logger.Error("sync job failed", "job_id", id, "error", err)
+				}
+				tracker.Finish(imageData, err)
+
+				if err := pruneCollection(jobCtx, client, jobs.Collection, cfg.Sync.JobsKept()); err != nil {
+					logger.Warn("failed to prune sync jobs", "error", err)
+				}
+			}()
+
+			return fdk.Response{
+				Code: http.StatusAccepted,
+				Body: fdk.JSON(tracker.Job()),
+			}
+		}
+
+		if timeout := req.Timeout(); timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		imageData, err := runSync(ctx, client, member{client: client}, cloud, cfg, cat, req, previous, store, nil, run)
+		if err != nil {
+			logger.Error("failed to sync images", "error", err)
+			if errors.Is(err, context.DeadlineExceeded) {
+				return errorResponse(http.StatusGatewayTimeout, err)
+			}
+			return errorResponse(http.StatusInternalServerError, err)
+		}
+
+		return fdk.Response{
+			Code: 200,
+			Body: fdk.JSON(api.NewSyncResponse(imageData)),
+		}
+	})
+}
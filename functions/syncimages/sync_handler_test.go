@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"syncimages/api"
+	"syncimages/catalog"
+	"syncimages/config"
+
+	fdk "github.com/CrowdStrike/foundry-fn-go"
+)
+
+const testMemberCID = "0123456789abcdef0123456789abcdef-ab"
+
+// fakeAPI answers the CrowdStrike API requests whose path ends with a key of responses with its
+// body, and every other request with an error of status code, a server error when unset. It
+// records the paths.
+type fakeAPI struct {
+	mu        sync.Mutex
+	paths     []string
+	responses map[string]string
+	code      int
+}
+
+func (f *fakeAPI) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.paths = append(f.paths, req.URL.Path)
+
+	code := f.code
+	if code == 0 {
+		code = http.StatusInternalServerError
+	}
+	body := fmt.Sprintf(`{"errors":[{"code":%d,"message":"%s"}]}`, code, http.StatusText(code))
+	for path, response := range f.responses {
+		if strings.HasSuffix(req.URL.Path, path) {
+			code, body = http.StatusOK, response
+		}
+	}
+
+	return &http.Response{
+		StatusCode: code,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+func (f *fakeAPI) requested(path string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, p := range f.paths {
+		if strings.HasSuffix(p, path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// withFakeAPI routes the requests of the Falcon clients to a fake API.
+func withFakeAPI(t *testing.T) *fakeAPI {
+	t.Helper()
+	t.Setenv("FALCON_CLOUD", "us-1")
+
+	api := &fakeAPI{}
+	transport := http.DefaultTransport
+	http.DefaultTransport = api
+	t.Cleanup(func() { http.DefaultTransport = transport })
+
+	return api
+}
+
+func TestSyncImagesHandler(t *testing.T) {
+	cat, err := catalog.Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Config{Members: config.Members{CIDs: []string{testMemberCID}, ClientIDEnv: "TEST_MSSP_CLIENT_ID", ClientSecretEnv: "TEST_MSSP_CLIENT_SECRET"}}
+
+	tests := []struct {
+		name    string
+		config  config.Config
+		body    string
+		code    int
+		members []string
+		// app is set when the images of the app are read, i.e. the sync covers the CID of the app
+		app bool
+	}{
+		{name: "app with configured members", config: cfg, body: `{}`, code: http.StatusInternalServerError, app: true},
+		{name: "all members", config: cfg, body: `{"allMembers":true}`, code: http.StatusOK, members: []string{testMemberCID}},
+		{name: "named members", config: cfg, body: `{"memberCids":["` + strings.ToUpper(testMemberCID) + `"]}`, code: http.StatusOK, members: []string{testMemberCID}},
+		{name: "all members without configured members", body: `{"allMembers":true}`, code: http.StatusBadRequest},
+		{name: "async all members", config: cfg, body: `{"allMembers":true,"async":true}`, code: http.StatusBadRequest},
+		{name: "async named members", body: `{"memberCids":["` + testMemberCID + `"],"async":true}`, code: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := withFakeAPI(t)
+			handler := syncImagesHandler(newLogger(io.Discard, false, false), tt.config, cat)
+
+			res := handler.Handle(context.Background(), fdk.Request{
+				Method:      http.MethodPost,
+				URL:         "/sync-images",
+				Body:        io.NopCloser(strings.NewReader(tt.body)),
+				AccessToken: "token",
+			})
+			if res.Code != tt.code {
+				t.Fatalf("code = %d, want %d", res.Code, tt.code)
+			}
+			if got := fake.requested("/objects/all"); got != tt.app {
+				t.Errorf("images of the app read = %v, want %v", got, tt.app)
+			}
+			if tt.members == nil {
+				return
+			}
+
+			b, err := res.Body.MarshalJSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got api.SyncResponse
+			if err := json.Unmarshal(b, &got); err != nil {
+				t.Fatal(err)
+			}
+			if len(got.Members) != len(tt.members) {
+				t.Fatalf("members = %+v, want %v", got.Members, tt.members)
+			}
+			for i, member := range got.Members {
+				// The parent API client is not configured, so every member fails on its own
+				if member.MemberCID != tt.members[i] || !strings.Contains(member.Error, "TEST_MSSP_CLIENT_ID") {
+					t.Errorf("members[%d] = %+v, want %s failing without the parent API client", i, member, tt.members[i])
+				}
+			}
+		})
+	}
+}
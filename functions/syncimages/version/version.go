@@ -1,3 +1,107 @@
 package version
 
+import (
+	"runtime/debug"
+	"strings"
+)
+
+// Version is the release version, replaced by make package. Builds from source keep the
+// placeholder, see Current.
 const Version = "0.0.0-dev"
+
+// devSuffix ends the placeholder of Version in the sources. The placeholder itself is not repeated
+// here, as make package replaces every occurrence of it.
+const devSuffix = "-dev"
+
+// modules are the dependencies whose versions are reported, by name.
+var modules = map[string]string{
+	"gofalcon":        "github.com/crowdstrike/gofalcon",
+	"containersImage": "github.com/containers/image/v5",
+	"foundryFn":       "github.com/CrowdStrike/foundry-fn-go",
+}
+
+// Info describes the build of the function.
+type Info struct {
+	Version   string `json:"version"`
+	GoVersion string `json:"goVersion,omitempty"`
+	// Revision, RevisionTime and Modified describe the VCS commit the function was built from,
+	// when the build recorded it.
+	Revision     string `json:"revision,omitempty"`
+	RevisionTime string `json:"revisionTime,omitempty"`
+	Modified     bool   `json:"modified,omitempty"`
+	// Modules are the versions of all the modules the function is built with, by module path.
+	Modules map[string]string `json:"modules"`
+}
+
+// Get returns the build information of the function. Only the version is known when the binary
+// carries no build information.
+func Get() Info {
+	info := Info{Version: Current(), Modules: map[string]string{}}
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+
+	info.GoVersion = build.GoVersion
+	for _, setting := range build.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			info.Revision = setting.Value
+		case "vcs.time":
+			info.RevisionTime = setting.Value
+		case "vcs.modified":
+			info.Modified = setting.Value == "true"
+		}
+	}
+	for _, dep := range build.Deps {
+		if dep.Replace != nil {
+			dep = dep.Replace
+		}
+		info.Modules[dep.Path] = dep.Version
+	}
+
+	return info
+}
+
+// Current returns the version of the function: Version for release packages, otherwise the
+// placeholder with the VCS revision of the build when recorded, e.g. 0.0.0-dev+3f2c1ab.
+func Current() string {
+	if !strings.HasSuffix(Version, devSuffix) {
+		return Version
+	}
+
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return Version
+	}
+	if v := build.Main.Version; v != "" && v != "(devel)" {
+		return strings.TrimPrefix(v, "v")
+	}
+	for _, setting := range build.Settings {
+		if setting.Key == "vcs.revision" && len(setting.Value) >= 7 {
+			return Version + "+" + setting.Value[:7]
+		}
+	}
+
+	return Version
+}
+
+// Dependencies returns the versions of the main dependencies the function is built with, by name.
+// It is empty when the binary carries no build information.
+func Dependencies() map[string]string {
+	deps := map[string]string{}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return deps
+	}
+
+	for _, dep := range info.Deps {
+		for name, path := range modules {
+			if dep.Path == path {
+				deps[name] = dep.Version
+			}
+		}
+	}
+
+	return deps
+}
@@ -0,0 +1,35 @@
+package version
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCurrent(t *testing.T) {
+	// Test binaries are built from source and keep the placeholder
+	if got := Current(); !strings.HasPrefix(got, Version) {
+		t.Errorf("Current() = %q, want the %s placeholder", got, Version)
+	}
+}
+
+func TestDependencies(t *testing.T) {
+	deps := Dependencies()
+	if deps == nil {
+		t.Fatal("Dependencies() = nil, want a map")
+	}
+	for name := range deps {
+		if _, ok := modules[name]; !ok {
+			t.Errorf("Dependencies() reports %q, want only the modules %v", name, modules)
+		}
+	}
+}
+
+func TestGet(t *testing.T) {
+	info := Get()
+	if info.Version != Current() || info.Modules == nil {
+		t.Errorf("Get() = %+v, want the current version and the modules", info)
+	}
+	if info.GoVersion == "" {
+		t.Error("Get() has no Go version, want the one of the test binary")
+	}
+}
@@ -0,0 +1,145 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"syncimages/falcon"
+	"syncimages/images"
+)
+
+// EventType is the event of the generic payload.
+const EventType = "images.changed"
+
+// maxLines is the number of changes listed in Slack and Teams messages.
+const maxLines = 20
+
+// GenericPayload is the body of generic webhooks.
+type GenericPayload struct {
+	Event   string          `json:"event"`
+	Time    time.Time       `json:"time"`
+	Changes []images.Change `json:"changes"`
+}
+
+// payload encodes the changes in the format of the webhook. The generic payload carries the
+// changes as stored, the Slack and Teams messages mask the member CIDs unless unmaskCID is set.
+func (h Webhook) payload(changes []images.Change, unmaskCID bool) ([]byte, error) {
+	var v interface{}
+	switch h.Format {
+	case FormatSlack:
+		v = map[string]interface{}{
+			"text": summary(changes),
+			"blocks": []map[string]interface{}{
+				{
+					"type": "section",
+					"text": map[string]string{"type": "mrkdwn", "text": "*" + summary(changes) + "*\n" + strings.Join(lines(changes, "`", unmaskCID), "\n")},
+				},
+			},
+		}
+	case FormatTeams:
+		body := []map[string]interface{}{
+			{"type": "TextBlock", "text": summary(changes), "weight": "Bolder", "size": "Medium", "wrap": true},
+		}
+		for _, line := range lines(changes, "", unmaskCID) {
+			body = append(body, map[string]interface{}{"type": "TextBlock", "text": line, "wrap": true, "spacing": "None"})
+		}
+		v = map[string]interface{}{
+			"type": "message",
+			"attachments": []map[string]interface{}{
+				{
+					"contentType": "application/vnd.microsoft.card.adaptive",
+					"content": map[string]interface{}{
+						"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+						"type":    "AdaptiveCard",
+						"version": "1.4",
+						"body":    body,
+					},
+				},
+			},
+		}
+	default:
+		v = GenericPayload{Event: EventType, Time: time.Now().UTC(), Changes: changes}
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding webhook payload: %v", err)
+	}
+
+	return b, nil
+}
+
+// summary returns the headline of a message, e.g. "CrowdStrike images: 2 added, 1 digest changed".
+func summary(changes []images.Change) string {
+	counts := map[string]int{}
+	for _, change := range changes {
+		counts[change.Type]++
+	}
+
+	parts := []string{}
+	for _, t := range []string{images.ChangeAdded, images.ChangeRemoved, images.ChangeDigestChanged, images.ChangeCredentials} {
+		if counts[t] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[t], describe(t)))
+		}
+	}
+
+	return "CrowdStrike images: " + strings.Join(parts, ", ")
+}
+
+// lines returns a line per change, up to maxLines, quoting references with quote.
+func lines(changes []images.Change, quote string, unmaskCID bool) []string {
+	out := []string{}
+	for i, change := range changes {
+		if i == maxLines {
+			out = append(out, fmt.Sprintf("... and %d more", len(changes)-maxLines))
+			break
+		}
+
+		ref := change.Repository
+		if change.Tag != "" {
+			ref += ":" + change.Tag
+		}
+		line := fmt.Sprintf("%s %s%s%s", describe(change.Type), quote, ref, quote)
+		if change.Type == images.ChangeDigestChanged {
+			line += fmt.Sprintf(" (%s → %s)", shortDigest(change.PreviousDigest), shortDigest(change.Digest))
+		}
+		if change.MemberCID != "" {
+			cid := change.MemberCID
+			if !unmaskCID {
+				cid = falcon.MaskCID(cid)
+			}
+			line += " for member CID " + cid
+		}
+		out = append(out, line)
+	}
+
+	return out
+}
+
+// describe returns the human readable change type.
+func describe(changeType string) string {
+	switch changeType {
+	case images.ChangeAdded:
+		return "added"
+	case images.ChangeRemoved:
+		return "removed"
+	case images.ChangeDigestChanged:
+		return "digest changed"
+	case images.ChangeCredentials:
+		return "credentials changed"
+	default:
+		return changeType
+	}
+}
+
+// shortDigest returns the first 12 hex characters of the digest.
+func shortDigest(digest string) string {
+	digest = strings.TrimPrefix(digest, "sha256:")
+	if len(digest) > 12 {
+		return digest[:12]
+	}
+
+	return digest
+}
@@ -0,0 +1,291 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"syncimages/images"
+
+	"github.com/Masterminds/semver"
+)
+
+// Payload formats.
+const (
+	FormatGeneric = "generic"
+	FormatSlack   = "slack"
+	FormatTeams   = "teams"
+)
+
+// SignatureHeader carries the hex encoded HMAC-SHA256 of the body, prefixed with sha256=.
+const SignatureHeader = "X-Signature-256"
+
+// defaultRetries is the number of retries of a failed delivery when the webhook does not set one.
+const defaultRetries = 3
+
+// retryBackoff is the delay before the first retry, doubled for every further retry.
+const retryBackoff = time.Second
+
+// requestTimeout bounds a single delivery attempt.
+const requestTimeout = 10 * time.Second
+
+// notifyTimeout bounds all the deliveries of a notification, retries included.
+const notifyTimeout = 30 * time.Second
+
+// Webhook is an outbound webhook called with the changes found by a sync.
+type Webhook struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// Format is the payload format: generic (default), slack or teams.
+	Format string `json:"format,omitempty"`
+	// Events restricts the change types sent, e.g. added. Defaults to every type.
+	Events []string `json:"events,omitempty"`
+	// SensorTypes restricts the changes sent to these sensor types.
+	SensorTypes []string `json:"sensorTypes,omitempty"`
+	// Constraint restricts tag changes to tags matching the semver constraint, e.g. ">= 7.20".
+	Constraint string `json:"constraint,omitempty"`
+	// Secret (or SecretEnv, the name of an environment variable holding the secret) signs the
+	// body in the X-Signature-256 header.
+	Secret    string `json:"secret,omitempty"`
+	SecretEnv string `json:"secretEnv,omitempty"`
+	// Retries is the number of retries of a failed delivery, 3 when unset. -1 disables retries.
+	Retries int `json:"retries,omitempty"`
+
+	constraint *semver.Constraints
+}
+
+// Delivery is the outcome of calling a webhook.
+type Delivery struct {
+	Name     string `json:"name"`
+	Status   int    `json:"status,omitempty"`
+	Attempts int    `json:"attempts"`
+	Changes  int    `json:"changes"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Validate checks the webhooks and compiles their constraints.
+func Validate(hooks []Webhook) error {
+	names := map[string]bool{}
+	for i := range hooks {
+		hook := &hooks[i]
+		if hook.Name == "" {
+			return fmt.Errorf("webhook %d: name is required", i)
+		}
+		if names[hook.Name] {
+			return fmt.Errorf("webhook %q: duplicate name", hook.Name)
+		}
+		names[hook.Name] = true
+
+		u, err := url.Parse(hook.URL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("webhook %q: url must be an http or https URL", hook.Name)
+		}
+
+		switch hook.Format {
+		case "", FormatGeneric, FormatSlack, FormatTeams:
+		default:
+			return fmt.Errorf("webhook %q: unknown format %q, expected %s, %s or %s", hook.Name, hook.Format, FormatGeneric, FormatSlack, FormatTeams)
+		}
+
+		for _, event := range hook.Events {
+			switch event {
+			case images.ChangeAdded, images.ChangeRemoved, images.ChangeDigestChanged, images.ChangeCredentials:
+			default:
+				return fmt.Errorf("webhook %q: unknown event %q", hook.Name, event)
+			}
+		}
+
+		if hook.Constraint != "" {
+			c, err := semver.NewConstraint(hook.Constraint)
+			if err != nil {
+				return fmt.Errorf("webhook %q: invalid constraint %q: %v", hook.Name, hook.Constraint, err)
+			}
+			hook.constraint = c
+		}
+	}
+
+	return nil
+}
+
+// Notify calls every webhook with the changes matching its filters, in parallel and for at most
+// notifyTimeout. Webhooks without matching changes are not called. Failed deliveries are returned
+// and do not stop the other webhooks. Slack and Teams messages mask the member CIDs unless
+// unmaskCID is set.
+func Notify(ctx context.Context, hooks []Webhook, changes []images.Change, unmaskCID bool) []Delivery {
+	ctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+	defer cancel()
+
+	results := make([]*Delivery, len(hooks))
+	var wg sync.WaitGroup
+	for i, hook := range hooks {
+		matching := hook.Filter(changes)
+		if len(matching) == 0 {
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			delivery := hook.Send(ctx, matching, unmaskCID)
+			if delivery.Error != "" {
+				slog.Warn("Webhook delivery failed", "webhook", hook.Name, "attempts", delivery.Attempts, "error", delivery.Error)
+			} else {
+				slog.Info("Webhook delivered", "webhook", hook.Name, "status", delivery.Status, "changes", delivery.Changes)
+			}
+			results[i] = &delivery
+		}()
+	}
+	wg.Wait()
+
+	deliveries := []Delivery{}
+	for _, delivery := range results {
+		if delivery != nil {
+			deliveries = append(deliveries, *delivery)
+		}
+	}
+
+	return deliveries
+}
+
+// Unfiltered returns a copy of the webhook without its event, sensor type and constraint filters.
+func (h Webhook) Unfiltered() Webhook {
+	h.Events, h.SensorTypes, h.Constraint, h.constraint = nil, nil, "", nil
+
+	return h
+}
+
+// Filter returns the changes matching the events, sensor types and constraint of the webhook.
+func (h Webhook) Filter(changes []images.Change) []images.Change {
+	matching := []images.Change{}
+	for _, change := range changes {
+		if len(h.Events) > 0 && !contains(h.Events, change.Type) {
+			continue
+		}
+		if len(h.SensorTypes) > 0 && !contains(h.SensorTypes, change.SensorType) {
+			continue
+		}
+		if h.constraint != nil && change.Tag != "" && !h.matchVersion(change.Tag) {
+			continue
+		}
+		matching = append(matching, change)
+	}
+
+	return matching
+}
+
+// matchVersion reports whether the tag version matches the constraint.
+func (h Webhook) matchVersion(tag string) bool {
+	v, ok := images.ParseVersion(tag)
+	if !ok {
+		return false
+	}
+
+	sv, err := semver.NewVersion(fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch))
+	return err == nil && h.constraint.Check(sv)
+}
+
+// Send posts the changes to the webhook, retrying network errors, 429 and 5xx responses.
+func (h Webhook) Send(ctx context.Context, changes []images.Change, unmaskCID bool) Delivery {
+	delivery := Delivery{Name: h.Name, Changes: len(changes)}
+
+	body, err := h.payload(changes, unmaskCID)
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+
+	retries := h.Retries
+	if retries == 0 {
+		retries = defaultRetries
+	}
+	backoff := retryBackoff
+
+	for {
+		delivery.Attempts++
+		status, err := h.post(ctx, body)
+		delivery.Status = status
+		if err == nil {
+			delivery.Error = ""
+			return delivery
+		}
+		delivery.Error = err.Error()
+
+		retryable := status == 0 || status == http.StatusTooManyRequests || status >= 500
+		if !retryable || delivery.Attempts > retries || ctx.Err() != nil {
+			return delivery
+		}
+
+		select {
+		case <-ctx.Done():
+			return delivery
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// post sends a single delivery attempt and returns the response status.
+func (h Webhook) post(ctx context.Context, body []byte) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("error creating request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if secret := h.secret(); secret != "" {
+		req.Header.Set(SignatureHeader, Sign(secret, body))
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 4096))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("unexpected status %s", res.Status)
+	}
+
+	return res.StatusCode, nil
+}
+
+// secret returns the signing secret of the webhook.
+func (h Webhook) secret() string {
+	if h.SecretEnv != "" {
+		return os.Getenv(h.SecretEnv)
+	}
+
+	return h.Secret
+}
+
+// Sign returns the value of the signature header for the body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+
+	return false
+}
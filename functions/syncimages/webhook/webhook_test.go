@@ -0,0 +1,212 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"syncimages/images"
+)
+
+func TestSign(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string
+		body   string
+		want   string
+	}{
+		// Reference values computed with: printf '<body>' | openssl dgst -sha256 -hmac '<secret>'
+		{name: "empty body", secret: "key", body: "", want: "sha256=5d5d139563c95b5967b9bd9a8c9b233a9dedb45072794cd232dc1b74832607d0"},
+		{name: "sentence", secret: "key", body: "The quick brown fox jumps over the lazy dog", want: "sha256=f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sign(tt.secret, []byte(tt.body)); got != tt.want {
+				t.Errorf("Sign() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilter(t *testing.T) {
+	changes := []images.Change{
+		{Type: images.ChangeAdded, SensorType: "falcon-sensor", Tag: "7.20.0-17306.falcon-linux.Release.US-1"},
+		{Type: images.ChangeAdded, SensorType: "falcon-sensor", Tag: "7.18.0-17106.falcon-linux.Release.US-1"},
+		{Type: images.ChangeRemoved, SensorType: "falcon-kac", Tag: "7.21.0-1"},
+		{Type: images.ChangeCredentials, SensorType: "falcon-kac"},
+		{Type: images.ChangeAdded, SensorType: "falcon-kac", Tag: "latest"},
+	}
+
+	tests := []struct {
+		name string
+		hook Webhook
+		want []int
+	}{
+		{name: "no filter", hook: Webhook{}, want: []int{0, 1, 2, 3, 4}},
+		{name: "events", hook: Webhook{Events: []string{images.ChangeRemoved, images.ChangeCredentials}}, want: []int{2, 3}},
+		{name: "sensor types", hook: Webhook{SensorTypes: []string{"falcon-sensor"}}, want: []int{0, 1}},
+		// Changes without a tag are kept, unversioned tags never match
+		{name: "constraint", hook: Webhook{Constraint: ">= 7.20"}, want: []int{0, 2, 3}},
+		{name: "combined", hook: Webhook{Events: []string{images.ChangeAdded}, Constraint: ">= 7.20"}, want: []int{0}},
+		{name: "unfiltered", hook: Webhook{Events: []string{images.ChangeRemoved}, Constraint: ">= 8"}.Unfiltered(), want: []int{0, 1, 2, 3, 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.hook.Name, tt.hook.URL = "hook", "https://example.com/hook"
+			hooks := []Webhook{tt.hook}
+			if err := Validate(hooks); err != nil {
+				t.Fatal(err)
+			}
+
+			got := hooks[0].Filter(changes)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Filter() = %+v, want changes %v", got, tt.want)
+			}
+			for i, index := range tt.want {
+				if got[i].Type != changes[index].Type || got[i].SensorType != changes[index].SensorType || got[i].Tag != changes[index].Tag {
+					t.Errorf("Filter()[%d] = %+v, want %+v", i, got[i], changes[index])
+				}
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		hooks []Webhook
+		err   string
+	}{
+		{name: "valid", hooks: []Webhook{{Name: "a", URL: "https://example.com"}, {Name: "b", URL: "http://localhost:9000/hook", Format: FormatTeams}}},
+		{name: "missing name", hooks: []Webhook{{URL: "https://example.com"}}, err: "name is required"},
+		{name: "duplicate name", hooks: []Webhook{{Name: "a", URL: "https://example.com"}, {Name: "a", URL: "https://example.org"}}, err: "duplicate name"},
+		{name: "invalid url", hooks: []Webhook{{Name: "a", URL: "ftp://example.com"}}, err: "url must be"},
+		{name: "unknown format", hooks: []Webhook{{Name: "a", URL: "https://example.com", Format: "discord"}}, err: "unknown format"},
+		{name: "unknown event", hooks: []Webhook{{Name: "a", URL: "https://example.com", Events: []string{"deleted"}}}, err: "unknown event"},
+		{name: "invalid constraint", hooks: []Webhook{{Name: "a", URL: "https://example.com", Constraint: ">> 7"}}, err: "invalid constraint"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.hooks)
+			if tt.err == "" && err != nil {
+				t.Errorf("Validate() error = %v", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestPayloadMasksMemberCID(t *testing.T) {
+	changes := []images.Change{{Type: images.ChangeAdded, Repository: "registry.example.com/falcon-sensor", Tag: "7.20.0-1", MemberCID: "0123456789abcdef0123456789abcdef-ab"}}
+	masked := "0123************************cdef-ab"
+
+	tests := []struct {
+		format    string
+		unmaskCID bool
+		want      string
+		notWant   string
+	}{
+		{format: FormatSlack, want: masked, notWant: changes[0].MemberCID},
+		{format: FormatTeams, want: masked, notWant: changes[0].MemberCID},
+		{format: FormatSlack, unmaskCID: true, want: changes[0].MemberCID},
+		{format: FormatGeneric, want: changes[0].MemberCID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			body, err := Webhook{Format: tt.format}.payload(changes, tt.unmaskCID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(body), tt.want) {
+				t.Errorf("payload() = %s, want it to contain %q", body, tt.want)
+			}
+			if tt.notWant != "" && strings.Contains(string(body), tt.notWant) {
+				t.Errorf("payload() = %s, want it without %q", body, tt.notWant)
+			}
+		})
+	}
+}
+
+func TestSend(t *testing.T) {
+	changes := []images.Change{{Type: images.ChangeAdded, SensorType: "falcon-sensor", Repository: "registry.example.com/falcon-sensor", Tag: "7.20.0-1"}}
+
+	tests := []struct {
+		name     string
+		retries  int
+		statuses []int
+		status   int
+		attempts int
+		failed   bool
+	}{
+		{name: "delivered", statuses: []int{http.StatusNoContent}, status: http.StatusNoContent, attempts: 1},
+		{name: "retried", statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK}, status: http.StatusOK, attempts: 3},
+		{name: "client error", statuses: []int{http.StatusBadRequest}, status: http.StatusBadRequest, attempts: 1, failed: true},
+		{name: "retries disabled", retries: -1, statuses: []int{http.StatusBadGateway}, status: http.StatusBadGateway, attempts: 1, failed: true},
+		{name: "retries exhausted", retries: 1, statuses: []int{http.StatusBadGateway, http.StatusBadGateway}, status: http.StatusBadGateway, attempts: 2, failed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				call := int(calls.Add(1)) - 1
+				body, _ := io.ReadAll(r.Body)
+				if got := r.Header.Get(SignatureHeader); got != Sign("secret", body) {
+					t.Errorf("signature = %q, want %q", got, Sign("secret", body))
+				}
+				var payload GenericPayload
+				if err := json.Unmarshal(body, &payload); err != nil || payload.Event != EventType || len(payload.Changes) != 1 {
+					t.Errorf("payload = %s, want the generic payload with the change", body)
+				}
+				w.WriteHeader(tt.statuses[call])
+			}))
+			defer server.Close()
+
+			hook := Webhook{Name: "hook", URL: server.URL, Secret: "secret", Retries: tt.retries}
+			delivery := hook.Send(context.Background(), changes, false)
+			if delivery.Status != tt.status || delivery.Attempts != tt.attempts || (delivery.Error != "") != tt.failed {
+				t.Errorf("Send() = %+v, want status %d after %d attempts, failed %v", delivery, tt.status, tt.attempts, tt.failed)
+			}
+			if delivery.Name != "hook" || delivery.Changes != 1 {
+				t.Errorf("Send() = %+v, want the name and change count of the webhook", delivery)
+			}
+		})
+	}
+}
+
+func TestNotify(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	hooks := []Webhook{
+		{Name: "added", URL: server.URL, Events: []string{images.ChangeAdded}},
+		{Name: "removed", URL: server.URL, Events: []string{images.ChangeRemoved}},
+		{Name: "all", URL: server.URL + "/all"},
+	}
+	if err := Validate(hooks); err != nil {
+		t.Fatal(err)
+	}
+
+	deliveries := Notify(context.Background(), hooks, []images.Change{{Type: images.ChangeAdded, Tag: "7.20.0-1"}}, false)
+	if len(deliveries) != 2 || deliveries[0].Name != "added" || deliveries[1].Name != "all" {
+		t.Fatalf("Notify() = %+v, want the deliveries of added and all in order", deliveries)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("receiver called %d times, want 2", calls.Load())
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"syncimages/api"
+	"syncimages/images"
+	"syncimages/webhook"
+
+	fdk "github.com/CrowdStrike/foundry-fn-go"
+)
+
+// webhookTestHandler sends a sample change to the configured webhooks, ignoring their filters,
+// and returns the deliveries. It makes it possible to check a webhook receiver without a sync.
+func webhookTestHandler(logger *slog.Logger, hooks []webhook.Webhook) fdk.Handler {
+	return jsonHandler(logger, func(ctx context.Context, r fdk.Request) (interface{}, error) {
+		req, err := api.DecodeWebhookTestRequest(r.Body)
+		if err != nil {
+			return nil, badRequest(err)
+		}
+
+		selected := []webhook.Webhook{}
+		for _, hook := range hooks {
+			if req.Webhook == "" || hook.Name == req.Webhook {
+				selected = append(selected, hook.Unfiltered())
+			}
+		}
+		if len(selected) == 0 {
+			return nil, notFound(fmt.Errorf("no webhook configured with the name %q", req.Webhook))
+		}
+
+		sample := []images.Change{{
+			Type:       images.ChangeAdded,
+			SensorType: "falcon-sensor",
+			Repository: "registry.crowdstrike.com/falcon-sensor/us-1/release/falcon-sensor",
+			Tag:        "7.20.0-17306.falcon-linux.Release.US-1",
+			Digest:     "sha256:0000000000000000000000000000000000000000000000000000000000000000",
+			Arch:       []string{"x86_64", "aarch64"},
+			Time:       time.Now().UTC(),
+		}}
+
+		return map[string]interface{}{
+			"deliveries": webhook.Notify(ctx, selected, sample, false),
+		}, nil
+	})
+}
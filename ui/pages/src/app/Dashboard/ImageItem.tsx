@@ -88,7 +88,11 @@ export function ImageItem({ image }: ImageItemProps) {
                 <DescriptionListGroup>
                   <DescriptionListTerm>Latest tag</DescriptionListTerm>
                   <DescriptionListDescription>
-                    <code>{image.latest}</code>
+                    {image.state === "notEntitled" ? (
+                      <span title={image.stateReason}>Not entitled</span>
+                    ) : (
+                      <code>{image.latest}</code>
+                    )}
                   </DescriptionListDescription>
                 </DescriptionListGroup>
                 <DescriptionListGroup>
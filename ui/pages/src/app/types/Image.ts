@@ -1,16 +1,33 @@
 export default interface Image {
+  sensorType: string;
+  source: "crowdstrike" | "external";
   name: string;
   description: string;
+  docsUrl?: string;
   latest: string;
   registry: string;
   repository: string;
   digest: string;
-  login: string;
-  password: string;
-  dockerAuthConfig: string;
+  latestByArch?: Record<string, { tag: string; digest: string }>;
+  latestByStream?: Record<string, { tag: string; digest: string }>;
+  credentialRef?: string;
+  updated?: string;
+  durationMs?: number;
+  state?: "notEntitled";
+  stateReason?: string;
+  unsynced?: string[];
+  filtered?: string[];
   tags: {
     name: string;
     digest: string;
     arch: string[];
+    release?: string;
+    newest?: boolean;
+    support?: "supported" | "unsupported";
+    firstSeen?: string;
+    lastSeen?: string;
+    digests?: { digest: string; firstSeen: string; lastSeen: string }[];
+    mutated?: boolean;
+    previousDigests?: string[];
   }[];
 }
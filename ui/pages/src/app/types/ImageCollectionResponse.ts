@@ -4,6 +4,25 @@ export default interface ImageCollectionResponse {
   duration: number;
   updated: Date;
   images: Image[];
+  discovered?: {
+    repositories: {
+      registry: string;
+      repository: string;
+      login?: string;
+      status: string;
+    }[];
+    errors?: string[];
+  };
+  changes?: {
+    type: "added" | "removed" | "digestChanged" | "credentialsChanged";
+    sensorType: string;
+    repository: string;
+    tag: string;
+    digest?: string;
+    previousDigest?: string;
+    arch?: string[];
+    time: string;
+  }[];
   errors?: {
     code: number;
     message: string;